@@ -0,0 +1,14 @@
+// Command snippet is a command-line client of Snippetbox, which creates,
+// prints, lists and deletes snippets through the JSON API of a server.
+// Run it with -h for the list of commands.
+package main
+
+import (
+	"os"
+
+	"github.com/AlessioPani/go-snippetbox/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
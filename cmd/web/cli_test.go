@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/cli"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func TestCLI(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	// Run the application on a temporary database.
+	dir := t.TempDir()
+	db := openTestDB(t, filepath.Join(dir, "snippetbox.db"))
+	defer db.Close()
+
+	app := newTestApplication(t)
+	app.snippets = &models.SnippetModel{DB: db}
+	app.users = &models.UserModel{DB: db}
+	app.tokens = &models.TokenModel{DB: db}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	err := app.users.Insert(context.Background(), "John Doe", "test@test.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := app.tokens.Insert(1, "CLI", []string{models.ScopeSnippetsRead, models.ScopeSnippetsWrite}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Configure the client to trust the certificate of the test server.
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	err = os.WriteFile(filepath.Join(dir, "cert.pem"), cert, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// writeConfig writes a configuration file with the token and returns its
+	// path.
+	writeConfig := func(t *testing.T, name string, token string) string {
+		cfg, err := json.Marshal(cli.Config{URL: ts.URL, Token: token, CACert: "cert.pem"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(dir, name)
+		err = os.WriteFile(path, cfg, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	config := writeConfig(t, "config.json", token)
+
+	// run runs the client with the arguments after the global flags.
+	run := func(t *testing.T, stdin string, args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := cli.Run(append([]string{"-config", config}, args...), strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	const content = "package main\n\nfunc main() {}\n"
+
+	t.Run("Create", func(t *testing.T) {
+		code, stdout, stderr := run(t, content, "create", "--title", "Hello", "--expires", "7", "--lang", "go")
+		assert.Equal(t, stderr, "")
+		assert.Equal(t, code, 0)
+		assert.Equal(t, stdout, "Created snippet #1: "+ts.URL+"/snippet/view/1/\n")
+
+		code, stdout, _ = run(t, "Second", "-output", "json", "create", "--title", "World")
+		assert.Equal(t, code, 0)
+
+		var snippet cli.Snippet
+		err := json.Unmarshal([]byte(stdout), &snippet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, snippet.ID, 2)
+		assert.Equal(t, snippet.UserID, 1)
+		assert.Equal(t, snippet.Content, "Second")
+	})
+
+	t.Run("Create with invalid data", func(t *testing.T) {
+		code, stdout, stderr := run(t, "", "create", "--title", "Empty", "--expires", "3")
+		assert.Equal(t, code, 1)
+		assert.Equal(t, stdout, "")
+		assert.StringContains(t, stderr, "422 Unprocessable Entity")
+		assert.StringContains(t, stderr, "content: This field cannot be blank")
+		assert.StringContains(t, stderr, "expires: This field must be equal to 1, 7 or 365")
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		code, stdout, _ := run(t, "", "get", "1")
+		assert.Equal(t, code, 0)
+		assert.Equal(t, stdout, content)
+
+		code, stdout, _ = run(t, "", "-output", "json", "get", "1")
+		assert.Equal(t, code, 0)
+		assert.StringContains(t, stdout, `"language": "go"`)
+
+		code, _, stderr := run(t, "", "get", "99")
+		assert.Equal(t, code, 1)
+		assert.StringContains(t, stderr, "404 Not Found")
+	})
+
+	t.Run("List", func(t *testing.T) {
+		code, stdout, _ := run(t, "", "list", "--page-size", "1")
+		assert.Equal(t, code, 0)
+		assert.StringContains(t, stdout, "ID  TITLE  LANGUAGE  EXPIRES")
+		assert.StringContains(t, stdout, "2   World")
+		assert.StringContains(t, stdout, "Page 1 of 2, 2 snippets")
+
+		code, stdout, _ = run(t, "", "-output", "json", "list")
+		assert.Equal(t, code, 0)
+
+		var page cli.Page
+		err := json.Unmarshal([]byte(stdout), &page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(page.Snippets), 2)
+		assert.Equal(t, page.Metadata.Total, 2)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		code, stdout, _ := run(t, "", "delete", "2")
+		assert.Equal(t, code, 0)
+		assert.Equal(t, stdout, "Deleted snippet #2\n")
+
+		code, _, _ = run(t, "", "get", "2")
+		assert.Equal(t, code, 1)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := cli.Run([]string{"-config", writeConfig(t, "invalid.json", "sbx_invalid"), "list"}, strings.NewReader(""), &stdout, &stderr)
+		assert.Equal(t, code, 1)
+		assert.StringContains(t, stderr.String(), "401 Unauthorized")
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		code, _, _ := run(t, "", "get")
+		assert.Equal(t, code, 2)
+
+		code, _, stderr := run(t, "", "rename", "1")
+		assert.Equal(t, code, 2)
+		assert.StringContains(t, stderr, `unknown command "rename"`)
+
+		code, _, _ = run(t, "", "list", "--limit", "3")
+		assert.Equal(t, code, 2)
+	})
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// configEnvPrefix is the prefix of the environment variables of the settings:
+// the setting of the -session-lifetime flag is read from
+// SNIPPETBOX_SESSION_LIFETIME.
+const configEnvPrefix = "SNIPPETBOX_"
+
+// configUsage is the beginning of the help message of the application.
+const configUsage = `usage: web [flags] [migrate <command>]
+
+Every flag is a setting, which is read in order of precedence from:
+  1. the command line,
+  2. its environment variable: SNIPPETBOX_ followed by its name in upper case
+     with dashes replaced by underscores, such as SNIPPETBOX_LOG_LEVEL,
+  3. the JSON configuration file given by -config or SNIPPETBOX_CONFIG, an
+     object with the names of the flags as keys, such as {"log-level": "info"},
+  4. its default value.
+
+Flags:
+`
+
+// errUsage is returned when the command line is invalid. The error has
+// already been printed by the flag set, with the usage.
+var errUsage = errors.New("invalid command line")
+
+// commandLineOnly contains the flags which aren't settings: they can only be
+// set on the command line, and aren't printed with the configuration.
+var commandLineOnly = []string{"config", "print-config"}
+
+// secretSettings contains the functions which redact the secrets of the
+// settings which may contain some, before they are printed.
+var secretSettings = map[string]func(string) string{
+	"dsn": redactDSN,
+}
+
+// config contains the settings of the application.
+type config struct {
+	addr         string
+	adminAddr    string
+	baseURL      string
+	dsn          string
+	migrate      bool
+	queryTimeout time.Duration
+	logLevel     slog.Level
+	logFormat    string
+	tls          struct {
+		certFile string
+		keyFile  string
+	}
+	server struct {
+		idleTimeout     time.Duration
+		readTimeout     time.Duration
+		writeTimeout    time.Duration
+		shutdownTimeout time.Duration
+	}
+	session struct {
+		lifetime           time.Duration
+		idleTimeout        time.Duration
+		rememberMeLifetime time.Duration
+	}
+	webAuthn struct {
+		rpID     string
+		rpOrigin string
+	}
+	webhookWorkers int
+	trace          struct {
+		exporter string
+		endpoint string
+	}
+
+	configFile  string
+	printConfig bool
+}
+
+// newFlagSet returns the flags of the application, which set the fields of
+// cfg to their default values.
+func newFlagSet(cfg *config) *flag.FlagSet {
+	fs := flag.NewFlagSet("web", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), configUsage)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&cfg.configFile, "config", "", "JSON configuration `file`")
+	fs.BoolVar(&cfg.printConfig, "print-config", false, "Print the configuration, with its secrets redacted, and exit")
+
+	fs.StringVar(&cfg.addr, "addr", ":8080", "HTTP Network Address")
+	fs.StringVar(&cfg.adminAddr, "admin-addr", "localhost:8081", "Network address of the admin listener serving the /metrics endpoint in plain HTTP, empty to disable it")
+	fs.StringVar(&cfg.baseURL, "base-url", "https://localhost:8080", "`URL` the application is reached at, which the absolute links of the feeds start with")
+	fs.StringVar(&cfg.dsn, "dsn", "./db-data/snippetbox.db", "Database dsn")
+	fs.BoolVar(&cfg.migrate, "migrate", true, "Apply the pending database migrations at startup")
+	fs.DurationVar(&cfg.queryTimeout, "query-timeout", 5*time.Second, "Time given to the snippet and user queries of a request, after which it gets a 503 response")
+	fs.TextVar(&cfg.logLevel, "log-level", slog.LevelDebug, "Minimum `level` of the logs: debug, info, warn or error")
+	fs.StringVar(&cfg.logFormat, "log-format", "text", "`format` of the logs: text (key=value pairs) or json (one object per line)")
+
+	fs.StringVar(&cfg.tls.certFile, "tls-cert", "./tls/cert.pem", "TLS certificate `file`")
+	fs.StringVar(&cfg.tls.keyFile, "tls-key", "./tls/key.pem", "TLS private key `file`")
+
+	fs.DurationVar(&cfg.server.idleTimeout, "idle-timeout", time.Minute, "Time a keep-alive connection is kept open between two requests")
+	fs.DurationVar(&cfg.server.readTimeout, "read-timeout", 5*time.Second, "Maximum duration for reading a request")
+	fs.DurationVar(&cfg.server.writeTimeout, "write-timeout", 10*time.Second, "Maximum duration for writing a response")
+	fs.DurationVar(&cfg.server.shutdownTimeout, "shutdown-timeout", 30*time.Second, "Time given to the requests in progress and the background tasks to complete on shutdown")
+
+	fs.DurationVar(&cfg.session.lifetime, "session-lifetime", 12*time.Hour, "Maximum lifetime of a normal login session")
+	fs.DurationVar(&cfg.session.idleTimeout, "session-idle-timeout", 20*time.Minute, "Inactivity after which a normal login session expires")
+	fs.DurationVar(&cfg.session.rememberMeLifetime, "remember-me-lifetime", 30*24*time.Hour, "Lifetime of a login session when \"remember me\" is checked")
+
+	fs.StringVar(&cfg.webAuthn.rpID, "rp-id", "localhost", "WebAuthn relying party ID (domain of the application)")
+	fs.StringVar(&cfg.webAuthn.rpOrigin, "rp-origin", "https://localhost:8080", "WebAuthn origin the application is reached at")
+
+	fs.IntVar(&cfg.webhookWorkers, "webhook-workers", 2, "Number of webhook deliveries sent concurrently")
+
+	fs.StringVar(&cfg.trace.exporter, "trace-exporter", "none", "`exporter` of the trace spans: none, stdout or otlp")
+	fs.StringVar(&cfg.trace.endpoint, "otlp-endpoint", "localhost:4318", "OTLP/HTTP endpoint (host:port) of the collector the spans are exported to with -trace-exporter otlp")
+
+	return fs
+}
+
+// loadConfig returns the configuration of the application read from the
+// command line arguments, the environment variables found by lookupEnv and the
+// configuration file, and the flag set with the remaining arguments. The
+// configuration isn't validated.
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (*config, *flag.FlagSet, error) {
+	// Parse the command line once to find the configuration file and report
+	// the invalid flags.
+	var cmdLine config
+	err := newFlagSet(&cmdLine).Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	cfg := &config{}
+	fs := newFlagSet(cfg)
+
+	path := cmdLine.configFile
+	if path == "" {
+		path, _ = lookupEnv(configEnvPrefix + "CONFIG")
+	}
+	if path != "" {
+		err = loadConfigFile(fs, path)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || slices.Contains(commandLineOnly, f.Name) {
+			return
+		}
+
+		name := configEnvPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value, ok := lookupEnv(name); ok {
+			if setErr := f.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %w", value, name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The command line has the precedence over the other sources.
+	err = fs.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.configFile = path
+
+	return cfg, fs, nil
+}
+
+// loadConfigFile sets the flags with the settings of a JSON configuration
+// file. The values are strings, as on the command line, or numbers and
+// booleans.
+func loadConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read the configuration file: %w", err)
+	}
+
+	var settings map[string]any
+	err = json.Unmarshal(data, &settings)
+	if err != nil {
+		return fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+
+	// Set the flags in order, so the same error is reported every time.
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil || slices.Contains(commandLineOnly, name) {
+			return fmt.Errorf("invalid configuration file %s: unknown setting %q", path, name)
+		}
+
+		var value string
+		switch v := settings[name].(type) {
+		case string:
+			value = v
+		case float64, bool:
+			value = fmt.Sprint(v)
+		default:
+			return fmt.Errorf("invalid configuration file %s: %s must be a string, a number or a boolean", path, name)
+		}
+
+		err = f.Value.Set(value)
+		if err != nil {
+			return fmt.Errorf("invalid configuration file %s: invalid value %q for %s: %w", path, value, name, err)
+		}
+	}
+
+	return nil
+}
+
+// validate returns the problems of the configuration, joined in one error,
+// or nil if it is valid.
+func (cfg *config) validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(cfg.addr)
+	check(err == nil, "addr: %q isn't a network address such as :8080 or localhost:8080", cfg.addr)
+	if cfg.adminAddr != "" {
+		_, _, err = net.SplitHostPort(cfg.adminAddr)
+		check(err == nil, "admin-addr: %q isn't a network address such as localhost:8081", cfg.adminAddr)
+		check(cfg.adminAddr != cfg.addr, "admin-addr: must differ from addr (%s)", cfg.addr)
+	}
+	base, err := url.Parse(cfg.baseURL)
+	check(err == nil && (base.Scheme == "http" || base.Scheme == "https") && base.Host != "" &&
+		(base.Path == "" || base.Path == "/") && base.RawQuery == "" && base.Fragment == "",
+		"base-url: %q isn't a URL such as https://example.com", cfg.baseURL)
+	check(cfg.dsn != "", "dsn: must be provided")
+	check(cfg.logFormat == "text" || cfg.logFormat == "json", "log-format: must be text or json, got %q", cfg.logFormat)
+
+	check(cfg.tls.certFile != "", "tls-cert: must be provided")
+	check(cfg.tls.keyFile != "", "tls-key: must be provided")
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"query-timeout", cfg.queryTimeout},
+		{"idle-timeout", cfg.server.idleTimeout},
+		{"read-timeout", cfg.server.readTimeout},
+		{"write-timeout", cfg.server.writeTimeout},
+		{"shutdown-timeout", cfg.server.shutdownTimeout},
+		{"session-lifetime", cfg.session.lifetime},
+		{"session-idle-timeout", cfg.session.idleTimeout},
+		{"remember-me-lifetime", cfg.session.rememberMeLifetime},
+	}
+	for _, d := range durations {
+		check(d.value > 0, "%s: must be positive, got %s", d.name, d.value)
+	}
+
+	check(cfg.webAuthn.rpID != "", "rp-id: must be provided")
+	origin, err := url.Parse(cfg.webAuthn.rpOrigin)
+	if err != nil || origin.Scheme == "" || origin.Host == "" {
+		check(false, "rp-origin: %q isn't an origin such as https://example.com", cfg.webAuthn.rpOrigin)
+	} else {
+		host := origin.Hostname()
+		check(host == cfg.webAuthn.rpID || strings.HasSuffix(host, "."+cfg.webAuthn.rpID),
+			"rp-origin: the host of %s must be rp-id (%s) or one of its subdomains", cfg.webAuthn.rpOrigin, cfg.webAuthn.rpID)
+	}
+
+	check(cfg.webhookWorkers >= 0, "webhook-workers: can't be negative, got %d", cfg.webhookWorkers)
+
+	check(slices.Contains([]string{"none", "stdout", "otlp"}, cfg.trace.exporter), "trace-exporter: must be none, stdout or otlp, got %q", cfg.trace.exporter)
+	if cfg.trace.exporter == "otlp" {
+		_, _, err = net.SplitHostPort(cfg.trace.endpoint)
+		check(err == nil, "otlp-endpoint: %q isn't a network address such as localhost:4318", cfg.trace.endpoint)
+	}
+
+	return errors.Join(errs...)
+}
+
+// writeConfig writes the settings of the flag set to w as a JSON object,
+// which can be used as a configuration file. The secrets are redacted.
+func writeConfig(w io.Writer, fs *flag.FlagSet) error {
+	settings := map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		if slices.Contains(commandLineOnly, f.Name) {
+			return
+		}
+
+		value := f.Value.String()
+		if redact, ok := secretSettings[f.Name]; ok {
+			value = redact(value)
+		}
+		settings[f.Name] = value
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(settings)
+}
+
+// dsnPasswordRX matches the password of a DSN made of key=value pairs.
+var dsnPasswordRX = regexp.MustCompile(`(password=)('[^']*'|[^\s&]*)`)
+
+// redactDSN replaces the password of a DSN, whether it is an URL or made of
+// key=value pairs, with xxxxx.
+func redactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err == nil && u.User != nil {
+		return u.Redacted()
+	}
+
+	return dsnPasswordRX.ReplaceAllString(dsn, "${1}xxxxx")
+}
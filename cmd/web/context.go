@@ -1,6 +1,38 @@
 package main
 
+import "context"
+
 type contextKey string
 
-// isAuthenticatedContextKey is the key used to store the isAuthenticated flag.
-const isAuthenticatedContextKey = contextKey("isAuthenticated")
+// authenticatedUserContextKey is the key used to store the record of the
+// authenticated user.
+const authenticatedUserContextKey = contextKey("authenticatedUser")
+
+// apiTokenContextKey is the key used to store the API token a request has
+// been sent with.
+const apiTokenContextKey = contextKey("apiToken")
+
+// requestIDContextKey is the key used to store the ID of a request.
+const requestIDContextKey = contextKey("requestID")
+
+// routeContextKey is the key used to store the route pattern matching a
+// request.
+const routeContextKey = contextKey("route")
+
+// routeFromContext returns the route pattern matching the request of a
+// context, or "unmatched" if none does or the routeRequest middleware hasn't
+// resolved it.
+func routeFromContext(ctx context.Context) string {
+	route, ok := ctx.Value(routeContextKey).(string)
+	if !ok {
+		return "unmatched"
+	}
+	return route
+}
+
+// requestIDFromContext returns the ID of the request of a context, or an
+// empty string if the requestID middleware hasn't identified it.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
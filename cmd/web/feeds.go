@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// feed is the data of a feed of snippets, shared by its Atom and RSS
+// representations. Path is the path of the feed without its extension.
+type feed struct {
+	title    string
+	path     string
+	author   string
+	snippets []models.Snippet
+}
+
+// updated returns the time the feed was last updated: the time its last
+// edited snippet was updated, or the Unix epoch if it has none, so that an
+// empty feed doesn't change either.
+func (f feed) updated() time.Time {
+	updated := time.Unix(0, 0)
+	for _, s := range f.snippets {
+		if s.Updated.After(updated) {
+			updated = s.Updated
+		}
+	}
+	return updated.UTC()
+}
+
+// atomFeed is an Atom feed (RFC 4287).
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// atomText is a text construct. Its content is escaped by the encoder.
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string        `xml:"title"`
+	ID        string        `xml:"id"`
+	Updated   string        `xml:"updated"`
+	Published string        `xml:"published"`
+	Link      atomLink      `xml:"link"`
+	Category  *atomCategory `xml:"category"`
+	Content   atomText      `xml:"content"`
+}
+
+// rssFeed is an RSS 2.0 feed.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// rssItem is an item of an RSS feed. Its description is HTML, which the
+// encoder escapes once more as text.
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	Category    string  `xml:"category,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+// latestFeed is the handler of the feed of the latest valid snippets, the
+// ones shown on the homepage.
+// Method: GET
+func (app *application) latestFeed(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippets, err := app.snippets.Latest(ctx)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.serveFeed(w, r, feed{
+		title:    "Snippetbox: latest snippets",
+		path:     "/feed",
+		author:   "Snippetbox",
+		snippets: snippets,
+	})
+}
+
+// userFeed is the handler of the feed of the latest valid snippets of a user.
+// Method: GET
+func (app *application) userFeed(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, err := app.users.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	snippets, err := app.snippets.LatestBy(ctx, models.SnippetFilter{UserID: id})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.serveFeed(w, r, feed{
+		title:    "Snippetbox: latest snippets by " + user.Name,
+		path:     fmt.Sprintf("/users/%d/feed", id),
+		author:   user.Name,
+		snippets: snippets,
+	})
+}
+
+// tagFeed is the handler of the feed of the latest valid snippets with a tag.
+// The snippets are tagged with their language.
+// Method: GET
+func (app *application) tagFeed(w http.ResponseWriter, r *http.Request) {
+	tag := r.PathValue("tag")
+	if tag == "" || len(tag) > 30 || !languageRX.MatchString(tag) {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippets, err := app.snippets.LatestBy(ctx, models.SnippetFilter{Language: tag})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.serveFeed(w, r, feed{
+		title:    "Snippetbox: latest snippets tagged " + tag,
+		path:     "/tags/" + tag + "/feed",
+		author:   "Snippetbox",
+		snippets: snippets,
+	})
+}
+
+// serveFeed sends a feed as Atom or RSS, according to the extension of the
+// requested path. The feed is identified by an ETag computed from its
+// content, so feed readers making a conditional request get a 304 Not
+// Modified response until it changes. The links of the feed start with the
+// configured base URL, rather than the Host header of the request, so that
+// the feed and its ETag are the same for every client.
+func (app *application) serveFeed(w http.ResponseWriter, r *http.Request, f feed) {
+	var v any
+	contentType := "application/rss+xml; charset=utf-8"
+	if path.Ext(r.URL.Path) == ".atom" {
+		v = newAtomFeed(app.baseURL, f)
+		contentType = "application/atom+xml; charset=utf-8"
+	} else {
+		v = newRSSFeed(app.baseURL, f)
+	}
+
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	body = append([]byte(xml.Header), body...)
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// newAtomFeed returns the Atom representation of a feed, with absolute links
+// starting with base.
+func newAtomFeed(base string, f feed) atomFeed {
+	atom := atomFeed{
+		Title:   f.title,
+		ID:      base + f.path + ".atom",
+		Updated: f.updated().Format(time.RFC3339),
+		Author:  atomPerson{Name: f.author},
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: base + f.path + ".atom"},
+			{Rel: "alternate", Type: "text/html", Href: base + "/"},
+		},
+	}
+
+	for _, s := range f.snippets {
+		link := fmt.Sprintf("%s/snippet/view/%d/", base, s.ID)
+		entry := atomEntry{
+			Title:     s.Title,
+			ID:        link,
+			Updated:   s.Updated.UTC().Format(time.RFC3339),
+			Published: s.Created.UTC().Format(time.RFC3339),
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: link},
+			Content:   atomText{Type: "text", Text: s.Content},
+		}
+		if s.Language != "" {
+			entry.Category = &atomCategory{Term: s.Language}
+		}
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	return atom
+}
+
+// newRSSFeed returns the RSS representation of a feed, with absolute links
+// starting with base. The content of the snippets is escaped as HTML in a
+// preformatted block, as the feed readers render the descriptions as HTML.
+func newRSSFeed(base string, f feed) rssFeed {
+	rss := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.title,
+			Link:          base + "/",
+			Description:   f.title,
+			LastBuildDate: f.updated().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, s := range f.snippets {
+		link := fmt.Sprintf("%s/snippet/view/%d/", base, s.ID)
+		rss.Channel.Items = append(rss.Channel.Items, rssItem{
+			Title:       s.Title,
+			Link:        link,
+			Description: "<pre>" + html.EscapeString(s.Content) + "</pre>",
+			Category:    s.Language,
+			GUID:        rssGUID{IsPermaLink: true, Value: link},
+			PubDate:     s.Created.UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	return rss
+}
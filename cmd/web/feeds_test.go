@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+)
+
+func TestFeeds(t *testing.T) {
+	// Create a new test application config, with a snippet whose content must
+	// be escaped and a snippet tagged with its language.
+	app := newTestApplication(t)
+	_, err := app.snippets.Insert(context.Background(), 1, "Escaped", "if a < b && b > c {}", "", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = app.snippets.Insert(context.Background(), 2, "Tagged", "fmt.Println()", "Go", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a new test server.
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantType    string
+		wantTitle   string
+		wantBody    []string
+		wantMissing []string
+	}{
+		{
+			name:      "Atom",
+			urlPath:   "/feed.atom",
+			wantCode:  http.StatusOK,
+			wantType:  "application/atom+xml; charset=utf-8",
+			wantTitle: "Snippetbox: latest snippets",
+			wantBody: []string{
+				`<feed xmlns="http://www.w3.org/2005/Atom">`,
+				`<link rel="self" type="application/atom+xml" href="` + app.baseURL + `/feed.atom"></link>`,
+				`<content type="text">if a &lt; b &amp;&amp; b &gt; c {}</content>`,
+				`<category term="Go"></category>`,
+				"<title>An old silent pond</title>",
+			},
+		},
+		{
+			name:      "RSS",
+			urlPath:   "/feed.rss",
+			wantCode:  http.StatusOK,
+			wantType:  "application/rss+xml; charset=utf-8",
+			wantTitle: "Snippetbox: latest snippets",
+			wantBody: []string{
+				`<rss version="2.0">`,
+				"<description>&lt;pre&gt;if a &amp;lt; b &amp;amp;&amp;amp; b &amp;gt; c {}&lt;/pre&gt;</description>",
+				`<guid isPermaLink="true">` + app.baseURL + "/snippet/view/2/</guid>",
+			},
+		},
+		{
+			name:        "User",
+			urlPath:     "/users/2/feed.atom",
+			wantCode:    http.StatusOK,
+			wantType:    "application/atom+xml; charset=utf-8",
+			wantTitle:   "Snippetbox: latest snippets by Jane Doe",
+			wantBody:    []string{"<title>Tagged</title>"},
+			wantMissing: []string{"<title>Escaped</title>", "<title>An old silent pond</title>"},
+		},
+		{
+			name:        "Tag",
+			urlPath:     "/tags/go/feed.rss",
+			wantCode:    http.StatusOK,
+			wantType:    "application/rss+xml; charset=utf-8",
+			wantTitle:   "Snippetbox: latest snippets tagged go",
+			wantBody:    []string{"<title>Tagged</title>", "<category>Go</category>"},
+			wantMissing: []string{"<title>Escaped</title>"},
+		},
+		{
+			name:     "Unknown user",
+			urlPath:  "/users/99/feed.atom",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid tag",
+			urlPath:  "/tags/a%3Cb/feed.atom",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, headers.Get("Content-Type"), tt.wantType)
+
+			// The feed is well-formed XML with the expected title.
+			var doc struct {
+				Title   string `xml:"title"`
+				Channel struct {
+					Title string `xml:"title"`
+				} `xml:"channel"`
+			}
+			err := xml.Unmarshal([]byte(body), &doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, doc.Title+doc.Channel.Title, tt.wantTitle)
+
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+			for _, missing := range tt.wantMissing {
+				assert.Equal(t, strings.Contains(body, missing), false)
+			}
+		})
+	}
+
+	t.Run("Conditional GET", func(t *testing.T) {
+		_, headers, _ := ts.get(t, "/feed.atom")
+		etag := headers.Get("ETag")
+		assert.Equal(t, etag != "", true)
+
+		// conditionalGet sends a request with the If-None-Match header.
+		conditionalGet := func(t *testing.T, etag string) (int, string) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/feed.atom", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("If-None-Match", etag)
+
+			rs, err := ts.client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return rs.StatusCode, string(body)
+		}
+
+		code, body := conditionalGet(t, etag)
+		assert.Equal(t, code, http.StatusNotModified)
+		assert.Equal(t, body, "")
+
+		// A new snippet changes the feed.
+		_, err := app.snippets.Insert(context.Background(), 1, "Newer", "Content", "", 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, body = conditionalGet(t, etag)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<title>Newer</title>")
+	})
+}
@@ -3,18 +3,44 @@ package main
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/AlessioPani/go-snippetbox/internal/models"
 	"github.com/AlessioPani/go-snippetbox/internal/validator"
 )
 
 // snippetCreateForm is a struct that contains snippet data and errors to be sent back to the form.
+// It is decoded from the JSON body of the API requests too.
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
+	Title               string `form:"title" json:"title"`
+	Content             string `form:"content" json:"content"`
+	Language            string `form:"language" json:"language,omitempty"`
+	Expires             int    `form:"expires" json:"expires"`
+	validator.Validator `form:"-" json:"-"`
+}
+
+// languageRX matches the names of the programming languages of the snippets,
+// which are used as the info string of their Markdown code blocks. It matches
+// the empty string too, as the language is optional.
+var languageRX = regexp.MustCompile(`^[a-zA-Z0-9+#.\-]*$`)
+
+// validate checks the snippet data, recording the errors in the form.
+func (form *snippetCreateForm) validate() {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Language, 30), "language", "This field cannot be more than 30 characters long")
+	form.CheckField(validator.Matches(form.Language, languageRX), "language", "This field can only contain letters, digits and the characters + # . -")
+	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must be equal to 1, 7 or 365")
+}
+
+// snippetReportForm is a struct that contains the snippet report form data and errors.
+type snippetReportForm struct {
+	Reason              string `form:"reason"`
 	validator.Validator `form:"-"`
 }
 
@@ -30,13 +56,17 @@ type userSignupForm struct {
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	RememberMe          bool   `form:"rememberMe"`
 	validator.Validator `form:"-"`
 }
 
 // home is the homepage handler.
 // Method: GET
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippets, err := app.snippets.Latest(ctx)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -48,7 +78,8 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, http.StatusOK, "home.tmpl.html", data)
 }
 
-// snippetView is the handler used to view a specific snippet by its ID.
+// snippetView is the handler used to view a specific snippet by its ID. It
+// sends the snippet as HTML, JSON, plain text or Markdown, as negotiated.
 // Method: GET
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.PathValue("id"))
@@ -57,7 +88,10 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippet, err := app.snippets.Get(ctx, id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			http.NotFound(w, r)
@@ -67,10 +101,101 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := app.newTemplateData(r)
-	data.Snippet = snippet
+	app.negotiate(w, r,
+		representation{formatHTML, func() {
+			data := app.newTemplateData(r)
+			data.Snippet = snippet
+			data.Form = snippetReportForm{}
+
+			app.render(w, r, http.StatusOK, "view.tmpl.html", data)
+		}},
+		representation{formatJSON, func() {
+			app.writeJSON(w, r, http.StatusOK, apiSnippetResponse{Snippet: newAPISnippet(snippet)})
+		}},
+		representation{formatText, func() {
+			app.writeText(w, http.StatusOK, formatMediaTypes[formatText], snippet.Content)
+		}},
+		representation{formatMarkdown, func() {
+			app.writeText(w, http.StatusOK, formatMediaTypes[formatMarkdown], snippetMarkdown(snippet))
+		}},
+	)
+}
+
+// snippetMarkdown returns a Markdown document with the title of a snippet as
+// heading and its content as a code block, tagged with its language. The code fence is longer than any
+// run of backticks in the content, so it can't be closed early.
+func snippetMarkdown(s models.Snippet) string {
+	longest, run := 0, 0
+	for _, c := range s.Content {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
 
-	app.render(w, r, http.StatusOK, "view.tmpl.html", data)
+	return fmt.Sprintf("# %s\n\n%s%s\n%s\n%s\n", s.Title, fence, s.Language, strings.TrimRight(s.Content, "\n"), fence)
+}
+
+// snippetReportPost is the handler that reports a snippet to the moderators.
+// A user can report a snippet only once.
+// Method: POST
+func (app *application) snippetReportPost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippet, err := app.snippets.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	var form snippetReportForm
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Validate form data.
+	form.CheckField(validator.NotBlank(form.Reason), "reason", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Reason, 500), "reason", "This field cannot be more than 500 characters long")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "view.tmpl.html", data)
+		return
+	}
+
+	user, _ := app.authenticatedUser(r)
+	err = app.reports.Insert(id, user.ID, form.Reason)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateReport) {
+			app.sessionManager.Put(r.Context(), "flash", "You have already reported this snippet.")
+			http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d/", id), http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Thank you, the snippet has been reported to the moderators.")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d/", id), http.StatusSeeOther)
 }
 
 // snippetCreate is the handler that shows a form used to create a snippet.
@@ -102,10 +227,7 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	}
 
 	// Validate form data.
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must be equal to 1, 7 or 365")
+	form.validate()
 
 	// If errors, render back the createSnippet form with all the data put by the user and the errors.
 	if !form.Valid() {
@@ -115,13 +237,26 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Insert a snippet record into the db and check for errors.
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	// Insert a snippet record of the user into the db and check for errors.
+	user, _ := app.authenticatedUser(r)
+	id, err := app.snippets.Insert(ctx, user.ID, form.Title, form.Content, form.Language, form.Expires)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	// Notify the webhooks of the user.
+	snippet, err := app.snippets.Get(ctx, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.snippetEvent(models.EventSnippetCreated, snippet)
+	app.metrics.snippetsCreated.Inc()
+
 	// Add a confirmation message in session data.
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
@@ -163,26 +298,21 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the mail in input already exists.
-	// With sqlite we need to check separately in this way; mySql for instance
-	// does have specific error codes.
-	exists, err := app.users.EmailTaken(form.Email)
-	if err != nil {
-		app.serverError(w, r, err)
-		return
-	}
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
 
-	if exists {
-		form.AddFieldError("email", "Email address is already in use")
-		data := app.newTemplateData(r)
-		data.Form = form
-		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
-	}
-
-	// Try to create a new user record in the database.
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	// Try to create a new user record in the database. If the email address
+	// is already in use, add an error message to the form and re-display it.
+	err = app.users.Insert(ctx, form.Name, form.Email, form.Password)
 	if err != nil {
-		app.serverError(w, r, err)
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			form.AddFieldError("email", "Email address is already in use")
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+		} else {
+			app.serverError(w, r, err)
+		}
 		return
 	}
 
@@ -226,9 +356,32 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check whether the credentials are valid. If they're not, add a generic
-	// non-field error message and re-display the login page.
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	// Record the attempt, and refuse it without checking the password if too
+	// many failed attempts have been made recently for this email or from
+	// this IP. The message is the same whether the email belongs to a user or
+	// not.
+	wait, err := app.loginThrottle.attempt(form.Email, clientIP(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if wait > 0 {
+		form.AddNonFieldError("Too many failed login attempts. Please try again later")
+		data := app.newTemplateData(r)
+		data.Form = form
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		app.render(w, r, http.StatusTooManyRequests, "login.tmpl.html", data)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	// Check whether the credentials are valid. If they're not, the attempt
+	// stays recorded as a failure: add a generic non-field error message and
+	// re-display the login page.
+	id, err := app.users.Authenticate(ctx, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddNonFieldError("Email or password is incorrect")
@@ -241,16 +394,39 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Use the RenewToken() method on the current session to change the session
-	// ID. It's good practice to generate a new session ID when the
-	// authentication state or privilege levels changes for the user (e.g. login
-	// and logout operations).
-	err = app.sessionManager.RenewToken(r.Context())
+	err = app.loginThrottle.succeed(form.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Users who registered a passkey must use it as a second factor.
+	credentials, err := app.credentials.GetByUser(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The user is not authenticated until the passkey has been verified, so
+	// only their ID is stored as pending, in a renewed session.
+	if len(credentials) > 0 {
+		err = app.sessionManager.RenewToken(r.Context())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.sessionManager.Put(r.Context(), "passkeyPendingUserID", id)
+		app.sessionManager.Put(r.Context(), "passkeyPendingRememberMe", form.RememberMe)
+		http.Redirect(w, r, "/user/login/passkey", http.StatusSeeOther)
+		return
+	}
+
+	err = app.logIn(r, id, form.RememberMe)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
-	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
 
 	// Redirect the user to the create snippet page.
 	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
@@ -259,15 +435,21 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 // userLogoutPost is the handler that log out the user.
 // Method: POST
 func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
-	// Use the RenewToken() method on the current session to change the session ID again.
-	err := app.sessionManager.RenewToken(r.Context())
-	if err != nil {
+	// Revoke the record of the logged in session.
+	user, _ := app.authenticatedUser(r)
+	err := app.sessions.Delete(app.sessionManager.GetString(r.Context(), "sessionID"), user.ID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
 		app.serverError(w, r, err)
 		return
 	}
 
-	// Remove the authenticatedUserID from the session data so that the user is 'logged out'.
-	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	// Renew the session ID again and remove the authentication data from the
+	// session so that the user is 'logged out'.
+	err = app.logOut(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	// Add a flash message to the session to confirm to the user that they've been logged out.
 	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/AlessioPani/go-snippetbox/internal/validator"
+)
+
+// accountPasswordUpdateForm is a struct that contains the password change form data and errors.
+type accountPasswordUpdateForm struct {
+	CurrentPassword         string `form:"currentPassword"`
+	NewPassword             string `form:"newPassword"`
+	NewPasswordConfirmation string `form:"newPasswordConfirmation"`
+	validator.Validator     `form:"-"`
+}
+
+// accountView is the handler that shows the account of the user together with
+// the sessions they are logged in with.
+// Method: GET
+func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
+	user, _ := app.authenticatedUser(r)
+
+	sessions, err := app.sessions.GetByUser(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Sessions = sessions
+	data.CurrentSessionID = app.sessionManager.GetString(r.Context(), "sessionID")
+
+	app.render(w, r, http.StatusOK, "account.tmpl.html", data)
+}
+
+// accountSessionRevokePost is the handler that signs out one of the sessions of
+// the user. The device using it is logged out on its next request.
+// Method: POST
+func (app *application) accountSessionRevokePost(w http.ResponseWriter, r *http.Request) {
+	user, _ := app.authenticatedUser(r)
+	sessionID := r.PathValue("id")
+
+	err := app.sessions.Delete(sessionID, user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Revoking the current session is the same as logging out.
+	if sessionID == app.sessionManager.GetString(r.Context(), "sessionID") {
+		err = app.logOut(r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The session has been signed out.")
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+// accountSessionRevokeAllPost is the handler that signs out every session of
+// the user, including the current one.
+// Method: POST
+func (app *application) accountSessionRevokeAllPost(w http.ResponseWriter, r *http.Request) {
+	user, _ := app.authenticatedUser(r)
+
+	err := app.sessions.DeleteByUser(user.ID, "")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.logOut(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "You've been signed out everywhere.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// accountPasswordUpdate is the handler that shows a form used to change the password.
+// Method: GET
+func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountPasswordUpdateForm{}
+	app.render(w, r, http.StatusOK, "password.tmpl.html", data)
+}
+
+// accountPasswordUpdatePost is the handler that changes the password of the
+// user. Every other session of the user is signed out.
+// Method: POST
+func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountPasswordUpdateForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Validate form data.
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.NewPasswordConfirmation), "newPasswordConfirmation", "This field cannot be blank")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "newPasswordConfirmation", "Passwords do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl.html", data)
+		return
+	}
+
+	user, _ := app.authenticatedUser(r)
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err = app.users.PasswordUpdate(ctx, user.ID, form.CurrentPassword, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("currentPassword", "Current password is incorrect")
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl.html", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Sign out every other session, as the old password may have been used
+	// by someone else.
+	err = app.sessions.DeleteByUser(user.ID, app.sessionManager.GetString(r.Context(), "sessionID"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The privileges of the current session change too, so renew its token.
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated. Your other sessions have been signed out.")
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
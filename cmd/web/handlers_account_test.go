@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+)
+
+// revokeFormRX captures the IDs of the sessions which can be revoked from the
+// account page.
+var revokeFormRX = regexp.MustCompile(`<form action='/account/sessions/revoke/([0-9a-f]+)'`)
+
+func TestAccountSessions(t *testing.T) {
+	// Create a new test application config.
+	app := newTestApplication(t)
+
+	// Create a new test server, with a client for each device.
+	laptop := newTestServer(t, app.routes())
+	defer laptop.Close()
+	phone := laptop.newClient(t)
+
+	// login logs the user in from a device.
+	login := func(t *testing.T, ts *testServer) {
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", "test@test.com")
+		form.Add("password", "password")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, _, _ := ts.postForm(t, "/user/login", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+
+	// post submits a form of the account page from a device.
+	post := func(t *testing.T, ts *testServer, urlPath string, form url.Values) (int, http.Header) {
+		_, _, body := ts.get(t, "/account")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, headers, _ := ts.postForm(t, urlPath, form)
+		return code, headers
+	}
+
+	// isLoggedIn checks whether a device can reach a protected page.
+	isLoggedIn := func(t *testing.T, ts *testServer) bool {
+		code, _, _ := ts.get(t, "/snippet/create")
+		return code == http.StatusOK
+	}
+
+	t.Run("Revoke a session", func(t *testing.T) {
+		login(t, laptop)
+		login(t, phone)
+
+		// Only the other session can be revoked from the list.
+		_, _, body := laptop.get(t, "/account")
+		assert.StringContains(t, body, "This session")
+		matches := revokeFormRX.FindAllStringSubmatch(body, -1)
+		assert.Equal(t, len(matches), 1)
+
+		code, headers := post(t, laptop, "/account/sessions/revoke/"+matches[0][1], url.Values{})
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/account")
+
+		assert.Equal(t, isLoggedIn(t, laptop), true)
+		assert.Equal(t, isLoggedIn(t, phone), false)
+	})
+
+	t.Run("Revoke an unknown session", func(t *testing.T) {
+		code, _ := post(t, laptop, "/account/sessions/revoke/unknown", url.Values{})
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Password change", func(t *testing.T) {
+		login(t, phone)
+
+		form := url.Values{}
+		form.Add("currentPassword", "password")
+		form.Add("newPassword", "newPassword")
+		form.Add("newPasswordConfirmation", "newPassword")
+		code, headers := post(t, laptop, "/account/password", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/account")
+
+		assert.Equal(t, isLoggedIn(t, laptop), true)
+		assert.Equal(t, isLoggedIn(t, phone), false)
+	})
+
+	t.Run("Wrong current password", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("currentPassword", "wrongPassword")
+		form.Add("newPassword", "newPassword")
+		form.Add("newPasswordConfirmation", "newPassword")
+		code, _ := post(t, laptop, "/account/password", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Sign out everywhere", func(t *testing.T) {
+		login(t, phone)
+
+		code, headers := post(t, laptop, "/account/sessions/revoke-all", url.Values{})
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/")
+
+		assert.Equal(t, isLoggedIn(t, laptop), false)
+		assert.Equal(t, isLoggedIn(t, phone), false)
+	})
+}
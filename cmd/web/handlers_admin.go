@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/AlessioPani/go-snippetbox/internal/validator"
+)
+
+// signupsDays is the number of days the signups are counted for on the admin
+// dashboard.
+const signupsDays = 30
+
+// adminUserFilterForm is a struct that contains the user search form data.
+type adminUserFilterForm struct {
+	Search string `form:"q"`
+}
+
+// adminSnippetFilterForm is a struct that contains the snippet filter form data and errors.
+type adminSnippetFilterForm struct {
+	Search              string `form:"q"`
+	UserID              int    `form:"user"`
+	Status              string `form:"status"`
+	validator.Validator `form:"-"`
+}
+
+// adminRoleForm is a struct that contains the role change form data.
+type adminRoleForm struct {
+	Role models.Role `form:"role"`
+}
+
+// admin is the handler that shows the admin dashboard, with the basic counts
+// about the use of the application.
+// Method: GET
+func (app *application) admin(w http.ResponseWriter, r *http.Request) {
+	stats, err := app.stats.Get(signupsDays)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Stats = stats
+	app.render(w, r, http.StatusOK, "admin.tmpl.html", data)
+}
+
+// adminUsers is the handler that lists the users, optionally searching them by
+// name or email address.
+// Method: GET
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	var form adminUserFilterForm
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	users, err := app.users.List(ctx, form.Search)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Users = users
+	data.Roles = models.Roles
+	app.render(w, r, http.StatusOK, "admin_users.tmpl.html", data)
+}
+
+// adminUserSuspendPost is the handler that suspends a user. A suspended user
+// is logged out on their next request and can't log in anymore.
+// Method: POST
+func (app *application) adminUserSuspendPost(w http.ResponseWriter, r *http.Request) {
+	app.adminUserUpdate(w, r, models.AuditSuspendUser, "", "The user has been suspended.", func(ctx context.Context, entry models.AuditEntry) error {
+		return app.actions.SetSuspended(ctx, entry, true)
+	})
+}
+
+// adminUserUnsuspendPost is the handler that reinstates a suspended user.
+// Method: POST
+func (app *application) adminUserUnsuspendPost(w http.ResponseWriter, r *http.Request) {
+	app.adminUserUpdate(w, r, models.AuditUnsuspendUser, "", "The user has been reinstated.", func(ctx context.Context, entry models.AuditEntry) error {
+		return app.actions.SetSuspended(ctx, entry, false)
+	})
+}
+
+// adminUserRolePost is the handler that changes the role of a user.
+// Method: POST
+func (app *application) adminUserRolePost(w http.ResponseWriter, r *http.Request) {
+	var form adminRoleForm
+	err := app.decodePostForm(r, &form)
+	if err != nil || !validator.PermittedValue(form.Role, models.Roles...) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	app.adminUserUpdate(w, r, models.AuditChangeRole, string(form.Role), "The role of the user has been changed.", func(ctx context.Context, entry models.AuditEntry) error {
+		return app.actions.SetRole(ctx, entry, form.Role)
+	})
+}
+
+// adminUserUpdate applies an update to the user whose ID is in the request
+// path, which records it in the audit trail as the entry of action with
+// details, and redirects back to the user list with a flash message. Admins
+// can't update themselves, so they can't lose access to the admin area.
+func (app *application) adminUserUpdate(w http.ResponseWriter, r *http.Request, action, details, flash string, update func(ctx context.Context, entry models.AuditEntry) error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	admin, _ := app.authenticatedUser(r)
+	if id == admin.ID {
+		app.sessionManager.Put(r.Context(), "flash", "You can't suspend yourself or change your own role.")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err = update(ctx, models.AuditEntry{ActorID: admin.ID, Action: action, UserID: id, Details: details})
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", flash)
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// adminSnippets is the handler that lists the snippets of every user,
+// including the expired ones, optionally filtered.
+// Method: GET
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	var form adminSnippetFilterForm
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.PermittedValue(form.Status, "", models.SnippetStatusLive, models.SnippetStatusExpired), "status", "This field must be equal to live or expired")
+	form.CheckField(form.UserID >= 0, "user", "This field must be a user ID")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "admin_snippets.tmpl.html", data)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippets, err := app.snippets.List(ctx, models.SnippetFilter{
+		Search: form.Search,
+		UserID: form.UserID,
+		Status: form.Status,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Snippets = snippets
+	app.render(w, r, http.StatusOK, "admin_snippets.tmpl.html", data)
+}
+
+// adminSnippetDeletePost is the handler that deletes a snippet before it expires.
+// Method: POST
+func (app *application) adminSnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	_, err = app.deleteSnippet(r, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The snippet has been deleted.")
+
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
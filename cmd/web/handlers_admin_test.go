@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+)
+
+func TestAdmin(t *testing.T) {
+	// Create a new test application config.
+	app := newTestApplication(t)
+
+	// Create a new test server, with a client for the admin and one for a
+	// user.
+	admin := newTestServer(t, app.routes())
+	defer admin.Close()
+	user := admin.newClient(t)
+
+	// login logs a user in from a client.
+	login := func(t *testing.T, ts *testServer, email string) {
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("password", "password")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, _, _ := ts.postForm(t, "/user/login", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+
+	// post submits a form of the admin area as the admin.
+	post := func(t *testing.T, urlPath string, form url.Values) (int, http.Header) {
+		_, _, body := admin.get(t, "/admin/users")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, headers, _ := admin.postForm(t, urlPath, form)
+		return code, headers
+	}
+
+	t.Run("Access", func(t *testing.T) {
+		anonymous := admin.newClient(t)
+		login(t, admin, "admin@test.com")
+		login(t, user, "test@test.com")
+
+		for _, urlPath := range []string{"/admin", "/admin/users", "/admin/snippets"} {
+			code, headers, _ := anonymous.get(t, urlPath)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), "/user/login")
+
+			code, _, _ = user.get(t, urlPath)
+			assert.Equal(t, code, http.StatusForbidden)
+
+			code, _, _ = admin.get(t, urlPath)
+			assert.Equal(t, code, http.StatusOK)
+		}
+	})
+
+	t.Run("Dashboard", func(t *testing.T) {
+		_, _, body := admin.get(t, "/admin")
+		assert.StringContains(t, body, "<th>Users</th>")
+		assert.StringContains(t, body, "2024-12-01")
+	})
+
+	t.Run("Snippet filters", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			query    string
+			wantCode int
+			wantBody string
+		}{
+			{
+				name:     "No filter",
+				query:    "",
+				wantCode: http.StatusOK,
+				wantBody: "An old silent pond",
+			},
+			{
+				name:     "Empty filters",
+				query:    "?q=&user=&status=",
+				wantCode: http.StatusOK,
+				wantBody: "An old silent pond",
+			},
+			{
+				name:     "Every filter",
+				query:    "?q=pond&user=1&status=live",
+				wantCode: http.StatusOK,
+				wantBody: "An old silent pond",
+			},
+			{
+				name:     "Invalid status",
+				query:    "?status=deleted",
+				wantCode: http.StatusUnprocessableEntity,
+				wantBody: "This field must be equal to live or expired",
+			},
+			{
+				name:     "Invalid user",
+				query:    "?user=john",
+				wantCode: http.StatusBadRequest,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				code, _, body := admin.get(t, "/admin/snippets"+tt.query)
+				assert.Equal(t, code, tt.wantCode)
+
+				if tt.wantBody != "" {
+					assert.StringContains(t, body, tt.wantBody)
+				}
+			})
+		}
+	})
+
+	t.Run("Delete a snippet", func(t *testing.T) {
+		code, headers := post(t, "/admin/snippets/delete/1", url.Values{})
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/admin/snippets")
+
+		code, _ = post(t, "/admin/snippets/delete/99", url.Values{})
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Change a role", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("role", "superuser")
+		code, _ := post(t, "/admin/users/role/1", form)
+		assert.Equal(t, code, http.StatusBadRequest)
+
+		form = url.Values{}
+		form.Add("role", "moderator")
+		code, headers := post(t, "/admin/users/role/1", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/admin/users")
+
+		u, err := app.users.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, u.Role, "moderator")
+	})
+
+	t.Run("Update yourself", func(t *testing.T) {
+		code, _ := post(t, "/admin/users/suspend/2", url.Values{})
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		_, _, body := admin.get(t, "/admin/users")
+		assert.StringContains(t, body, "You can&#39;t suspend yourself or change your own role.")
+
+		code, _, _ = admin.get(t, "/admin")
+		assert.Equal(t, code, http.StatusOK)
+	})
+
+	t.Run("Suspend a user", func(t *testing.T) {
+		code, _, _ := user.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusOK)
+
+		code, _ = post(t, "/admin/users/suspend/1", url.Values{})
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		// The user is logged out on their next request.
+		code, headers, _ := user.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+
+		_, _, body := user.get(t, "/user/login")
+		assert.StringContains(t, body, "Your account has been suspended.")
+
+		// Once reinstated, the user can log in again.
+		code, _ = post(t, "/admin/users/unsuspend/1", url.Values{})
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		login(t, user, "test@test.com")
+		code, _, _ = user.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusOK)
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		code, _ := post(t, "/admin/users/suspend/99", url.Values{})
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/AlessioPani/go-snippetbox/internal/validator"
+)
+
+// Number of snippets in a page of the API list, by default and at most.
+const (
+	apiDefaultPageSize = 20
+	apiMaxPageSize     = 100
+)
+
+// apiSnippet is the JSON representation of a snippet. UserID and Language are
+// omitted for the snippets without an author and a language.
+type apiSnippet struct {
+	ID       int       `json:"id"`
+	UserID   int       `json:"user_id,omitempty"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	Language string    `json:"language,omitempty"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"`
+}
+
+// newAPISnippet returns the JSON representation of a snippet.
+func newAPISnippet(s models.Snippet) apiSnippet {
+	return apiSnippet{
+		ID:       s.ID,
+		UserID:   s.UserID,
+		Title:    s.Title,
+		Content:  s.Content,
+		Language: s.Language,
+		Created:  s.Created,
+		Expires:  s.Expires,
+	}
+}
+
+// apiPagination is the JSON representation of the position of a page in the
+// snippets list.
+type apiPagination struct {
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+	Total    int `json:"total"`
+	LastPage int `json:"last_page"`
+}
+
+// apiSnippetResponse is the body of the API responses returning a snippet.
+type apiSnippetResponse struct {
+	Snippet apiSnippet `json:"snippet"`
+}
+
+// apiSnippetListResponse is the body of the API response listing snippets.
+type apiSnippetListResponse struct {
+	Snippets []apiSnippet  `json:"snippets"`
+	Metadata apiPagination `json:"metadata"`
+}
+
+// apiSnippetListForm is a struct that contains the pagination query of the
+// snippets list and its errors.
+type apiSnippetListForm struct {
+	Page                int `form:"page"`
+	PageSize            int `form:"page_size"`
+	validator.Validator `form:"-"`
+}
+
+// apiSnippetList is the API handler that lists the valid snippets, newest
+// first, a page at a time.
+// Method: GET
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	form := apiSnippetListForm{Page: 1, PageSize: apiDefaultPageSize}
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.apiClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(form.Page >= 1, "page", "This field must be greater than zero")
+	form.CheckField(form.PageSize >= 1 && form.PageSize <= apiMaxPageSize, "page_size", fmt.Sprintf("This field must be between 1 and %d", apiMaxPageSize))
+
+	if !form.Valid() {
+		app.apiFailedValidation(w, r, form.Validator)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippets, total, err := app.snippets.Page(ctx, form.Page, form.PageSize)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	list := []apiSnippet{}
+	for _, s := range snippets {
+		list = append(list, newAPISnippet(s))
+	}
+
+	app.writeJSON(w, r, http.StatusOK, apiSnippetListResponse{
+		Snippets: list,
+		Metadata: apiPagination{
+			Page:     form.Page,
+			PageSize: form.PageSize,
+			Total:    total,
+			LastPage: max(1, (total+form.PageSize-1)/form.PageSize),
+		},
+	})
+}
+
+// apiSnippetView is the API handler that returns a valid snippet.
+// Method: GET
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, apiSnippetResponse{Snippet: newAPISnippet(snippet)})
+}
+
+// apiSnippetCreate is the API handler that creates a snippet of the user.
+// Method: POST
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var form snippetCreateForm
+	err := app.decodeJSON(w, r, &form)
+	if err != nil {
+		app.apiBadRequest(w, r, err)
+		return
+	}
+
+	form.validate()
+	if !form.Valid() {
+		app.apiFailedValidation(w, r, form.Validator)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	user, _ := app.authenticatedUser(r)
+	id, err := app.snippets.Insert(ctx, user.ID, form.Title, form.Content, form.Language, form.Expires)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	snippet, err := app.snippets.Get(ctx, id)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.snippetEvent(models.EventSnippetCreated, snippet)
+	app.metrics.snippetsCreated.Inc()
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+	app.writeJSON(w, r, http.StatusCreated, apiSnippetResponse{Snippet: newAPISnippet(snippet)})
+}
+
+// apiSnippetUpdate is the API handler that replaces the title and the content
+// of a snippet of the user, which then expires the given number of days from
+// now.
+// Method: PUT
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetCreateForm
+	err := app.decodeJSON(w, r, &form)
+	if err != nil {
+		app.apiBadRequest(w, r, err)
+		return
+	}
+
+	form.validate()
+	if !form.Valid() {
+		app.apiFailedValidation(w, r, form.Validator)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err = app.snippets.Update(ctx, snippet.ID, form.Title, form.Content, form.Language, form.Expires)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	snippet, err = app.snippets.Get(ctx, snippet.ID)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.snippetEvent(models.EventSnippetUpdated, snippet)
+
+	app.writeJSON(w, r, http.StatusOK, apiSnippetResponse{Snippet: newAPISnippet(snippet)})
+}
+
+// apiSnippetDelete is the API handler that deletes a snippet of the user.
+// Method: DELETE
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	err := app.snippets.Delete(ctx, snippet.ID)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.snippetEvent(models.EventSnippetDeleted, snippet)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiNotFound is the API handler of the paths which don't exist.
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	app.apiClientError(w, r, http.StatusNotFound)
+}
+
+// apiSnippet returns the valid snippet whose ID is in the request path. If
+// there is no such snippet, it sends back an error and returns false.
+func (app *application) apiSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.apiClientError(w, r, http.StatusNotFound)
+		return models.Snippet{}, false
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	snippet, err := app.snippets.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiClientError(w, r, http.StatusNotFound)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// apiOwnSnippet is like apiSnippet, but only returns the snippets written by
+// the user. The others get a 403 Forbidden response.
+func (app *application) apiOwnSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := app.apiSnippet(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	user, _ := app.authenticatedUser(r)
+	if snippet.UserID != user.ID {
+		app.apiClientError(w, r, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func TestAPISnippets(t *testing.T) {
+	// Create a new test application config.
+	app := newTestApplication(t)
+
+	// Create a new test server.
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// newToken creates an API token of a user with the scopes.
+	newToken := func(t *testing.T, userID int, scopes ...string) string {
+		token, err := app.tokens.Insert(userID, "Test", scopes, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	readToken := newToken(t, 1, models.ScopeSnippetsRead)
+	writeToken := newToken(t, 1, models.ScopeSnippetsRead, models.ScopeSnippetsWrite)
+	otherToken := newToken(t, 2, models.ScopeSnippetsRead, models.ScopeSnippetsWrite)
+
+	t.Run("Authentication", func(t *testing.T) {
+		tests := []struct {
+			name          string
+			method        string
+			urlPath       string
+			token         string
+			wantCode      int
+			wantChallenge string
+		}{
+			{"No token", http.MethodGet, "/api/v1/snippets", "", http.StatusUnauthorized, "Bearer"},
+			{"Invalid token", http.MethodGet, "/api/v1/snippets", "sbx_invalid", http.StatusUnauthorized, `Bearer error="invalid_token"`},
+			{"Insufficient scope", http.MethodPost, "/api/v1/snippets", readToken, http.StatusForbidden, `Bearer error="insufficient_scope", scope="snippets:write"`},
+			{"Unknown path", http.MethodGet, "/api/v1/users", readToken, http.StatusNotFound, ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				code, headers, body := ts.apiRequest(t, tt.method, tt.urlPath, tt.token, "")
+				assert.Equal(t, code, tt.wantCode)
+				assert.Equal(t, headers.Get("Content-Type"), "application/json")
+				assert.Equal(t, headers.Get("WWW-Authenticate"), tt.wantChallenge)
+				assert.Equal(t, body, `{"error":"`+http.StatusText(tt.wantCode)+`"}`)
+			})
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		code, _, body := ts.apiRequest(t, http.MethodGet, "/api/v1/snippets/1", readToken, "")
+		assert.Equal(t, code, http.StatusOK)
+
+		var rs struct {
+			Snippet apiSnippet `json:"snippet"`
+		}
+		err := json.Unmarshal([]byte(body), &rs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, rs.Snippet.ID, 1)
+		assert.Equal(t, rs.Snippet.Title, "An old silent pond")
+
+		code, _, _ = ts.apiRequest(t, http.MethodGet, "/api/v1/snippets/99", readToken, "")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Create with invalid data", func(t *testing.T) {
+		code, _, body := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", writeToken, `{"title": "", "content": "Hello", "expires": 2}`)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+		var rs apiErrorResponse
+		err := json.Unmarshal([]byte(body), &rs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, rs.Error, "Unprocessable Entity")
+		assert.Equal(t, len(rs.Fields), 2)
+		assert.Equal(t, rs.Fields["title"], "This field cannot be blank")
+		assert.Equal(t, rs.Fields["expires"], "This field must be equal to 1, 7 or 365")
+	})
+
+	t.Run("Create with invalid body", func(t *testing.T) {
+		code, _, _ := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", writeToken, `{"title": `)
+		assert.Equal(t, code, http.StatusBadRequest)
+
+		large := `{"title": "Large", "content": "` + strings.Repeat("a", maxJSONBytes) + `", "expires": 7}`
+		code, _, _ = ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", writeToken, large)
+		assert.Equal(t, code, http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("Create, update and delete", func(t *testing.T) {
+		code, headers, body := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", writeToken, `{"title": "Haiku", "content": "A frog jumps in", "expires": 7}`)
+		assert.Equal(t, code, http.StatusCreated)
+		assert.Equal(t, headers.Get("Location"), "/api/v1/snippets/2")
+		assert.StringContains(t, body, `"user_id":1`)
+
+		// Only the author can update or delete the snippet.
+		code, _, _ = ts.apiRequest(t, http.MethodPut, "/api/v1/snippets/2", otherToken, `{"title": "Mine", "content": "Mine", "expires": 1}`)
+		assert.Equal(t, code, http.StatusForbidden)
+		code, _, _ = ts.apiRequest(t, http.MethodDelete, "/api/v1/snippets/2", otherToken, "")
+		assert.Equal(t, code, http.StatusForbidden)
+
+		code, _, body = ts.apiRequest(t, http.MethodPut, "/api/v1/snippets/2", writeToken, `{"title": "Haiku", "content": "The sound of water", "expires": 365}`)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `"content":"The sound of water"`)
+
+		code, _, body = ts.apiRequest(t, http.MethodDelete, "/api/v1/snippets/2", writeToken, "")
+		assert.Equal(t, code, http.StatusNoContent)
+		assert.Equal(t, body, "")
+
+		code, _, _ = ts.apiRequest(t, http.MethodGet, "/api/v1/snippets/2", readToken, "")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		for range 2 {
+			code, _, _ := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", writeToken, `{"title": "Haiku", "content": "Autumn moonlight", "expires": 1}`)
+			assert.Equal(t, code, http.StatusCreated)
+		}
+
+		tests := []struct {
+			name         string
+			query        string
+			wantCode     int
+			wantIDs      []int
+			wantLastPage int
+		}{
+			{"Default", "", http.StatusOK, []int{4, 3, 1}, 1},
+			{"First page", "?page_size=2", http.StatusOK, []int{4, 3}, 2},
+			{"Last page", "?page=2&page_size=2", http.StatusOK, []int{1}, 2},
+			{"After the last page", "?page=3&page_size=2", http.StatusOK, []int{}, 2},
+			{"Invalid page", "?page=0", http.StatusUnprocessableEntity, nil, 0},
+			{"Invalid page size", "?page_size=1000", http.StatusUnprocessableEntity, nil, 0},
+			{"Malformed page", "?page=first", http.StatusBadRequest, nil, 0},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				code, _, body := ts.apiRequest(t, http.MethodGet, "/api/v1/snippets"+tt.query, readToken, "")
+				assert.Equal(t, code, tt.wantCode)
+				if code != http.StatusOK {
+					return
+				}
+
+				var rs struct {
+					Snippets []apiSnippet  `json:"snippets"`
+					Metadata apiPagination `json:"metadata"`
+				}
+				err := json.Unmarshal([]byte(body), &rs)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				ids := []int{}
+				for _, s := range rs.Snippets {
+					ids = append(ids, s.ID)
+				}
+				assert.Equal(t, fmt.Sprint(ids), fmt.Sprint(tt.wantIDs))
+				assert.Equal(t, rs.Metadata.Total, 3)
+				assert.Equal(t, rs.Metadata.LastPage, tt.wantLastPage)
+			})
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// auditEntries is the number of entries of the audit trail shown in the
+// moderation queue.
+const auditEntries = 50
+
+// moderation is the handler that shows the moderation queue: the snippets
+// with open reports, together with the latest moderation actions.
+// Method: GET
+func (app *application) moderation(w http.ResponseWriter, r *http.Request) {
+	reported, err := app.reports.Open()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	entries, err := app.audit.Latest(auditEntries)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.ReportedSnippets = reported
+	data.AuditEntries = entries
+	app.render(w, r, http.StatusOK, "moderation.tmpl.html", data)
+}
+
+// moderationDismissPost is the handler that dismisses the reports of a
+// snippet, making it visible again if it was hidden.
+// Method: POST
+func (app *application) moderationDismissPost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	moderator, _ := app.authenticatedUser(r)
+	err = app.actions.DismissReports(ctx, models.AuditEntry{ActorID: moderator.ID, Action: models.AuditDismissReports, SnippetID: id})
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The reports have been dismissed.")
+
+	http.Redirect(w, r, "/moderation", http.StatusSeeOther)
+}
+
+// moderationHidePost is the handler that hides a reported snippet until its
+// reports are dismissed or it is deleted.
+// Method: POST
+func (app *application) moderationHidePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	moderator, _ := app.authenticatedUser(r)
+	err = app.actions.HideSnippet(ctx, models.AuditEntry{ActorID: moderator.ID, Action: models.AuditHideSnippet, SnippetID: id})
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The snippet has been hidden pending review.")
+
+	http.Redirect(w, r, "/moderation", http.StatusSeeOther)
+}
+
+// moderationDeletePost is the handler that deletes a reported snippet,
+// together with its reports, and suspends its author. Members of staff are
+// never suspended by moderators.
+// Method: POST
+func (app *application) moderationDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	snippet, err := app.deleteSnippet(r, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	flash := "The snippet has been deleted."
+
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	author, err := app.users.Get(ctx, snippet.UserID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	switch {
+	case err != nil:
+		// The author is unknown or doesn't exist anymore.
+	case author.HasRole(models.RoleModerator, models.RoleAdmin):
+		flash = "The snippet has been deleted. Its author is a member of staff and hasn't been suspended."
+	default:
+		moderator, _ := app.authenticatedUser(r)
+		err = app.actions.SetSuspended(ctx, models.AuditEntry{
+			ActorID:   moderator.ID,
+			Action:    models.AuditSuspendUser,
+			SnippetID: id,
+			UserID:    author.ID,
+			Details:   fmt.Sprintf("Author of reported snippet #%d", id),
+		}, true)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		flash = "The snippet has been deleted and its author suspended."
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", flash)
+
+	http.Redirect(w, r, "/moderation", http.StatusSeeOther)
+}
+
+// deleteSnippet deletes a snippet, even if it is hidden or expired, and
+// records it in the audit trail with its title. It returns the deleted
+// snippet, or ErrNoRecord if no such snippet exists.
+func (app *application) deleteSnippet(r *http.Request, id int) (models.Snippet, error) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	actor, _ := app.authenticatedUser(r)
+	snippet, err := app.actions.DeleteSnippet(ctx, models.AuditEntry{ActorID: actor.ID, Action: models.AuditDeleteSnippet, SnippetID: id})
+	if err != nil {
+		return models.Snippet{}, err
+	}
+	app.snippetEvent(models.EventSnippetDeleted, snippet)
+
+	return snippet, nil
+}
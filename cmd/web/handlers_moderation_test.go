@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+)
+
+func TestModeration(t *testing.T) {
+	// Create a new test application config.
+	app := newTestApplication(t)
+
+	// Create a new test server, with a client for the author of the snippet,
+	// who reports it too, and one for an admin.
+	user := newTestServer(t, app.routes())
+	defer user.Close()
+	admin := user.newClient(t)
+
+	// login logs a user in from a client.
+	login := func(t *testing.T, ts *testServer, email string) {
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("password", "password")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, _, _ := ts.postForm(t, "/user/login", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+
+	// report reports the snippet from a client.
+	report := func(t *testing.T, ts *testServer, reason string) (int, http.Header, string) {
+		_, _, body := ts.get(t, "/snippet/view/1/")
+		form := url.Values{}
+		form.Add("reason", reason)
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		return ts.postForm(t, "/snippet/report/1", form)
+	}
+
+	// moderate takes a moderation action on the snippet as the admin.
+	moderate := func(t *testing.T, action string) int {
+		_, _, body := admin.get(t, "/moderation")
+		form := url.Values{}
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, headers, _ := admin.postForm(t, "/moderation/"+action+"/1", form)
+		if code == http.StatusSeeOther {
+			assert.Equal(t, headers.Get("Location"), "/moderation")
+		}
+		return code
+	}
+
+	login(t, user, "test@test.com")
+	login(t, admin, "admin@test.com")
+
+	t.Run("Access", func(t *testing.T) {
+		code, _, _ := user.get(t, "/moderation")
+		assert.Equal(t, code, http.StatusForbidden)
+
+		code, _, body := admin.get(t, "/moderation")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "There are no open reports.")
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		code, _, body := report(t, user, "")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field cannot be blank")
+
+		code, headers, _ := report(t, user, "Spam")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/snippet/view/1/")
+
+		_, _, body = user.get(t, "/snippet/view/1/")
+		assert.StringContains(t, body, "the snippet has been reported")
+
+		_, _, body = admin.get(t, "/moderation")
+		assert.StringContains(t, body, "<li>Spam</li>")
+	})
+
+	t.Run("Report twice", func(t *testing.T) {
+		code, _, _ := report(t, user, "Still spam")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		_, _, body := user.get(t, "/snippet/view/1/")
+		assert.StringContains(t, body, "You have already reported this snippet.")
+
+		_, _, body = admin.get(t, "/moderation")
+		assert.StringContains(t, body, "1 report(s)")
+	})
+
+	t.Run("Hide", func(t *testing.T) {
+		assert.Equal(t, moderate(t, "hide"), http.StatusSeeOther)
+
+		code, _, _ := user.get(t, "/snippet/view/1/")
+		assert.Equal(t, code, http.StatusNotFound)
+
+		_, _, body := admin.get(t, "/moderation")
+		assert.StringContains(t, body, "The snippet has been hidden pending review.")
+	})
+
+	t.Run("Dismiss", func(t *testing.T) {
+		assert.Equal(t, moderate(t, "dismiss"), http.StatusSeeOther)
+
+		code, _, _ := user.get(t, "/snippet/view/1/")
+		assert.Equal(t, code, http.StatusOK)
+
+		_, _, body := admin.get(t, "/moderation")
+		assert.StringContains(t, body, "There are no open reports.")
+
+		// Nothing is left to dismiss.
+		assert.Equal(t, moderate(t, "dismiss"), http.StatusNotFound)
+
+		// A dismissed report can't be made again.
+		report(t, user, "Spam again")
+		_, _, body = user.get(t, "/snippet/view/1/")
+		assert.StringContains(t, body, "You have already reported this snippet.")
+	})
+
+	t.Run("Delete and suspend the author", func(t *testing.T) {
+		code, _, _ := report(t, admin, "Offensive")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		assert.Equal(t, moderate(t, "delete"), http.StatusSeeOther)
+
+		// The author is logged out on their next request.
+		code, headers, _ := user.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	t.Run("Audit trail", func(t *testing.T) {
+		entries, err := app.audit.Latest(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var actions []string
+		for _, e := range entries {
+			assert.Equal(t, e.ActorID, 2)
+			actions = append(actions, e.Action)
+		}
+		assert.Equal(t, len(actions), 4)
+		assert.Equal(t, actions[0], "suspend_user")
+		assert.Equal(t, actions[1], "delete_snippet")
+		assert.Equal(t, actions[2], "dismiss_reports")
+		assert.Equal(t, actions[3], "hide_snippet")
+
+		_, _, body := admin.get(t, "/moderation")
+		assert.StringContains(t, body, "Author of reported snippet #1")
+	})
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/AlessioPani/go-snippetbox/internal/validator"
+	"github.com/AlessioPani/go-snippetbox/internal/webauthn"
+)
+
+// passkeyRegisterRequest is the body of a passkey registration, containing the
+// name chosen by the user and the response of the authenticator.
+type passkeyRegisterRequest struct {
+	Name       string                       `json:"name"`
+	Credential webauthn.AttestationResponse `json:"credential"`
+}
+
+// userPasskeys is the handler that shows the passkeys registered by the user.
+// Method: GET
+func (app *application) userPasskeys(w http.ResponseWriter, r *http.Request) {
+	user, _ := app.authenticatedUser(r)
+
+	credentials, err := app.credentials.GetByUser(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Credentials = credentials
+
+	app.render(w, r, http.StatusOK, "passkeys.tmpl.html", data)
+}
+
+// passkeyRegisterBegin is the handler that starts a passkey registration,
+// returning the options for navigator.credentials.create().
+// Method: POST
+func (app *application) passkeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	user, _ := app.authenticatedUser(r)
+
+	credentials, err := app.credentials.GetByUser(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var exclude [][]byte
+	for _, c := range credentials {
+		exclude = append(exclude, c.CredentialID)
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The challenge is kept in the session until the ceremony is completed.
+	app.sessionManager.Put(r.Context(), "passkeyRegistrationChallenge", challenge)
+
+	options := app.webAuthn.CreationOptions(challenge, webauthn.User{
+		ID:          []byte(strconv.Itoa(user.ID)),
+		Name:        user.Email,
+		DisplayName: user.Name,
+	}, exclude)
+
+	app.writeJSON(w, r, http.StatusOK, map[string]any{"publicKey": options})
+}
+
+// passkeyRegisterFinish is the handler that verifies the response of the
+// authenticator and stores the new passkey.
+// Method: POST
+func (app *application) passkeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	user, _ := app.authenticatedUser(r)
+
+	// The challenge can be used only once, whatever the outcome.
+	challenge := app.sessionManager.PopBytes(r.Context(), "passkeyRegistrationChallenge")
+
+	var req passkeyRegisterRequest
+	err := app.decodeJSON(w, r, &req)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var v validator.Validator
+	v.CheckField(validator.NotBlank(req.Name), "name", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(req.Name, 100), "name", "This field cannot be more than 100 characters long")
+	if !v.Valid() {
+		app.clientError(w, http.StatusUnprocessableEntity)
+		return
+	}
+
+	credential, err := app.webAuthn.VerifyRegistration(challenge, req.Credential, false)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrVerification) {
+			app.logger.Warn("passkey registration failed", "error", err.Error(), "user", user.ID)
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.credentials.Insert(user.ID, strings.TrimSpace(req.Name), credential.ID, credential.PublicKey, credential.SignCount)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your passkey has been registered!")
+
+	app.writeJSON(w, r, http.StatusOK, map[string]any{"redirect": "/user/passkeys"})
+}
+
+// passkeyDeletePost is the handler that removes a passkey of the user.
+// Method: POST
+func (app *application) passkeyDeletePost(w http.ResponseWriter, r *http.Request) {
+	credentialID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || credentialID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	user, _ := app.authenticatedUser(r)
+
+	err = app.credentials.Delete(credentialID, user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your passkey has been removed.")
+
+	http.Redirect(w, r, "/user/passkeys", http.StatusSeeOther)
+}
+
+// userLoginPasskey is the handler that shows the second step of the login for
+// users with a passkey, once their password has been checked.
+// Method: GET
+func (app *application) userLoginPasskey(w http.ResponseWriter, r *http.Request) {
+	if app.sessionManager.GetInt(r.Context(), "passkeyPendingUserID") == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	app.render(w, r, http.StatusOK, "passkey.tmpl.html", data)
+}
+
+// passkeyLoginBegin is the handler that starts a passkey authentication,
+// returning the options for navigator.credentials.get().
+// If the password of the user has already been checked, the passkey is used as
+// a second factor and only their credentials are allowed. Otherwise, it's a
+// passwordless login and the authenticator picks a discoverable credential.
+// Method: POST
+func (app *application) passkeyLoginBegin(w http.ResponseWriter, r *http.Request) {
+	var allow [][]byte
+	userVerification := "required"
+
+	pendingID := app.sessionManager.GetInt(r.Context(), "passkeyPendingUserID")
+	if pendingID != 0 {
+		credentials, err := app.credentials.GetByUser(pendingID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		for _, c := range credentials {
+			allow = append(allow, c.CredentialID)
+		}
+		userVerification = "discouraged"
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "passkeyLoginChallenge", challenge)
+
+	options := app.webAuthn.RequestOptions(challenge, allow, userVerification)
+
+	app.writeJSON(w, r, http.StatusOK, map[string]any{"publicKey": options})
+}
+
+// passkeyLoginFinish is the handler that verifies the response of the
+// authenticator and logs in the user.
+// Method: POST
+func (app *application) passkeyLoginFinish(w http.ResponseWriter, r *http.Request) {
+	challenge := app.sessionManager.PopBytes(r.Context(), "passkeyLoginChallenge")
+	pendingID := app.sessionManager.GetInt(r.Context(), "passkeyPendingUserID")
+
+	var resp webauthn.AssertionResponse
+	err := app.decodeJSON(w, r, &resp)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	credential, err := app.credentials.Get(resp.RawID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusUnauthorized)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// As a second factor, the passkey must belong to the user who has just
+	// entered their password. For a passwordless login, the user handle
+	// returned by the authenticator must match the owner of the credential.
+	switch {
+	case pendingID != 0 && credential.UserID != pendingID:
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	case len(resp.Response.UserHandle) > 0 && string(resp.Response.UserHandle) != strconv.Itoa(credential.UserID):
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	// User verification replaces the password, so it's mandatory only for a
+	// passwordless login.
+	signCount, err := app.webAuthn.VerifyAssertion(challenge, resp, credential.PublicKey, credential.SignCount, pendingID == 0)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrVerification) {
+			app.logger.Warn("passkey login failed", "error", err.Error(), "user", credential.UserID)
+			app.clientError(w, http.StatusUnauthorized)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.credentials.UpdateSignCount(credential.ID, signCount)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Only a login with a password and a passkey as second factor can ask
+	// to be remembered.
+	rememberMe := app.sessionManager.PopBool(r.Context(), "passkeyPendingRememberMe")
+	app.sessionManager.Remove(r.Context(), "passkeyPendingUserID")
+
+	err = app.logIn(r, credential.UserID, rememberMe)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, map[string]any{"redirect": "/snippet/create"})
+}
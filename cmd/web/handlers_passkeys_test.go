@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/webauthn"
+	"github.com/AlessioPani/go-snippetbox/internal/webauthn/webauthntest"
+)
+
+func TestPasskeys(t *testing.T) {
+	// Create a new test application config.
+	app := newTestApplication(t)
+
+	// Create a new test server. The origin of the relying party is known
+	// only once the server is listening.
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	app.webAuthn.Origin = ts.URL
+
+	// Create the software authenticator standing in for the browser.
+	authenticator, err := webauthntest.NewAuthenticator(ts.URL, app.webAuthn.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// login submits the password login form and returns the redirect location.
+	login := func(t *testing.T) string {
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", "test@test.com")
+		form.Add("password", "password")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, headers, _ := ts.postForm(t, "/user/login", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		return headers.Get("Location")
+	}
+
+	// logout submits the logout form.
+	logout := func(t *testing.T) {
+		_, _, body := ts.get(t, "/")
+		form := url.Values{}
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		ts.postForm(t, "/user/logout", form)
+	}
+
+	// passkeyLogin runs an authentication ceremony from the login page and
+	// returns the status code of the final step.
+	passkeyLogin := func(t *testing.T, page string) int {
+		_, _, body := ts.get(t, page)
+		csrfToken := extractCSRFToken(t, body)
+
+		var options struct {
+			PublicKey webauthn.RequestOptions `json:"publicKey"`
+		}
+		code := ts.postJSON(t, "/user/login/passkey/begin", csrfToken, nil, &options)
+		assert.Equal(t, code, http.StatusOK)
+
+		resp, err := authenticator.Get(options.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ts.postJSON(t, "/user/login/passkey/finish", csrfToken, resp, nil)
+	}
+
+	t.Run("Register", func(t *testing.T) {
+		assert.Equal(t, login(t), "/snippet/create")
+
+		_, _, body := ts.get(t, "/user/passkeys")
+		csrfToken := extractCSRFToken(t, body)
+
+		var options struct {
+			PublicKey webauthn.CreationOptions `json:"publicKey"`
+		}
+		code := ts.postJSON(t, "/user/passkeys/register/begin", csrfToken, nil, &options)
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, options.PublicKey.RelyingParty.ID, app.webAuthn.ID)
+
+		resp, err := authenticator.Create(options.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code = ts.postJSON(t, "/user/passkeys/register/finish", csrfToken, passkeyRegisterRequest{
+			Name:       "Test key",
+			Credential: resp,
+		}, nil)
+		assert.Equal(t, code, http.StatusOK)
+
+		_, _, body = ts.get(t, "/user/passkeys")
+		assert.StringContains(t, body, "Test key")
+
+		// The challenge is single use: replaying the registration fails.
+		code = ts.postJSON(t, "/user/passkeys/register/finish", csrfToken, passkeyRegisterRequest{
+			Name:       "Test key",
+			Credential: resp,
+		}, nil)
+		assert.Equal(t, code, http.StatusBadRequest)
+
+		logout(t)
+	})
+
+	t.Run("Passwordless login", func(t *testing.T) {
+		code := passkeyLogin(t, "/user/login")
+		assert.Equal(t, code, http.StatusOK)
+
+		code, _, _ = ts.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusOK)
+
+		logout(t)
+	})
+
+	t.Run("Second factor", func(t *testing.T) {
+		// The password alone is not enough anymore.
+		assert.Equal(t, login(t), "/user/login/passkey")
+
+		code, headers, _ := ts.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+
+		code = passkeyLogin(t, "/user/login/passkey")
+		assert.Equal(t, code, http.StatusOK)
+
+		code, _, _ = ts.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusOK)
+
+		logout(t)
+	})
+
+	t.Run("Cloned authenticator", func(t *testing.T) {
+		// Rewind the signature counter, as a clone of the authenticator
+		// would do.
+		authenticator.SignCount = 0
+
+		code := passkeyLogin(t, "/user/login")
+		assert.Equal(t, code, http.StatusUnauthorized)
+
+		code, _, _ = ts.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusSeeOther)
+	})
+}
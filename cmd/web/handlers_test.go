@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
 )
 
 func TestPing(t *testing.T) {
@@ -79,6 +87,99 @@ func TestSnippetView(t *testing.T) {
 	}
 }
 
+func TestQueryTimeout(t *testing.T) {
+	// Create a new test application config, whose queries time out at once.
+	app := newTestApplication(t)
+	app.queryTimeout = 0
+
+	// Create a new test server.
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Page", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/1/")
+
+		assert.Equal(t, code, http.StatusServiceUnavailable)
+		assert.StringContains(t, body, http.StatusText(http.StatusServiceUnavailable))
+	})
+
+	t.Run("API", func(t *testing.T) {
+		token, err := app.tokens.Insert(1, "Test", []string{models.ScopeSnippetsRead}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, _, body := ts.apiRequest(t, http.MethodGet, "/api/v1/snippets/1", token, "")
+
+		assert.Equal(t, code, http.StatusServiceUnavailable)
+		assert.StringContains(t, body, `"error":"Service Unavailable"`)
+	})
+}
+
+func TestSnippetViewFormats(t *testing.T) {
+	// Create a new test application config.
+	app := newTestApplication(t)
+
+	// Create a new test server.
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		query           string
+		accept          string
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{"No Accept header", "", "", http.StatusOK, "text/html; charset=utf-8", "Snippet #1"},
+		{"Browser", "", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", http.StatusOK, "text/html; charset=utf-8", "Snippet #1"},
+		{"Any", "", "*/*", http.StatusOK, "text/html; charset=utf-8", "Snippet #1"},
+		{"JSON", "", "application/json", http.StatusOK, "application/json", `"title":"An old silent pond"`},
+		{"Plain text", "", "text/plain", http.StatusOK, "text/plain; charset=utf-8", "An old silent pond..."},
+		{"Markdown", "", "text/markdown", http.StatusOK, "text/markdown; charset=utf-8", "# An old silent pond\n\n```\nAn old silent pond...\n```"},
+		{"Quality", "", "text/html;q=0.5, text/markdown;q=0.6, text/*;q=0.1", http.StatusOK, "text/markdown; charset=utf-8", "```"},
+		{"Wildcard type", "", "text/*, text/html;q=0", http.StatusOK, "text/plain; charset=utf-8", "An old silent pond..."},
+		{"Format parameter", "?format=json", "text/html", http.StatusOK, "application/json", `"id":1`},
+		{"Unknown format", "?format=xml", "", http.StatusNotAcceptable, "", ""},
+		{"Not acceptable", "", "image/png", http.StatusNotAcceptable, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/view/1/"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rs, err := ts.client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, slices.Contains(rs.Header.Values("Vary"), "Accept"), true)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, rs.Header.Get("Content-Type"), tt.wantContentType)
+				assert.StringContains(t, string(body), tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Markdown fence", func(t *testing.T) {
+		md := snippetMarkdown(models.Snippet{Title: "Fences", Content: "```go\n````\n", Language: "markdown"})
+		assert.Equal(t, md, "# Fences\n\n`````markdown\n```go\n````\n`````\n")
+	})
+}
+
 func TestSnippetCreate(t *testing.T) {
 	// Create a new test application config.
 	// The logger is required for some middleware.
@@ -139,6 +240,7 @@ func TestUserSignup(t *testing.T) {
 		csrfToken    string
 		wantCode     int
 		wantFormTag  string
+		wantError    string
 	}{
 		{
 			name:         "Valid submission",
@@ -209,6 +311,7 @@ func TestUserSignup(t *testing.T) {
 			csrfToken:    validCSRFToken,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
+			wantError:    "Email address is already in use",
 		},
 	}
 
@@ -225,6 +328,259 @@ func TestUserSignup(t *testing.T) {
 			if test.wantFormTag != "" {
 				assert.StringContains(t, body, test.wantFormTag)
 			}
+			if test.wantError != "" {
+				assert.StringContains(t, body, test.wantError)
+			}
+		})
+	}
+}
+
+func TestUserLoginThrottling(t *testing.T) {
+	const throttledMessage = "Too many failed login attempts. Please try again later"
+
+	// The same behaviour is expected for an existing and a non-existing
+	// email address, so the throttling doesn't reveal which one exists.
+	for _, email := range []string{"test@test.com", "nobody@test.com"} {
+		t.Run(email, func(t *testing.T) {
+			// Create a new test application config, so the failures of
+			// the previous case are not counted.
+			app := newTestApplication(t)
+
+			// Create a new test server.
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			_, _, body := ts.get(t, "/user/login")
+			csrfToken := extractCSRFToken(t, body)
+
+			login := func(password string) (int, http.Header, string) {
+				form := url.Values{}
+				form.Add("email", email)
+				form.Add("password", password)
+				form.Add("csrf_token", csrfToken)
+				return ts.postForm(t, "/user/login", form)
+			}
+
+			for i := 0; i < app.loginThrottle.freeAttempts; i++ {
+				code, _, body := login("wrongPassword")
+				assert.Equal(t, code, http.StatusUnprocessableEntity)
+				assert.StringContains(t, body, "Email or password is incorrect")
+			}
+
+			// Even the right password is refused while throttled.
+			code, headers, body := login("password")
+			assert.Equal(t, code, http.StatusTooManyRequests)
+			assert.Equal(t, headers.Get("Retry-After"), "1")
+			assert.StringContains(t, body, throttledMessage)
+		})
+	}
+}
+
+// slowUserModel counts the password checks, and makes each one take a while
+// like bcrypt does, so concurrent logins overlap.
+type slowUserModel struct {
+	models.UserModelInterface
+	checks atomic.Int32
+}
+
+func (m *slowUserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	m.checks.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	return m.UserModelInterface.Authenticate(ctx, email, password)
+}
+
+func TestUserLoginThrottlingConcurrent(t *testing.T) {
+	// Create a new test application config, with a user model counting the
+	// password checks.
+	app := newTestApplication(t)
+	users := &slowUserModel{UserModelInterface: app.users}
+	app.users = users
+
+	// Create a new test server.
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "test@test.com")
+	form.Add("password", "wrongPassword")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	// Fire a burst of bad logins at once. Each attempt is recorded before
+	// its password is checked, so only the free attempts get that far.
+	const n = 20
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/user/login", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Origin", ts.URL)
+
+			rs, err := ts.client.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			rs.Body.Close()
+
+			if rs.StatusCode != http.StatusUnprocessableEntity && rs.StatusCode != http.StatusTooManyRequests {
+				t.Errorf("got status %d", rs.StatusCode)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int(users.checks.Load()), app.loginThrottle.freeAttempts)
+}
+
+// userAgentTransport sets the User-Agent header of the requests, so a client
+// can pass for a different browser.
+type userAgentTransport struct {
+	userAgent string
+	http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("User-Agent", t.userAgent)
+	return t.RoundTripper.RoundTrip(r)
+}
+
+func TestUserLoginRememberMe(t *testing.T) {
+	// login logs the user in from a device, returning the session cookie.
+	login := func(t *testing.T, ts *testServer, rememberMe bool) *http.Cookie {
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", "test@test.com")
+		form.Add("password", "password")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		if rememberMe {
+			form.Add("rememberMe", "true")
+		}
+
+		code, headers, _ := ts.postForm(t, "/user/login", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		for _, cookie := range (&http.Response{Header: headers}).Cookies() {
+			if cookie.Name == "session" {
+				return cookie
+			}
+		}
+		t.Fatal("no session cookie set")
+		return nil
+	}
+
+	// isLoggedIn checks whether a device can reach a protected page.
+	isLoggedIn := func(t *testing.T, ts *testServer) bool {
+		code, _, _ := ts.get(t, "/snippet/create")
+		return code == http.StatusOK
+	}
+
+	tests := []struct {
+		name           string
+		rememberMe     bool
+		wantMaxAge     int
+		wantIdleLogout bool
+	}{
+		{
+			name:           "Normal login",
+			rememberMe:     false,
+			wantMaxAge:     0,
+			wantIdleLogout: true,
+		},
+		{
+			name:           "Remember me",
+			rememberMe:     true,
+			wantMaxAge:     int((30 * 24 * time.Hour).Seconds()),
+			wantIdleLogout: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.sessionIdleTimeout = 100 * time.Millisecond
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			// A normal login gets a browser session cookie, a remembered one
+			// a cookie lasting for the remember me lifetime.
+			cookie := login(t, ts, tt.rememberMe)
+			assert.Equal(t, cookie.MaxAge/60, tt.wantMaxAge/60)
+			assert.Equal(t, isLoggedIn(t, ts), true)
+
+			time.Sleep(2 * app.sessionIdleTimeout)
+			assert.Equal(t, isLoggedIn(t, ts), !tt.wantIdleLogout)
+		})
+	}
+
+	t.Run("Remembered session used with another User-Agent", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+		cookie := login(t, ts, true)
+
+		// Another browser presenting the same cookie is refused, and the
+		// session is revoked for the original browser too. A thief copying
+		// the User-Agent as well wouldn't be caught.
+		thief := ts.newClient(t)
+		thief.client.Transport = userAgentTransport{"Thief/1.0", ts.client.Transport}
+		u, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		thief.client.Jar.SetCookies(u, []*http.Cookie{cookie})
+
+		assert.Equal(t, isLoggedIn(t, thief), false)
+		assert.Equal(t, isLoggedIn(t, ts), false)
+	})
+}
+
+func TestNavRoles(t *testing.T) {
+	const adminLink = "<a href='/admin'>Admin</a>"
+
+	tests := []struct {
+		name          string
+		email         string
+		wantAdminLink bool
+	}{
+		{
+			name:          "User",
+			email:         "test@test.com",
+			wantAdminLink: false,
+		},
+		{
+			name:          "Admin",
+			email:         "admin@test.com",
+			wantAdminLink: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			_, _, body := ts.get(t, "/user/login")
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("password", "password")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			code, _, _ := ts.postForm(t, "/user/login", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+
+			_, _, body = ts.get(t, "/")
+			assert.Equal(t, strings.Contains(body, adminLink), tt.wantAdminLink)
 		})
 	}
 }
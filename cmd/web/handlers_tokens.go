@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/AlessioPani/go-snippetbox/internal/validator"
+)
+
+// apiTokenCreateForm is a struct that contains the API token creation form data and errors.
+type apiTokenCreateForm struct {
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scopes"`
+	ExpiresIn           int      `form:"expiresIn"`
+	validator.Validator `form:"-"`
+}
+
+// accountTokens is the handler that lists the API tokens of the user, with a
+// form used to create a new one.
+// Method: GET
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = apiTokenCreateForm{ExpiresIn: 30}
+
+	app.renderTokens(w, r, http.StatusOK, data)
+}
+
+// accountTokenCreatePost is the handler that creates an API token, which is
+// shown once in the response, as it can't be retrieved afterwards.
+// Method: POST
+func (app *application) accountTokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form apiTokenCreateForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Validate form data.
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Scopes) > 0, "scopes", "At least one scope must be selected")
+	for _, scope := range form.Scopes {
+		form.CheckField(validator.PermittedValue(scope, models.Scopes...), "scopes", "This field contains an unknown scope")
+	}
+	form.CheckField(validator.PermittedValue(form.ExpiresIn, 0, 7, 30, 90, 365), "expiresIn", "This field must be equal to 7, 30, 90, 365 or never")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.renderTokens(w, r, http.StatusUnprocessableEntity, data)
+		return
+	}
+
+	user, _ := app.authenticatedUser(r)
+
+	token, err := app.tokens.Insert(user.ID, form.Name, form.Scopes, form.ExpiresIn)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The token is only stored hashed, so it is rendered in the response
+	// rather than kept in the session, which is stored in the database. The
+	// response mustn't be cached by the browser or a proxy.
+	w.Header().Set("Cache-Control", "no-store")
+
+	data := app.newTemplateData(r)
+	data.Form = apiTokenCreateForm{ExpiresIn: 30}
+	data.NewToken = token
+	app.renderTokens(w, r, http.StatusCreated, data)
+}
+
+// accountTokenRevokePost is the handler that revokes an API token of the user.
+// Method: POST
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || tokenID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	user, _ := app.authenticatedUser(r)
+
+	err = app.tokens.Delete(tokenID, user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The API token has been revoked.")
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+// renderTokens renders the API tokens page with the tokens of the user.
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, status int, data templateData) {
+	user, _ := app.authenticatedUser(r)
+
+	tokens, err := app.tokens.GetByUser(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Tokens = tokens
+	data.Scopes = models.Scopes
+	app.render(w, r, status, "tokens.tmpl.html", data)
+}
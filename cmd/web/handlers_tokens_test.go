@@ -0,0 +1,181 @@
+package main
+
+import (
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+)
+
+// newTokenRX captures the API token shown once after its creation.
+var newTokenRX = regexp.MustCompile(`Your new API token is <code>([^<]+)</code>`)
+
+// revokeTokenFormRX captures the IDs of the API tokens which can be revoked.
+var revokeTokenFormRX = regexp.MustCompile(`<form action='/account/tokens/revoke/([0-9]+)'`)
+
+func TestAPITokens(t *testing.T) {
+	// Create a new test application config.
+	app := newTestApplication(t)
+
+	// Create a new test server, with a browser client for the user and a
+	// client without cookies for their scripts.
+	browser := newTestServer(t, app.routes())
+	defer browser.Close()
+	script := browser.newClient(t)
+
+	_, _, body := browser.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "test@test.com")
+	form.Add("password", "password")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := browser.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// createToken creates an API token from the browser and returns it.
+	createToken := func(t *testing.T, form url.Values) (int, string) {
+		_, _, body := browser.get(t, "/account/tokens")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, headers, body := browser.postForm(t, "/account/tokens", form)
+		if code != http.StatusCreated {
+			return code, body
+		}
+		assert.Equal(t, headers.Get("Cache-Control"), "no-store")
+
+		matches := newTokenRX.FindStringSubmatch(body)
+		if len(matches) < 2 {
+			t.Fatal("no API token found in body")
+		}
+		return code, html.UnescapeString(matches[1])
+	}
+
+	// createSnippet creates a snippet from the script, with an Authorization
+	// header but neither a CSRF token nor an Origin header.
+	createSnippet := func(t *testing.T, authorization string) (int, http.Header) {
+		form := url.Values{}
+		form.Add("title", "Created by a script")
+		form.Add("content", "Hello from the API")
+		form.Add("expires", "7")
+
+		req, err := http.NewRequest(http.MethodPost, script.URL+"/snippet/create", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		rs, err := script.client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+		io.Copy(io.Discard, rs.Body)
+
+		return rs.StatusCode, rs.Header
+	}
+
+	var writeToken string
+
+	t.Run("Create with invalid data", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("name", "")
+		form.Add("scopes", "snippets:delete")
+		form.Add("expiresIn", "3")
+
+		code, body := createToken(t, form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field cannot be blank")
+		assert.StringContains(t, body, "This field contains an unknown scope")
+		assert.StringContains(t, body, "This field must be equal to 7, 30, 90, 365 or never")
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("name", "Backup script")
+		form.Add("scopes", "snippets:write")
+		form.Add("expiresIn", "30")
+
+		code, token := createToken(t, form)
+		assert.Equal(t, code, http.StatusCreated)
+		assert.StringContains(t, token, "sbx_")
+		writeToken = token
+
+		// The token is shown only once.
+		_, _, body := browser.get(t, "/account/tokens")
+		assert.StringContains(t, body, "Backup script")
+		assert.Equal(t, strings.Contains(body, token), false)
+	})
+
+	t.Run("Use", func(t *testing.T) {
+		code, headers := createSnippet(t, "Bearer "+writeToken)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/snippet/view/2/")
+	})
+
+	t.Run("No token", func(t *testing.T) {
+		// Without a token, the CSRF protection applies.
+		code, _ := createSnippet(t, "")
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		code, headers := createSnippet(t, "Bearer sbx_invalid")
+		assert.Equal(t, code, http.StatusUnauthorized)
+		assert.Equal(t, headers.Get("WWW-Authenticate"), `Bearer error="invalid_token"`)
+
+		code, _ = createSnippet(t, "Basic "+writeToken)
+		assert.Equal(t, code, http.StatusUnauthorized)
+	})
+
+	t.Run("Insufficient scope", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("name", "Read only")
+		form.Add("scopes", "snippets:read")
+		form.Add("expiresIn", "0")
+		_, token := createToken(t, form)
+
+		code, headers := createSnippet(t, "Bearer "+token)
+		assert.Equal(t, code, http.StatusForbidden)
+		assert.Equal(t, headers.Get("WWW-Authenticate"), `Bearer error="insufficient_scope", scope="snippets:write"`)
+	})
+
+	t.Run("Route without tokens", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, script.URL+"/account", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+writeToken)
+
+		rs, err := script.client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+
+		assert.Equal(t, rs.StatusCode, http.StatusSeeOther)
+		assert.Equal(t, rs.Header.Get("Location"), "/user/login")
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		_, _, body := browser.get(t, "/account/tokens")
+		matches := revokeTokenFormRX.FindAllStringSubmatch(body, -1)
+		assert.Equal(t, len(matches), 2)
+
+		for _, match := range matches {
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			code, _, _ := browser.postForm(t, "/account/tokens/revoke/"+match[1], form)
+			assert.Equal(t, code, http.StatusSeeOther)
+		}
+
+		code, _ := createSnippet(t, "Bearer "+writeToken)
+		assert.Equal(t, code, http.StatusUnauthorized)
+	})
+}
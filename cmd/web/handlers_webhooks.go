@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/AlessioPani/go-snippetbox/internal/validator"
+)
+
+// webhookDeliveriesShown is the number of deliveries shown in the delivery log.
+const webhookDeliveriesShown = 50
+
+// webhookCreateForm is a struct that contains the webhook creation form data and errors.
+type webhookCreateForm struct {
+	URL                 string `form:"url"`
+	Secret              string `form:"secret"`
+	validator.Validator `form:"-"`
+}
+
+// accountWebhooks is the handler that lists the webhooks of the user and the
+// latest deliveries to them, with a form used to register a new webhook.
+// Method: GET
+func (app *application) accountWebhooks(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = webhookCreateForm{}
+
+	app.renderWebhooks(w, r, http.StatusOK, data)
+}
+
+// accountWebhookCreatePost is the handler that registers a webhook.
+// Method: POST
+func (app *application) accountWebhookCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form webhookCreateForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Validate form data.
+	form.CheckField(validator.NotBlank(form.URL), "url", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.URL, 2048), "url", "This field cannot be more than 2048 characters long")
+	form.CheckField(webhookURL(form.URL), "url", "This field must be a valid http or https URL")
+	if form.Valid() {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		u, _ := url.Parse(form.URL)
+		form.CheckField(checkWebhookHost(ctx, u.Hostname()) == nil, "url", "This field must be the URL of a public host, not of a local or private network")
+	}
+	form.CheckField(validator.MinChars(form.Secret, 16), "secret", "This field must be at least 16 characters long")
+	form.CheckField(validator.MaxChars(form.Secret, 255), "secret", "This field cannot be more than 255 characters long")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.renderWebhooks(w, r, http.StatusUnprocessableEntity, data)
+		return
+	}
+
+	user, _ := app.authenticatedUser(r)
+
+	_, err = app.webhooks.Insert(user.ID, form.URL, form.Secret)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The webhook has been registered.")
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
+
+// accountWebhookDeletePost is the handler that deletes a webhook of the user.
+// Method: POST
+func (app *application) accountWebhookDeletePost(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || webhookID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	user, _ := app.authenticatedUser(r)
+
+	err = app.webhooks.Delete(webhookID, user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The webhook has been deleted.")
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
+
+// renderWebhooks renders the webhooks page with the webhooks of the user and
+// their delivery log.
+func (app *application) renderWebhooks(w http.ResponseWriter, r *http.Request, status int, data templateData) {
+	user, _ := app.authenticatedUser(r)
+
+	webhooks, err := app.webhooks.GetByUser(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	deliveries, err := app.webhooks.Deliveries(user.ID, webhookDeliveriesShown)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Webhooks = webhooks
+	data.Deliveries = deliveries
+	app.render(w, r, status, "webhooks.tmpl.html", data)
+}
+
+// webhookURL reports whether s is an absolute http or https URL.
+func webhookURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
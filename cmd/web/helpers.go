@@ -2,23 +2,71 @@ package main
 
 import (
 	"bytes"
-	"database/sql"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/AlessioPani/go-snippetbox/internal/validator"
 	"github.com/go-playground/form"
 	"github.com/justinas/nosurf"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // serverError is a method that writes a log entry at Error level and sends a generic 500 Internal Server Error response to the user.
+// A query which ran out of time gets a 503 Service Unavailable response instead, logged at Warn level, as the database is
+// busy rather than broken and the request can be retried.
+// The ID of the request is logged and shown to the user, who can give it to the support to find the log entry.
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
 	method := r.Method
 	uri := r.URL.RequestURI()
+	id := requestIDFromContext(r.Context())
 
-	app.logger.Error(err.Error(), "method", method, "uri", uri)
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if errors.Is(err, context.DeadlineExceeded) {
+		app.logger.Warn(err.Error(), "method", method, "uri", uri, "request_id", id)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	app.logger.Error(err.Error(), "method", method, "uri", uri, "request_id", id)
+
+	message := http.StatusText(http.StatusInternalServerError)
+	if id != "" {
+		message += "\nRequest ID: " + id
+	}
+	http.Error(w, message, http.StatusInternalServerError)
+}
+
+// queryContext returns the context of the database queries made for a
+// request. It is cancelled with the request, or when the query timeout has
+// elapsed.
+func (app *application) queryContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), app.queryTimeout)
+}
+
+// getUser is a method used to get a user based on its ID within the query
+// timeout, for the middleware which authenticate the requests.
+func (app *application) getUser(r *http.Request, id int) (models.User, error) {
+	ctx, cancel := app.queryContext(r)
+	defer cancel()
+
+	return app.users.Get(ctx, id)
+}
+
+// routePattern returns the pattern of mux matching a request, or "unmatched"
+// if none does. It is looked up before the request is served, as the
+// middleware may pass a copy of the request to the mux.
+func routePattern(mux *http.ServeMux, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	if pattern == "" {
+		return "unmatched"
+	}
+	return pattern
 }
 
 // clientError is a method that sends a specific error response to the user.
@@ -36,12 +84,19 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 		return
 	}
 
+	// Trace the rendering of the page, from the execution of the template to
+	// the writing of the response.
+	_, span := app.tracer.Start(r.Context(), "render "+page)
+	defer span.End()
+
 	// Create a new buffer.
 	buf := new(bytes.Buffer)
 
 	// Execute the template in the buffer to check for errors.
 	err := ts.ExecuteTemplate(buf, "base", data)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		app.serverError(w, r, err)
 		return
 	}
@@ -55,11 +110,14 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 
 // newTemplateData set up some common template data.
 func (app *application) newTemplateData(r *http.Request) templateData {
+	authenticatedUser, _ := app.authenticatedUser(r)
+
 	return templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+		CurrentYear:       time.Now().Year(),
+		Flash:             app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:   app.isAuthenticated(r),
+		AuthenticatedUser: authenticatedUser,
+		CSRFToken:         nosurf.Token(r),
 	}
 }
 
@@ -83,68 +141,304 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 	return nil
 }
 
-// isAuthenticated returns true if the current request is from an authenticated user,
-// otherwise returns false.
-func (app *application) isAuthenticated(r *http.Request) bool {
-	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
-	if !ok {
-		return false
+// maxJSONBytes is the maximum size of a JSON request body.
+const maxJSONBytes = 64 << 10
+
+// decodeJSON decodes a JSON request body into a destination (dst), rejecting
+// bodies larger than maxJSONBytes.
+func (app *application) decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBytes)
+
+	return json.NewDecoder(r.Body).Decode(dst)
+}
+
+// writeJSON encodes data as JSON and sends it to the user with the provided status.
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// apiErrorResponse is the envelope of the errors sent by the JSON API. Fields
+// contains the validation errors of the request fields, if any.
+type apiErrorResponse struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// apiServerError is the JSON API equivalent of serverError: it writes a log
+// entry at Error level and sends a generic 500 Internal Server Error response,
+// or a 503 Service Unavailable response for a query which ran out of time.
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	method := r.Method
+	uri := r.URL.RequestURI()
+	id := requestIDFromContext(r.Context())
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		app.logger.Warn(err.Error(), "method", method, "uri", uri, "request_id", id)
+		app.writeJSON(w, r, http.StatusServiceUnavailable, apiErrorResponse{Error: http.StatusText(http.StatusServiceUnavailable)})
+		return
 	}
 
-	return isAuthenticated
+	app.logger.Error(err.Error(), "method", method, "uri", uri, "request_id", id)
+	app.writeJSON(w, r, http.StatusInternalServerError, apiErrorResponse{Error: http.StatusText(http.StatusInternalServerError)})
 }
 
-// checkTables is a function that checks for users and snippets tables.
-// If they are not in the DB, create them.
-func checkTables(db *sql.DB) error {
-	var tableName string
+// apiClientError is the JSON API equivalent of clientError.
+func (app *application) apiClientError(w http.ResponseWriter, r *http.Request, status int) {
+	app.writeJSON(w, r, status, apiErrorResponse{Error: http.StatusText(status)})
+}
 
-	// Check for the table users.
-	query := `SELECT name FROM sqlite_master WHERE type='table' AND name='users';`
-	err := db.QueryRow(query).Scan(&tableName)
+// apiFailedValidation sends a 422 Unprocessable Entity response with the
+// validation errors of the request fields.
+func (app *application) apiFailedValidation(w http.ResponseWriter, r *http.Request, v validator.Validator) {
+	app.writeJSON(w, r, http.StatusUnprocessableEntity, apiErrorResponse{
+		Error:  http.StatusText(http.StatusUnprocessableEntity),
+		Fields: v.FieldErrors,
+	})
+}
 
-	if err != nil {
-		if err == sql.ErrNoRows {
-			createUsersTable := `
-				CREATE TABLE users (
-					id INTEGER PRIMARY KEY AUTOINCREMENT,
-					name VARCHAR(255) NOT NULL,
-					email VARCHAR(255) NOT NULL,
-					hashed_password CHAR(60) NOT NULL,
-					created DATETIME NOT NULL,
-					CONSTRAINT uc_email UNIQUE (email)
-				);`
-			_, err = db.Exec(createUsersTable)
-			if err != nil {
-				return err
+// apiBadRequest sends the response for a JSON request body which can't be
+// decoded: 413 Request Entity Too Large if it is larger than maxJSONBytes, 400
+// Bad Request otherwise.
+func (app *application) apiBadRequest(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		app.apiClientError(w, r, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	app.apiClientError(w, r, http.StatusBadRequest)
+}
+
+// Formats of the representations a response can be negotiated to, as they
+// are named in the format query parameter.
+const (
+	formatHTML     = "html"
+	formatJSON     = "json"
+	formatText     = "text"
+	formatMarkdown = "markdown"
+)
+
+// formatMediaTypes maps the formats to their media types.
+var formatMediaTypes = map[string]string{
+	formatHTML:     "text/html",
+	formatJSON:     "application/json",
+	formatText:     "text/plain",
+	formatMarkdown: "text/markdown",
+}
+
+// representation is a format a handler can send its response in, with the
+// function rendering it.
+type representation struct {
+	format string
+	render func()
+}
+
+// negotiate renders the representation of the response preferred by the
+// request: the one named in the format query parameter if any, otherwise the
+// one the Accept header gives the highest quality to. On a tie, or without an
+// Accept header, the first representation wins. If none is acceptable, a 406
+// Not Acceptable response is sent.
+func (app *application) negotiate(w http.ResponseWriter, r *http.Request, representations ...representation) {
+	w.Header().Add("Vary", "Accept")
+
+	if format := r.URL.Query().Get("format"); format != "" {
+		for _, rep := range representations {
+			if rep.format == format {
+				rep.render()
+				return
 			}
-		} else {
-			return err
 		}
+		app.clientError(w, http.StatusNotAcceptable)
+		return
 	}
 
-	// Check for the table snippets.
-	query = `SELECT name FROM sqlite_master WHERE type='table' AND name='snippets';`
-	err = db.QueryRow(query).Scan(&tableName)
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		representations[0].render()
+		return
+	}
 
-	if err != nil {
-		if err == sql.ErrNoRows {
-			createTableQuery := `
-				CREATE TABLE snippets (
-					id INTEGER PRIMARY KEY AUTOINCREMENT,
-					title VARCHAR(255) NOT NULL,
-					content VARCHAR(255) NOT NULL,
-					created DATETIME NOT NULL,
-					expires DATETIME NOT NULL
-				);`
-			_, err = db.Exec(createTableQuery)
-			if err != nil {
-				return err
+	var best *representation
+	var bestQuality float64
+	for i, rep := range representations {
+		quality := acceptQuality(accept, formatMediaTypes[rep.format])
+		if quality > bestQuality {
+			best, bestQuality = &representations[i], quality
+		}
+	}
+
+	if best == nil {
+		app.clientError(w, http.StatusNotAcceptable)
+		return
+	}
+	best.render()
+}
+
+// acceptQuality returns the quality an Accept header gives to a media type,
+// from the most specific media range matching it, or 0 if none does.
+func acceptQuality(accept string, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	quality, specificity := 0.0, -1
+	for _, mediaRange := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(mediaRange, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		var s int
+		switch name {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s < specificity {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(key) == "q" {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err == nil {
+					q = parsed
+				}
 			}
-		} else {
-			return err
 		}
+		quality, specificity = q, s
+	}
+
+	return quality
+}
+
+// writeText sends a text response with the provided status and media type,
+// encoded in UTF-8.
+func (app *application) writeText(w http.ResponseWriter, status int, mediaType string, text string) {
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(text))
+}
+
+// logIn renews the session token and records a new logged in session for a
+// user, so that it can be listed and revoked from the account page.
+// A remembered session lasts rememberMeLifetime regardless of inactivity, with
+// a persistent cookie, and is tied to the User-Agent header it was created
+// with. This is only a weak heuristic against stolen cookies: the header is
+// chosen by the client, so whoever steals the cookie can send it too.
+// Otherwise the session expires after the session manager lifetime or after
+// sessionIdleTimeout of inactivity, and the cookie is gone with the browser.
+func (app *application) logIn(r *http.Request, userID int, rememberMe bool) error {
+	// Use the RenewToken() method on the current session to change the session
+	// ID. It's good practice to generate a new session ID when the
+	// authentication state or privilege levels changes for the user (e.g. login
+	// and logout operations).
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := app.sessions.Insert(userID, r.UserAgent(), clientIP(r), rememberMe)
+	if err != nil {
+		return err
+	}
+
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", userID)
+	app.sessionManager.Put(r.Context(), "sessionID", sessionID)
+
+	// The flag is kept in the session as well, as the one set by RememberMe
+	// is internal to the session manager.
+	app.sessionManager.RememberMe(r.Context(), rememberMe)
+	app.sessionManager.Put(r.Context(), "rememberMe", rememberMe)
+	if rememberMe {
+		app.sessionManager.SetDeadline(r.Context(), time.Now().Add(app.rememberMeLifetime).UTC())
+		app.sessionManager.Put(r.Context(), "userAgent", r.UserAgent())
+	} else {
+		app.sessionManager.Put(r.Context(), "lastSeen", time.Now().UnixMilli())
 	}
 
 	return nil
 }
+
+// sessionExpired reports whether the logged in session of the request can't
+// be used anymore: a normal session which has been inactive for longer than
+// sessionIdleTimeout, or a remembered session used with another User-Agent.
+// Otherwise, the last seen time of a normal session is updated once it's older
+// than lastSeenInterval, so the session isn't saved on every request.
+func (app *application) sessionExpired(r *http.Request) bool {
+	if app.sessionManager.GetBool(r.Context(), "rememberMe") {
+		return app.sessionManager.GetString(r.Context(), "userAgent") != r.UserAgent()
+	}
+
+	lastSeen := time.UnixMilli(app.sessionManager.GetInt64(r.Context(), "lastSeen"))
+	idle := time.Since(lastSeen)
+	if idle > app.sessionIdleTimeout {
+		return true
+	}
+
+	if idle > lastSeenInterval(app.sessionIdleTimeout) {
+		app.sessionManager.Put(r.Context(), "lastSeen", time.Now().UnixMilli())
+	}
+
+	return false
+}
+
+// lastSeenInterval returns how often the last seen time of a logged in
+// session is recorded, given the idle timeout of the sessions. The idle timeout
+// doesn't need to be checked with a per-request precision, so a minute is
+// enough, or less for short timeouts.
+func lastSeenInterval(idleTimeout time.Duration) time.Duration {
+	return min(time.Minute, idleTimeout/10)
+}
+
+// logOut renews the session token and removes the authentication data from
+// the session. The record of the logged in session must be deleted by the caller.
+func (app *application) logOut(r *http.Request) error {
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Remove(r.Context(), "sessionID")
+	app.sessionManager.Remove(r.Context(), "rememberMe")
+	app.sessionManager.Remove(r.Context(), "userAgent")
+	app.sessionManager.Remove(r.Context(), "lastSeen")
+
+	// Go back to a browser session cookie with the default lifetime.
+	app.sessionManager.RememberMe(r.Context(), false)
+	app.sessionManager.SetDeadline(r.Context(), time.Now().Add(app.sessionManager.Lifetime).UTC())
+
+	return nil
+}
+
+// isAuthenticated returns true if the current request is from an authenticated user,
+// otherwise returns false.
+func (app *application) isAuthenticated(r *http.Request) bool {
+	_, ok := app.authenticatedUser(r)
+	return ok
+}
+
+// apiToken returns the API token the request has been sent with, if any.
+func (app *application) apiToken(r *http.Request) (models.Token, bool) {
+	apiToken, ok := r.Context().Value(apiTokenContextKey).(models.Token)
+	return apiToken, ok
+}
+
+// authenticatedUser returns the record of the user authenticated by the
+// request, if any.
+func (app *application) authenticatedUser(r *http.Request) (models.User, bool) {
+	user, ok := r.Context().Value(authenticatedUserContextKey).(models.User)
+	return user, ok
+}
@@ -1,59 +1,166 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/AlessioPani/go-snippetbox/internal/webauthn"
+	"github.com/XSAM/otelsql"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form"
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/ncruces/go-sqlite3/driver"
 	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // application is a struct that contains the web application config.
 type application struct {
-	logger         *slog.Logger
-	snippets       models.SnippetModelInterface
-	users          models.UserModelInterface
-	templateCache  map[string]*template.Template
-	formDecoder    *form.Decoder
-	sessionManager *scs.SessionManager
+	logger             *slog.Logger
+	baseURL            string
+	snippets           models.SnippetModelInterface
+	users              models.UserModelInterface
+	stats              models.StatsModelInterface
+	reports            models.ReportModelInterface
+	audit              models.AuditModelInterface
+	actions            models.ModerationModelInterface
+	tokens             models.TokenModelInterface
+	webhooks           models.WebhookModelInterface
+	credentials        models.CredentialModelInterface
+	sessions           models.SessionModelInterface
+	metrics            *metrics
+	tracer             trace.Tracer
+	webAuthn           *webauthn.RelyingParty
+	loginThrottle      *loginThrottle
+	templateCache      map[string]*template.Template
+	formDecoder        *form.Decoder
+	sessionManager     *scs.SessionManager
+	sessionIdleTimeout time.Duration
+	rememberMeLifetime time.Duration
+	shutdownTimeout    time.Duration
+	queryTimeout       time.Duration
+	wg                 sync.WaitGroup
 }
 
 func main() {
-	// Get the app config by parsing command line parameters.
-	addr := flag.String("addr", ":8080", "HTTP Network Address")
-	dsn := flag.String("dsn", "./db-data/snippetbox.db", "Database dsn")
-	flag.Parse()
+	// Get the app config from the command line, the environment and the
+	// configuration file.
+	cfg, flags, err := loadConfig(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if errors.Is(err, errUsage) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	// Initialize a new structured logger with minimum level set to "debug".
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	}))
+	if cfg.printConfig {
+		err = writeConfig(os.Stdout, flags)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "cannot print the configuration:", err)
+			os.Exit(1)
+		}
+	}
 
-	// Initialize and configures a session manager based on cookies.
-	sessionManager := scs.New()
-	sessionManager.Lifetime = 12 * time.Hour
-	sessionManager.IdleTimeout = 20 * time.Minute
-	sessionManager.Cookie.Persist = true
-	sessionManager.Cookie.SameSite = http.SameSiteLaxMode
+	err = cfg.validate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n  %s\n", strings.ReplaceAll(err.Error(), "\n", "\n  "))
+		os.Exit(1)
+	}
+	if cfg.printConfig {
+		return
+	}
+
+	// Initialize a new structured logger with the configured minimum level
+	// and format.
+	logger := newLogger(os.Stdout, cfg.logFormat, cfg.logLevel)
+
+	// Initialize the tracing, whose spans are exported as configured.
+	tracerProvider, shutdownTracing, err := newTracerProvider(context.Background(), cfg.trace.exporter, cfg.trace.endpoint)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	tracer := tracerProvider.Tracer(tracerName)
 
-	// Connect to the database by instantiating a db connection pool.
-	db, err := openDB(*dsn)
+	// Connect to the database by instantiating a db connection pool, whose
+	// queries are traced.
+	dialect := dsnDialect(cfg.dsn)
+	db, err := openDB(cfg.dsn, tracerProvider)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
-	logger.Info("connected to the database", "dsn", *dsn)
+	logger.Info("connected to the database", "dialect", dialect, "dsn", redactDSN(cfg.dsn))
 	defer db.Close()
 
+	migrator, err := newMigrator(db, dialect)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Run the migrate subcommand instead of the server, if requested.
+	if flags.NArg() > 0 {
+		if flags.Arg(0) != "migrate" {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		err = runMigrate(migrator, flags.Args()[1:], os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Bring the database schema up to date, unless the migrations are applied
+	// separately with the migrate subcommand.
+	if cfg.migrate {
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			logger.Info("applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	} else {
+		pending, err := migrator.Pending()
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		if len(pending) > 0 {
+			logger.Warn("the database has pending migrations", "count", len(pending))
+		}
+	}
+
+	// Initialize and configures a session manager based on cookies, with the
+	// sessions stored in the database.
+	sessionManager := newSessionManager(newSessionStore(db, dialect, logger), cfg.session.lifetime)
+
 	// Fill the template cache.
 	templateCache, err := newTemplateCache()
 	if err != nil {
@@ -64,16 +171,56 @@ func main() {
 	// Initialize the form decoder.
 	formDecoder := form.NewDecoder()
 
+	webhooks := newWebhookModel(db, dialect)
+	sessions := newSessionModel(db, dialect, cfg.session.lifetime, cfg.session.idleTimeout, cfg.session.rememberMeLifetime)
+
+	// Initialize the metrics, with the statistics of the database pool and
+	// the count of the active sessions collected at each scrape.
+	metrics := newMetrics(collectors.NewDBStatsCollector(db, metricsNamespace), newSessionCollector(sessions))
+
 	// Initialize application config with all the dependencies.
 	app := &application{
-		logger:         logger,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
+		logger:             logger,
+		baseURL:            strings.TrimSuffix(cfg.baseURL, "/"),
+		snippets:           newSnippetModel(db, dialect),
+		users:              newUserModel(db, dialect),
+		stats:              newStatsModel(db, dialect),
+		reports:            newReportModel(db, dialect),
+		audit:              newAuditModel(db, dialect),
+		actions:            newModerationModel(db, dialect),
+		tokens:             newTokenModel(db, dialect),
+		webhooks:           webhooks,
+		credentials:        newCredentialModel(db, dialect),
+		sessions:           sessions,
+		metrics:            metrics,
+		tracer:             tracer,
+		webAuthn:           &webauthn.RelyingParty{ID: cfg.webAuthn.rpID, Name: "Snippetbox", Origin: cfg.webAuthn.rpOrigin},
+		loginThrottle:      newLoginThrottle(newLoginAttemptModel(db, dialect)),
+		templateCache:      templateCache,
+		formDecoder:        formDecoder,
+		sessionManager:     sessionManager,
+		sessionIdleTimeout: cfg.session.idleTimeout,
+		rememberMeLifetime: cfg.session.rememberMeLifetime,
+		shutdownTimeout:    cfg.server.shutdownTimeout,
+		queryTimeout:       cfg.queryTimeout,
 	}
 
+	// Stop on SIGINT (Ctrl-C) or SIGTERM (the stop target of the Makefile).
+	// Once the shutdown has started, a second signal terminates the
+	// application immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	// Start the workers delivering the events to the webhooks in background.
+	// They are stopped on shutdown, after the delivery they are making.
+	dispatcher := newWebhookDispatcher(webhooks, logger, cfg.webhookWorkers)
+	dispatcher.start()
+	app.background(func() {
+		<-ctx.Done()
+		dispatcher.stop()
+	})
+
 	// Create a TLS config struct, so only the elliptic curves with an assembly implementation are used.
 	// The others are very CPU intensive, so omitting them helps ensure that our server will remain performant
 	// under heavy loads.
@@ -82,31 +229,128 @@ func main() {
 		MinVersion:       tls.VersionTLS13,
 	}
 
+	// Load the TLS certificate, generated by using generate_cert.go.
+	// Command: go run /opt/homebrew/Cellar/go/1.23.4/libexec/src/crypto/tls/generate_cert.go --rsa-bits=2028 --host=localhost
+	certificate, err := tls.LoadX509KeyPair(cfg.tls.certFile, cfg.tls.keyFile)
+	if err != nil {
+		logger.Error("cannot load the TLS certificate", slog.String("tls-cert", cfg.tls.certFile), slog.String("tls-key", cfg.tls.keyFile), slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	tlsConfig.Certificates = []tls.Certificate{certificate}
+
 	// Get the configured mux.
 	mux := app.routes()
 
 	// Start the server using a self-signed TLS certificate and check for errors.
-	logger.Info("starting server", slog.String("addr", *addr))
-	server := http.Server{
-		Addr:         *addr,
+	server := &http.Server{
+		Addr:         cfg.addr,
 		Handler:      mux,
 		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		TLSConfig:    tlsConfig,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  cfg.server.idleTimeout,
+		ReadTimeout:  cfg.server.readTimeout,
+		WriteTimeout: cfg.server.writeTimeout,
 	}
 
-	// TLS certificates generated by using generate_cert.go.
-	// Command: go run /opt/homebrew/Cellar/go/1.23.4/libexec/src/crypto/tls/generate_cert.go --rsa-bits=2028 --host=localhost
-	err = server.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	logger.Error(err.Error())
-	os.Exit(1)
+	ln, err := net.Listen("tcp", cfg.addr)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	logger.Info("starting server", slog.String("addr", cfg.addr))
+
+	// Serve the metrics in plain HTTP on the admin address, which is meant to
+	// be reachable by the monitoring system only, unless it is disabled.
+	if cfg.adminAddr != "" {
+		adminServer := &http.Server{
+			Addr:         cfg.adminAddr,
+			Handler:      app.adminRoutes(),
+			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+			IdleTimeout:  cfg.server.idleTimeout,
+			ReadTimeout:  cfg.server.readTimeout,
+			WriteTimeout: cfg.server.writeTimeout,
+		}
+
+		adminLn, err := net.Listen("tcp", cfg.adminAddr)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		logger.Info("starting admin server", slog.String("addr", cfg.adminAddr))
+
+		app.serveAdmin(ctx, adminServer, adminLn)
+	}
+
+	err = app.serve(ctx, server, ln)
+
+	// Release the database, whether the server has stopped or failed.
+	sessionManager.Store.(sessionStore).StopCleanup()
+	db.Close()
+
+	// Export the last spans.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.server.shutdownTimeout)
+	defer cancel()
+	tracingErr := shutdownTracing(shutdownCtx)
+	if tracingErr != nil {
+		logger.Error("cannot export the last spans", slog.String("error", tracingErr.Error()))
+	}
+
+	if err != nil {
+		logger.Error(err.Error())
+		os.Stdout.Sync()
+		os.Exit(1)
+	}
+
+	logger.Info("stopped server")
+
+	// Flush the logs, in case the standard output is redirected to a file.
+	os.Stdout.Sync()
+}
+
+// newLogger returns a structured logger writing to w the entries of at least
+// the level, in JSON if the format is "json" or as key=value pairs otherwise.
+func newLogger(w io.Writer, format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts))
 }
 
-// openDB open a connection pool on Sqlite based on the DSN.
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", dsn)
+// newSessionManager returns a session manager based on cookies which keeps the
+// sessions in the database through store, so they survive a restart of the
+// application and can be shared between many instances of it.
+// The idle timeout isn't set on the session manager, as it would apply to
+// remembered sessions too: it is enforced by the authenticate middleware for
+// normal logins only. For the same reason the cookie is only persisted for the
+// users who asked to be remembered.
+func newSessionManager(store sessionStore, lifetime time.Duration) *scs.SessionManager {
+	sessionManager := scs.New()
+	sessionManager.Store = store
+	sessionManager.Lifetime = lifetime
+	sessionManager.Cookie.Persist = false
+	sessionManager.Cookie.SameSite = http.SameSiteLaxMode
+
+	return sessionManager
+}
+
+// openDB open a connection pool on the database of the DSN, SQLite or
+// PostgreSQL depending on its scheme (see dsnDialect). The schema of the
+// database is managed by the migrations. Each query, whichever model or store
+// runs it, is traced in a span of the tracer provider.
+func openDB(dsn string, tp trace.TracerProvider) (*sql.DB, error) {
+	dialect := dsnDialect(dsn)
+	db, err := otelsql.Open(drivers[dialect], dsn,
+		otelsql.WithTracerProvider(tp),
+		otelsql.WithAttributes(dbSystems[dialect]),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -118,11 +362,5 @@ func openDB(dsn string) (*sql.DB, error) {
 		return nil, err
 	}
 
-	// Verify if the required tables are in the database.
-	err = checkTables(db)
-	if err != nil {
-		return nil, err
-	}
-
 	return db, nil
 }
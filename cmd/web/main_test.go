@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/migrate"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func TestSessionSurvivesRestart(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	dsn := filepath.Join(t.TempDir(), "snippetbox.db")
+
+	// start runs an instance of the application on a real database, with the
+	// production session manager, and returns a function to stop it.
+	start := func(t *testing.T) (*testServer, *sql.DB, func()) {
+		db := openTestDB(t, dsn)
+
+		app := newTestApplication(t)
+		app.sessionManager = newSessionManager(newSessionStore(db, migrate.SQLite, app.logger), 12*time.Hour)
+		app.users = &models.UserModel{DB: db}
+		app.sessions = newSessionModel(db, migrate.SQLite, app.sessionManager.Lifetime, app.sessionIdleTimeout, app.rememberMeLifetime)
+
+		ts := newTestServer(t, app.routes())
+
+		stop := func() {
+			ts.Close()
+			app.sessionManager.Store.(sessionStore).StopCleanup()
+			db.Close()
+		}
+
+		return ts, db, stop
+	}
+
+	// First run: create a user and log in.
+	ts, db, stop := start(t)
+
+	err := (&models.UserModel{DB: db}).Insert(context.Background(), "John Doe", "test@test.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "test@test.com")
+	form.Add("password", "password")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+
+	jar := ts.client.Jar
+	stop()
+
+	// Second run: the same browser is still logged in. Cookies don't depend
+	// on the port, so the cookie jar can be reused with the new server.
+	ts, _, stop = start(t)
+	defer stop()
+	ts.client.Jar = jar
+
+	code, _, _ = ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace is the prefix of the names of the metrics of the
+// application.
+const metricsNamespace = "snippetbox"
+
+// metrics contains the Prometheus metrics of the application, in a registry
+// of its own which also collects the Go runtime and process metrics.
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	responseSize    *prometheus.HistogramVec
+	snippetsCreated prometheus.Counter
+}
+
+// newMetrics returns the metrics of the application, registered along with
+// the other collectors, such as the statistics of the database pool.
+func newMetrics(cs ...prometheus.Collector) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests served, by route pattern and status code.",
+		}, []string{"route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve the HTTP requests, by route pattern and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "status"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_response_size_bytes",
+			Help:      "Size of the bodies of the HTTP responses, by route pattern and status code.",
+			Buckets:   prometheus.ExponentialBuckets(100, 10, 6),
+		}, []string{"route", "status"}),
+		snippetsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "snippets_created_total",
+			Help:      "Number of snippets created, from the web pages or the API.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.responseSize,
+		m.snippetsCreated,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	m.registry.MustRegister(cs...)
+
+	return m
+}
+
+// handler returns the handler of the /metrics endpoint, which writes the
+// metrics in the Prometheus text exposition format. A failed collector, such
+// as the count of the sessions when the database is down, is logged and left
+// out, so that the other metrics can still be scraped.
+func (m *metrics) handler(logger *slog.Logger) http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorLog:      slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// sessionCollector collects the number of active login sessions from the
+// database at each scrape.
+type sessionCollector struct {
+	sessions models.SessionModelInterface
+	desc     *prometheus.Desc
+}
+
+// newSessionCollector returns a collector of the active sessions of the model.
+func newSessionCollector(sessions models.SessionModelInterface) *sessionCollector {
+	return &sessionCollector{
+		sessions: sessions,
+		desc:     prometheus.NewDesc(metricsNamespace+"_sessions_active", "Number of active login sessions.", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *sessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *sessionCollector) Collect(ch chan<- prometheus.Metric) {
+	count, err := c.sessions.Count()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count))
+}
+
+// instrumentRequest is a middleware which records the count, the duration and
+// the response size of the requests, by route. The route is the one resolved
+// by the routeRequest middleware, or "unmatched" if no pattern matches.
+func (app *application) instrumentRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeFromContext(r.Context())
+
+		start := time.Now()
+		rw := newResponseWriter(w)
+
+		next.ServeHTTP(rw, r)
+
+		status := strconv.Itoa(rw.status)
+		app.metrics.requests.WithLabelValues(route, status).Inc()
+		app.metrics.requestDuration.WithLabelValues(route, status).Observe(time.Since(start).Seconds())
+		app.metrics.responseSize.WithLabelValues(route, status).Observe(float64(rw.size))
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	admin := httptest.NewServer(app.adminRoutes())
+	defer admin.Close()
+
+	token, err := app.tokens.Insert(1, "Test", []string{models.ScopeSnippetsWrite}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = app.sessions.Insert(1, "Firefox", "127.0.0.1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts.get(t, "/ping")
+	ts.get(t, "/ping")
+	ts.get(t, "/snippet/view/1/")
+	ts.get(t, "/snippet/view/99/")
+	ts.get(t, "/missing")
+	code, _, _ := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", token, `{"title": "Title", "content": "Content", "expires": 7}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	// The metrics are served in the text exposition format by the admin
+	// server, which isn't instrumented itself.
+	code, headers, body := (&testServer{admin, admin.Client()}).get(t, "/metrics")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, headers.Get("Content-Type"), "text/plain")
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Requests", `snippetbox_http_requests_total{route="/ping",status="200"} 2`},
+		{"Route pattern", `snippetbox_http_requests_total{route="GET /snippet/view/{id}/",status="200"} 1`},
+		{"Status", `snippetbox_http_requests_total{route="GET /snippet/view/{id}/",status="404"} 1`},
+		{"Unmatched route", `snippetbox_http_requests_total{route="unmatched",status="404"} 1`},
+		{"Latency", `snippetbox_http_request_duration_seconds_count{route="/ping",status="200"} 2`},
+		{"Response size", `snippetbox_http_response_size_bytes_sum{route="/ping",status="200"} 4`},
+		{"Snippets created", "snippetbox_snippets_created_total 1"},
+		{"Sessions", "snippetbox_sessions_active 1"},
+		{"Go runtime", "go_goroutines "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.StringContains(t, body, tt.want)
+		})
+	}
+}
@@ -2,9 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/AlessioPani/go-snippetbox/internal/models"
 	"github.com/justinas/nosurf"
 )
 
@@ -26,17 +34,65 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
-// logRequest is a middleware that logs each request.
+// routeRequest returns a middleware that resolves the pattern of mux matching
+// each request once, and stores it in the request context for the metrics
+// and the tracing of the request.
+func routeRequest(mux *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), routeContextKey, routePattern(mux, r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requestIDRX matches the request IDs accepted from the clients or the proxies
+// in the X-Request-ID header, which are logged as they are.
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID is a middleware that identifies each request with the ID of its
+// X-Request-ID header, set by a proxy for instance, or a random one if it has
+// none or an invalid one. The ID is stored in the request context, for the
+// logs, and sent back in the X-Request-ID header of the response.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			b := make([]byte, 16)
+			_, err := rand.Read(b)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// logRequest is a middleware that logs each request once it has been served,
+// with the status code, the size of the body and the duration of the response.
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
-		proto := r.Proto
-		method := r.Method
-		uri := r.RequestURI
+		start := time.Now()
+		rw := newResponseWriter(w)
 
-		app.logger.Info("received request", "ip", ip, "proto", proto, "method", method, "uri", uri)
+		next.ServeHTTP(rw, r)
 
-		next.ServeHTTP(w, r)
+		app.logger.Info("served request",
+			slog.String("ip", r.RemoteAddr),
+			slog.String("proto", r.Proto),
+			slog.String("method", r.Method),
+			slog.String("uri", r.RequestURI),
+			slog.Int("status", rw.status),
+			slog.Int("size", rw.size),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("request_id", requestIDFromContext(r.Context())),
+		)
 	})
 }
 
@@ -94,6 +150,184 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// errInvalidAuthorization is returned by bearerToken when the Authorization
+// header doesn't use the Bearer scheme.
+var errInvalidAuthorization = errors.New("authorization: not a bearer token")
+
+// bearerToken authenticates the API token sent in the "Authorization: Bearer"
+// header. It returns false if there is no Authorization header,
+// errInvalidAuthorization if it isn't a Bearer one and
+// models.ErrInvalidCredentials if the token isn't valid.
+func (app *application) bearerToken(r *http.Request) (models.Token, bool, error) {
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return models.Token{}, false, nil
+	}
+
+	scheme, token, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return models.Token{}, false, errInvalidAuthorization
+	}
+
+	apiToken, err := app.tokens.Authenticate(strings.TrimSpace(token))
+	if err != nil {
+		return models.Token{}, false, err
+	}
+
+	return apiToken, true, nil
+}
+
+// bearerChallenge returns the WWW-Authenticate challenge sent back along with
+// a 401 Unauthorized response for an error of bearerToken, or false if the
+// error isn't about the credentials.
+func bearerChallenge(err error) (string, bool) {
+	switch {
+	case errors.Is(err, errInvalidAuthorization):
+		return `Bearer error="invalid_request"`, true
+	case errors.Is(err, models.ErrInvalidCredentials):
+		return `Bearer error="invalid_token"`, true
+	default:
+		return "", false
+	}
+}
+
+// authenticateToken is a middleware that checks the API token sent in the
+// "Authorization: Bearer" header, if any, and stores it in the request context.
+// The user isn't authenticated yet: that is done by requireScope, on the
+// routes which accept API tokens. Requests with an invalid token are rejected.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiToken, ok, err := app.bearerToken(r)
+		if err != nil {
+			if challenge, ok := bearerChallenge(err); ok {
+				w.Header().Set("WWW-Authenticate", challenge)
+				app.clientError(w, http.StatusUnauthorized)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiTokenContextKey, apiToken)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireAPIScope is the middleware of the JSON API, which is used with API
+// tokens only: it authenticates the user of the token sent with the request,
+// as long as it has been granted the scope. Errors are sent back as JSON.
+func (app *application) requireAPIScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiToken, ok, err := app.bearerToken(r)
+			if err != nil {
+				if challenge, ok := bearerChallenge(err); ok {
+					w.Header().Set("WWW-Authenticate", challenge)
+					app.apiClientError(w, r, http.StatusUnauthorized)
+				} else {
+					app.apiServerError(w, r, err)
+				}
+				return
+			}
+
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				app.apiClientError(w, r, http.StatusUnauthorized)
+				return
+			}
+
+			if !apiToken.HasScope(scope) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+				app.apiClientError(w, r, http.StatusForbidden)
+				return
+			}
+
+			user, err := app.getUser(r, apiToken.UserID)
+			if err != nil {
+				if errors.Is(err, models.ErrNoRecord) {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					app.apiClientError(w, r, http.StatusUnauthorized)
+				} else {
+					app.apiServerError(w, r, err)
+				}
+				return
+			}
+
+			if user.Suspended {
+				app.apiClientError(w, r, http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), apiTokenContextKey, apiToken)
+			ctx = context.WithValue(ctx, authenticatedUserContextKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requireScope is a middleware that lets the requests sent with an API token
+// through only if the token has been granted the scope, authenticating its
+// user. It must come before requireAuthentication in a chain, which then
+// accepts API tokens. Requests without an API token are left untouched.
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiToken, ok := app.apiToken(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !apiToken.HasScope(scope) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+				app.clientError(w, http.StatusForbidden)
+				return
+			}
+
+			user, err := app.getUser(r, apiToken.UserID)
+			if err != nil {
+				if errors.Is(err, models.ErrNoRecord) {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					app.clientError(w, http.StatusUnauthorized)
+				} else {
+					app.serverError(w, r, err)
+				}
+				return
+			}
+
+			if user.Suspended {
+				app.clientError(w, http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), authenticatedUserContextKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requireRole is a middleware that only lets through the authenticated users
+// having one of the roles. The others get a 403 Forbidden response. It is
+// meant to be appended to a chain requiring authentication.
+func (app *application) requireRole(roles ...models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := app.authenticatedUser(r)
+			if !ok || !user.HasRole(roles...) {
+				app.clientError(w, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // authenticate is a middleware that store in the session context
 // whether a user is autenticated or not.
 func (app *application) authenticate(next http.Handler) http.Handler {
@@ -104,28 +338,68 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// call the next handler in the chain as normal and return.
 		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
 
-		if id == 0 {
+		// The requests sent with an API token are authenticated by the token
+		// only, on the routes which accept it.
+		if _, ok := app.apiToken(r); ok || id == 0 {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Otherwise, we check to see if a user with that ID exists in our
-		// database.
-		exists, err := app.users.Exists(id)
+		// Otherwise, we get the user with that ID from our database, checking
+		// that they still exist.
+		user, err := app.getUser(r, id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				next.ServeHTTP(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		// Then we check that the logged in session hasn't been revoked from
+		// another device, updating its last seen time every now and then. A
+		// revoked session is logged out. The same happens to sessions created
+		// before the sessions were recorded, as they don't have a sessionID.
+		sessionID := app.sessionManager.GetString(r.Context(), "sessionID")
+		active, err := app.sessions.Touch(sessionID, id, clientIP(r))
 		if err != nil {
 			app.serverError(w, r, err)
 			return
 		}
 
-		// If a matching user is found, we know that the request is
-		// coming from an authenticated user who exists in our database. We
-		// create a new copy of the request (with an isAuthenticatedContextKey
-		// value of true in the request context) and assign it to r.
-		if exists {
-			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
-			r = r.WithContext(ctx)
+		// A session which has expired, because it has been idle for too long
+		// or it's a remembered session used with another User-Agent, is revoked
+		// and logged out too, as well as the sessions of suspended users.
+		if active && (user.Suspended || app.sessionExpired(r)) {
+			err = app.sessions.Delete(sessionID, id)
+			if err != nil && !errors.Is(err, models.ErrNoRecord) {
+				app.serverError(w, r, err)
+				return
+			}
+			active = false
 		}
 
+		if !active {
+			err = app.logOut(r)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			if user.Suspended {
+				app.sessionManager.Put(r.Context(), "flash", "Your account has been suspended.")
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The request is coming from an authenticated user who exists in our
+		// database. We create a new copy of the request (with the user record
+		// as authenticatedUserContextKey value in the request context) and
+		// assign it to r.
+		ctx := context.WithValue(r.Context(), authenticatedUserContextKey, user)
+		r = r.WithContext(ctx)
+
 		// Call the next handler in the chain.
 		next.ServeHTTP(w, r)
 	})
@@ -141,5 +415,51 @@ func noSurf(next http.Handler) http.Handler {
 		Secure:   true,
 	})
 
+	// The requests sent with a valid API token don't rely on cookies, so they
+	// can't be forged by another site and don't need a CSRF token.
+	csrfHandler.ExemptFunc(func(r *http.Request) bool {
+		_, ok := r.Context().Value(apiTokenContextKey).(models.Token)
+		return ok
+	})
+
 	return csrfHandler
 }
+
+// responseWriter wraps a http.ResponseWriter to record the status code and
+// the size of the body of the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+// newResponseWriter returns a responseWriter wrapping w, with the status
+// code set to 200 as when the handler only writes the body.
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+// WriteHeader records the status code of the response. The informational
+// 1xx responses, which may precede it, are ignored.
+func (rw *responseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader && status >= 200 {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the number of bytes written to the body of the response.
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+	return n, err
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, so that
+// http.ResponseController can flush it.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
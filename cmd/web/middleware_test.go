@@ -2,12 +2,22 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
 )
 
 func TestCommonHeaders(t *testing.T) {
@@ -63,3 +73,269 @@ func TestCommonHeaders(t *testing.T) {
 	assert.Equal(t, string(body), "OK")
 
 }
+
+func TestRequireRole(t *testing.T) {
+	app := newTestApplication(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	// Moderators and admins can go through the middleware.
+	handler := app.requireRole(models.RoleModerator, models.RoleAdmin)(next)
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		wantCode int
+	}{
+		{
+			name:     "Not authenticated",
+			user:     nil,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "User",
+			user:     &models.User{ID: 1, Role: models.RoleUser},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Moderator",
+			user:     &models.User{ID: 2, Role: models.RoleModerator},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Admin",
+			user:     &models.User{ID: 3, Role: models.RoleAdmin},
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.user != nil {
+				r = r.WithContext(context.WithValue(r.Context(), authenticatedUserContextKey, *tt.user))
+			}
+
+			handler.ServeHTTP(rw, r)
+
+			assert.Equal(t, rw.Code, tt.wantCode)
+		})
+	}
+}
+
+// countingStore counts the sessions saved to a session store.
+type countingStore struct {
+	scs.Store
+	commits atomic.Int32
+}
+
+func (s *countingStore) Commit(token string, b []byte, expiry time.Time) error {
+	s.commits.Add(1)
+	return s.Store.Commit(token, b, expiry)
+}
+
+func TestAuthenticateSessionWrites(t *testing.T) {
+	app := newTestApplication(t)
+	store := &countingStore{Store: memstore.New()}
+	app.sessionManager.Store = store
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "test@test.com")
+	form.Add("password", "password")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The first page shows the flash message, which is removed from the
+	// session.
+	code, _, _ = ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+
+	// Then the session was seen recently enough not to be saved again.
+	commits := store.commits.Load()
+	for range 3 {
+		code, _, _ = ts.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusOK)
+	}
+	assert.Equal(t, store.commits.Load(), commits)
+}
+
+func TestResponseWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantSize   int
+	}{
+		{
+			name:       "Body only",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("OK")) },
+			wantStatus: http.StatusOK,
+			wantSize:   2,
+		},
+		{
+			name: "Status and body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+				w.Write([]byte("short"))
+				w.Write([]byte(" and stout"))
+			},
+			wantStatus: http.StatusTeapot,
+			wantSize:   15,
+		},
+		{
+			name: "Informational response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusEarlyHints)
+				w.WriteHeader(http.StatusNoContent)
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "Superfluous status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK"))
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			wantStatus: http.StatusOK,
+			wantSize:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := newResponseWriter(httptest.NewRecorder())
+
+			tt.handler(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, rw.status, tt.wantStatus)
+			assert.Equal(t, rw.size, tt.wantSize)
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{"Propagated", "3f2a-proxy.id:1", true},
+		{"Missing", "", false},
+		{"Invalid", "not an id", false},
+		{"Too long", strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				r.Header.Set("X-Request-ID", tt.header)
+			}
+
+			var id string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id = requestIDFromContext(r.Context())
+			})
+
+			requestID(next).ServeHTTP(rw, r)
+
+			assert.Equal(t, rw.Header().Get("X-Request-ID"), id)
+			assert.Equal(t, id == tt.header, tt.wantSame)
+			if !tt.wantSame {
+				assert.Equal(t, len(id), 32)
+			}
+		})
+	}
+}
+
+func TestLogRequest(t *testing.T) {
+	app := newTestApplication(t)
+
+	var logs bytes.Buffer
+	app.logger = newLogger(&logs, "json", slog.LevelInfo)
+
+	type logEntry struct {
+		Level     string
+		Msg       string
+		Method    string
+		URI       string
+		Status    int
+		Size      int
+		Duration  time.Duration
+		RequestID string `json:"request_id"`
+	}
+
+	// decodeLogs returns the log entries written since the last call.
+	decodeLogs := func(t *testing.T) []logEntry {
+		var entries []logEntry
+		dec := json.NewDecoder(&logs)
+		for dec.More() {
+			var entry logEntry
+			err := dec.Decode(&entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+
+	t.Run("Served request", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		r.Header.Set("X-Request-ID", "ping-1")
+
+		app.routes().ServeHTTP(httptest.NewRecorder(), r)
+
+		entries := decodeLogs(t)
+		assert.Equal(t, len(entries), 1)
+		assert.Equal(t, entries[0].Msg, "served request")
+		assert.Equal(t, entries[0].Method, http.MethodGet)
+		assert.Equal(t, entries[0].URI, "/ping")
+		assert.Equal(t, entries[0].Status, http.StatusOK)
+		assert.Equal(t, entries[0].Size, len("OK"))
+		assert.Equal(t, entries[0].RequestID, "ping-1")
+		assert.Equal(t, entries[0].Duration > 0, true)
+	})
+
+	t.Run("Server error", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/panic", nil)
+		r.Header.Set("X-Request-ID", "panic-1")
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("something went wrong")
+		})
+
+		requestID(app.logRequest(app.recoverPanic(next))).ServeHTTP(rw, r)
+
+		// The user is shown the ID of the request, to report it.
+		assert.Equal(t, rw.Code, http.StatusInternalServerError)
+		assert.StringContains(t, rw.Body.String(), "Request ID: panic-1")
+
+		entries := decodeLogs(t)
+		assert.Equal(t, len(entries), 2)
+		assert.Equal(t, entries[0].Level, "ERROR")
+		assert.Equal(t, entries[0].Msg, "something went wrong")
+		assert.Equal(t, entries[0].RequestID, "panic-1")
+		assert.Equal(t, entries[1].Status, http.StatusInternalServerError)
+		assert.Equal(t, entries[1].RequestID, "panic-1")
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/AlessioPani/go-snippetbox/internal/migrate"
+	"github.com/AlessioPani/go-snippetbox/migrations"
+)
+
+// migrateUsage is the help message of the migrate subcommand.
+const migrateUsage = `usage: web [flags] migrate <command>
+
+Commands:
+  status         list the migrations and whether they have been applied
+  up             apply the pending migrations
+  down           revert the last applied migration
+  force VERSION  record the migrations up to VERSION as applied, without running them`
+
+// legacyColumns contains the columns added to the tables created by
+// checkTables, before the migrations, so that they can be added to the
+// databases which lack them when they are baselined.
+var legacyColumns = []struct {
+	table      string
+	name       string
+	definition string
+}{
+	{table: "user_sessions", name: "remember_me", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "users", name: "role", definition: "VARCHAR(20) NOT NULL DEFAULT 'user'"},
+	{table: "users", name: "suspended", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "snippets", name: "user_id", definition: "INTEGER REFERENCES users(id) ON DELETE SET NULL"},
+	{table: "snippets", name: "hidden", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "snippets", name: "language", definition: "VARCHAR(30) NOT NULL DEFAULT ''"},
+}
+
+// newMigrator returns the migrator of the application database, with the
+// embedded migrations of its dialect.
+func newMigrator(db *sql.DB, dialect migrate.Dialect) (*migrate.Migrator, error) {
+	files, err := migrations.Dialect(string(dialect))
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.New(db, dialect, files)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the SQLite databases have been created before the migrations.
+	if dialect == migrate.SQLite {
+		m.Baseline = func(tx *sql.Tx) (int, error) {
+			return baseline(tx, files)
+		}
+	}
+
+	return m, nil
+}
+
+// baseline detects a SQLite database created by checkTables, before the
+// migrations, from its users table. The columns and the tables added since it
+// was created are added to it, so it is at version 1, the initial schema read
+// from files.
+func baseline(tx *sql.Tx, files fs.FS) (int, error) {
+	var exists bool
+
+	query := "SELECT EXISTS(SELECT true FROM sqlite_master WHERE type = 'table' AND name = 'users')"
+	err := tx.QueryRow(query).Scan(&exists)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	for _, column := range legacyColumns {
+		var tableExists, columnExists bool
+
+		query := `SELECT EXISTS(SELECT true FROM pragma_table_info(?)),
+				  EXISTS(SELECT true FROM pragma_table_info(?) WHERE name = ?)`
+		err := tx.QueryRow(query, column.table, column.table, column.name).Scan(&tableExists, &columnExists)
+		if err != nil {
+			return 0, err
+		}
+
+		// The missing tables are created below, with all their columns.
+		if tableExists && !columnExists {
+			_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", column.table, column.name, column.definition))
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	initial, err := fs.ReadFile(files, "0001_initial.up.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(string(initial))
+	if err != nil {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+// runMigrate runs the migrate subcommand with its arguments, writing its
+// output to w.
+func runMigrate(m *migrate.Migrator, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch {
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied.Valid {
+				applied = s.Applied.Time.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+
+	case args[0] == "up" && len(args) == 1:
+		applied, err := m.Up()
+		for _, migration := range applied {
+			fmt.Fprintf(w, "Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(w, "No pending migration")
+		}
+		return err
+
+	case args[0] == "down" && len(args) == 1:
+		migration, err := m.Down()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Reverted %04d_%s\n", migration.Version, migration.Name)
+		return nil
+
+	case args[0] == "force" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return errors.New(migrateUsage)
+		}
+
+		err = m.Force(version)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Forced version %04d\n", version)
+		return nil
+
+	default:
+		return errors.New(migrateUsage)
+	}
+}
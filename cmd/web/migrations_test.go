@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/migrate"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := openDB(filepath.Join(t.TempDir(), "snippetbox.db"), noop.NewTracerProvider())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create the database as the first versions of checkTables did.
+	_, err = db.Exec(`
+		CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name VARCHAR(255) NOT NULL,
+			email VARCHAR(255) NOT NULL,
+			hashed_password CHAR(60) NOT NULL,
+			created DATETIME NOT NULL,
+			CONSTRAINT uc_email UNIQUE (email)
+		);
+		CREATE TABLE snippets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title VARCHAR(255) NOT NULL,
+			content VARCHAR(255) NOT NULL,
+			created DATETIME NOT NULL,
+			expires DATETIME NOT NULL
+		);
+		INSERT INTO snippets (title, content, created, expires)
+		VALUES ('Legacy', 'Content', datetime(), datetime('now', '+7 days'));`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := newMigrator(db, migrate.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The database is baselined at the initial schema, so only the later
+	// migrations are applied.
+	var out bytes.Buffer
+	err = runMigrate(migrator, []string{"up"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, out.String(), "Applied 0002_snippets_updated\n")
+
+	out.Reset()
+	err = runMigrate(migrator, []string{"status"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.StringContains(t, out.String(), "VERSION  NAME              APPLIED")
+	assert.StringContains(t, out.String(), "0001     initial           20")
+	assert.StringContains(t, out.String(), "0002     snippets_updated  20")
+
+	// The missing columns and tables have been added, and the data kept.
+	snippets := &models.SnippetModel{DB: db}
+	snippet, err := snippets.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.Title, "Legacy")
+	assert.Equal(t, snippet.Updated.Equal(snippet.Created), true)
+
+	users := &models.UserModel{DB: db}
+	err = users.Insert(context.Background(), "John Doe", "test@test.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhooks := &models.WebhookModel{DB: db}
+	_, err = webhooks.Insert(1, "https://example.com/hook", "0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := openDB(filepath.Join(t.TempDir(), "snippetbox.db"), noop.NewTracerProvider())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator, err := newMigrator(db, migrate.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantOut string
+		wantErr string
+	}{
+		{name: "Status of a new database", args: []string{"status"}, wantOut: "0001     initial           pending\n0002     snippets_updated  pending\n"},
+		{name: "Up", args: []string{"up"}, wantOut: "Applied 0001_initial\nApplied 0002_snippets_updated\n"},
+		{name: "Up to date", args: []string{"up"}, wantOut: "No pending migration\n"},
+		{name: "Down", args: []string{"down"}, wantOut: "Reverted 0002_snippets_updated\n"},
+		{name: "Down to the initial schema", args: []string{"down"}, wantOut: "Reverted 0001_initial\n"},
+		{name: "Nothing to revert", args: []string{"down"}, wantErr: "migrate: no such migration"},
+		{name: "Force", args: []string{"force", "1"}, wantOut: "Forced version 0001\n"},
+		{name: "Force an unknown version", args: []string{"force", "9"}, wantErr: "migrate: no such migration: version 9"},
+		{name: "Unknown command", args: []string{"redo"}, wantErr: "usage: web [flags] migrate <command>"},
+		{name: "Missing version", args: []string{"force"}, wantErr: "usage: web [flags] migrate <command>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runMigrate(migrator, tt.args, &out)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.StringContains(t, out.String(), tt.wantOut)
+		})
+	}
+
+	// The forced version hasn't created the tables.
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS(SELECT true FROM sqlite_master WHERE name = 'users')").Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, exists, false)
+}
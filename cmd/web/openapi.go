@@ -0,0 +1,228 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/AlessioPani/go-snippetbox/ui"
+)
+
+// openAPISpec returns the OpenAPI description of the JSON API. The schemas
+// are generated from the types the API handlers encode and decode, so they
+// can't get out of date.
+func openAPISpec() map[string]any {
+	schemas := map[string]any{
+		"Snippet":      jsonSchema(apiSnippet{}),
+		"SnippetInput": jsonSchema(snippetCreateForm{}),
+		"SnippetList":  jsonSchema(apiSnippetListResponse{}),
+		"SnippetItem":  jsonSchema(apiSnippetResponse{}),
+		"Error":        jsonSchema(apiErrorResponse{}),
+	}
+
+	// The schema of a snippet input accepts only the permitted values.
+	schemas["SnippetInput"].(map[string]any)["properties"].(map[string]any)["expires"] = map[string]any{
+		"type":        "integer",
+		"enum":        []int{1, 7, 365},
+		"description": "Number of days the snippet is valid for.",
+	}
+
+	id := map[string]any{
+		"name": "id", "in": "path", "required": true,
+		"schema": map[string]any{"type": "integer", "minimum": 1},
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Snippetbox API",
+			"version":     "1.0.0",
+			"description": "JSON API to manage the snippets. Requests are authenticated with a personal API token, created from the account page and sent in the \"Authorization: Bearer\" header.",
+		},
+		"servers": []any{map[string]any{"url": "/api/v1"}},
+		"paths": map[string]any{
+			"/openapi.json": map[string]any{
+				"get": map[string]any{
+					"operationId": "getOpenAPI",
+					"summary":     "Get this OpenAPI description",
+					"security":    []any{},
+					"responses": map[string]any{
+						"200": map[string]any{"description": "The OpenAPI description of the API."},
+					},
+				},
+			},
+			"/snippets": map[string]any{
+				"get": map[string]any{
+					"operationId": "listSnippets",
+					"summary":     "List the valid snippets, newest first",
+					"security":    apiSecurity(models.ScopeSnippetsRead),
+					"parameters": []any{
+						map[string]any{
+							"name": "page", "in": "query",
+							"schema": map[string]any{"type": "integer", "minimum": 1, "default": 1},
+						},
+						map[string]any{
+							"name": "page_size", "in": "query",
+							"schema": map[string]any{"type": "integer", "minimum": 1, "maximum": apiMaxPageSize, "default": apiDefaultPageSize},
+						},
+					},
+					"responses": apiResponses(http.StatusOK, "A page of snippets.", "SnippetList",
+						http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusUnprocessableEntity),
+				},
+				"post": map[string]any{
+					"operationId": "createSnippet",
+					"summary":     "Create a snippet",
+					"security":    apiSecurity(models.ScopeSnippetsWrite),
+					"requestBody": apiRequestBody("SnippetInput"),
+					"responses": apiResponses(http.StatusCreated, "The created snippet.", "SnippetItem",
+						http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+				},
+			},
+			"/snippets/{id}": map[string]any{
+				"get": map[string]any{
+					"operationId": "getSnippet",
+					"summary":     "Get a valid snippet",
+					"security":    apiSecurity(models.ScopeSnippetsRead),
+					"parameters":  []any{id},
+					"responses": apiResponses(http.StatusOK, "The snippet.", "SnippetItem",
+						http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound),
+				},
+				"put": map[string]any{
+					"operationId": "updateSnippet",
+					"summary":     "Replace a snippet of the user, which then expires from now",
+					"security":    apiSecurity(models.ScopeSnippetsWrite),
+					"parameters":  []any{id},
+					"requestBody": apiRequestBody("SnippetInput"),
+					"responses": apiResponses(http.StatusOK, "The updated snippet.", "SnippetItem",
+						http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+				},
+				"delete": map[string]any{
+					"operationId": "deleteSnippet",
+					"summary":     "Delete a snippet of the user",
+					"security":    apiSecurity(models.ScopeSnippetsWrite),
+					"parameters":  []any{id},
+					"responses": apiResponses(http.StatusNoContent, "The snippet has been deleted.", "",
+						http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound),
+				},
+			},
+		},
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Personal API token. The scopes required by each operation are listed in its security requirement.",
+				},
+			},
+		},
+	}
+}
+
+// apiSecurity returns the security requirement of an operation which needs
+// an API token with the scope.
+func apiSecurity(scope string) []any {
+	return []any{map[string]any{"bearerAuth": []string{scope}}}
+}
+
+// apiRequestBody returns a required JSON request body with the schema.
+func apiRequestBody(schema string) map[string]any {
+	return map[string]any{
+		"required": true,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemaRef(schema)},
+		},
+	}
+}
+
+// apiResponses returns the responses of an operation: the successful one with
+// the status, description and schema, if any, and the errors with the
+// statuses, which share the Error schema.
+func apiResponses(status int, description string, schema string, errorStatuses ...int) map[string]any {
+	success := map[string]any{"description": description}
+	if schema != "" {
+		success["content"] = map[string]any{
+			"application/json": map[string]any{"schema": schemaRef(schema)},
+		}
+	}
+
+	responses := map[string]any{strconv.Itoa(status): success}
+	for _, s := range errorStatuses {
+		responses[strconv.Itoa(s)] = map[string]any{
+			"description": http.StatusText(s),
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": schemaRef("Error")},
+			},
+		}
+	}
+	return responses
+}
+
+// schemaRef returns a reference to a schema of the components.
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// timeType is the reflect.Type of time.Time, encoded as a string in JSON.
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema returns the JSON schema of the JSON encoding of v, following the
+// json struct tags. The fields without omitempty are required.
+func jsonSchema(v any) map[string]any {
+	return typeSchema(reflect.TypeOf(v))
+}
+
+// typeSchema returns the JSON schema of a type, see jsonSchema.
+func typeSchema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Int:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		properties := map[string]any{}
+		required := []string{}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = typeSchema(field.Type)
+			if options != "omitempty" {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": properties, "required": required}
+	default:
+		panic("openapi: unsupported type " + t.String())
+	}
+}
+
+// apiOpenAPI is the API handler that returns the OpenAPI description of the
+// API. It doesn't need an API token.
+// Method: GET
+func (app *application) apiOpenAPI(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, r, http.StatusOK, openAPISpec())
+}
+
+// apiDocs is the handler of the API documentation page, which renders the
+// OpenAPI description with a script served from the static files.
+// Method: GET
+func (app *application) apiDocs(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, ui.Files, "static/api/docs.html")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+)
+
+// apiRoutes returns the method and path of the API routes registered on the
+// mux of the application.
+func apiRoutes(t *testing.T) [][2]string {
+	var routes [][2]string
+	for _, pattern := range newTestApplication(t).mux().patterns {
+		method, path, ok := strings.Cut(pattern, " ")
+		if ok && strings.HasPrefix(path, "/api/v1/") {
+			routes = append(routes, [2]string{method, path})
+		}
+	}
+
+	return routes
+}
+
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	// Encode and decode the specification, as a client would get it.
+	js, err := json.Marshal(openAPISpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spec struct {
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	err = json.Unmarshal(js, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := spec.Servers[0].URL
+
+	routes := apiRoutes(t)
+	if len(routes) == 0 {
+		t.Fatal("no API routes registered")
+	}
+
+	// Every API route is described.
+	registered := map[string]bool{}
+	for _, route := range routes {
+		method, path := strings.ToLower(route[0]), strings.TrimPrefix(route[1], server)
+		registered[method+" "+path] = true
+
+		if _, ok := spec.Paths[path][method]; !ok {
+			t.Errorf("route %s %s is missing from the OpenAPI specification", route[0], route[1])
+		}
+	}
+
+	// Every described operation is routed.
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if !registered[method+" "+path] {
+				t.Errorf("operation %s %s%s is not routed", strings.ToUpper(method), server, path)
+			}
+		}
+	}
+}
+
+func TestAPIDocs(t *testing.T) {
+	// Create a new test application config.
+	app := newTestApplication(t)
+
+	// Create a new test server.
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Specification", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/api/v1/openapi.json")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Content-Type"), "application/json")
+		assert.StringContains(t, body, `"openapi":"3.1.0"`)
+	})
+
+	t.Run("Page", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/api/docs")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Content-Security-Policy"), "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+		assert.StringContains(t, body, `<script src="/static/js/apidocs.js"`)
+
+		// Everything is served by the application.
+		assert.Equal(t, strings.Contains(body, "://"), false)
+
+		code, _, body = ts.get(t, "/static/js/apidocs.js")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `"/api/v1/openapi.json"`)
+	})
+}
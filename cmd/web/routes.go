@@ -3,13 +3,29 @@ package main
 import (
 	"net/http"
 
+	"github.com/AlessioPani/go-snippetbox/internal/models"
 	"github.com/AlessioPani/go-snippetbox/ui"
 	"github.com/justinas/alice"
 )
 
-// routes configure the application mux and return it back to the main function.
+// routes configure the application mux and return it back to the main function,
+// wrapped in the middleware used on every request.
 func (app *application) routes() http.Handler {
-	mux := http.NewServeMux()
+	mux := app.mux()
+
+	// Create a middleware chain to be used on every request. The requests are
+	// routed once, then instrumented, traced, identified and logged before
+	// the panics are recovered, so that they are counted and logged as 500
+	// with the ID of the request.
+	standard := alice.New(routeRequest(mux.ServeMux), app.instrumentRequest, app.traceRequest, requestID, app.logRequest, app.recoverPanic, commonHeaders)
+
+	return standard.Then(mux)
+}
+
+// mux returns the mux of the application, with the handler of each route
+// traced in a span of its own.
+func (app *application) mux() *tracedMux {
+	mux := &tracedMux{ServeMux: http.NewServeMux(), tracer: app.tracer}
 
 	// Static files handler using embedded files.
 	mux.Handle("GET /static/", http.FileServerFS(ui.Files))
@@ -18,26 +34,95 @@ func (app *application) routes() http.Handler {
 	mux.HandleFunc("/ping", ping)
 
 	// Dynamic application routes with the new session manager.
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
+	// API tokens are checked before the CSRF protection, which they bypass.
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.authenticateToken, noSurf, app.authenticate)
 
 	// Application handlers.
 	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
 	mux.Handle("GET /snippet/view/{id}/", dynamic.ThenFunc(app.snippetView))
 
+	// Feeds of the latest snippets, which don't need the sessions.
+	mux.HandleFunc("GET /feed.atom", app.latestFeed)
+	mux.HandleFunc("GET /feed.rss", app.latestFeed)
+	mux.HandleFunc("GET /users/{id}/feed.atom", app.userFeed)
+	mux.HandleFunc("GET /users/{id}/feed.rss", app.userFeed)
+	mux.HandleFunc("GET /tags/{tag}/feed.atom", app.tagFeed)
+	mux.HandleFunc("GET /tags/{tag}/feed.rss", app.tagFeed)
+
 	// Authentication handlers.
 	mux.Handle("GET /user/signup", dynamic.ThenFunc(app.userSignup))
 	mux.Handle("POST /user/signup", dynamic.ThenFunc(app.userSignupPost))
 	mux.Handle("GET /user/login", dynamic.ThenFunc(app.userLogin))
 	mux.Handle("POST /user/login", dynamic.ThenFunc(app.userLoginPost))
+	mux.Handle("GET /user/login/passkey", dynamic.ThenFunc(app.userLoginPasskey))
+	mux.Handle("POST /user/login/passkey/begin", dynamic.ThenFunc(app.passkeyLoginBegin))
+	mux.Handle("POST /user/login/passkey/finish", dynamic.ThenFunc(app.passkeyLoginFinish))
 
 	// Handlers reserved to authenticated users only.
 	protected := dynamic.Append(app.requireAuthentication)
 	mux.Handle("GET /snippet/create", protected.ThenFunc(app.snippetCreate))
-	mux.Handle("POST /snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	mux.Handle("POST /snippet/report/{id}", protected.ThenFunc(app.snippetReportPost))
 	mux.Handle("POST /user/logout", protected.ThenFunc(app.userLogoutPost))
+	mux.Handle("GET /account", protected.ThenFunc(app.accountView))
+	mux.Handle("POST /account/sessions/revoke/{id}", protected.ThenFunc(app.accountSessionRevokePost))
+	mux.Handle("POST /account/sessions/revoke-all", protected.ThenFunc(app.accountSessionRevokeAllPost))
+	mux.Handle("GET /account/password", protected.ThenFunc(app.accountPasswordUpdate))
+	mux.Handle("POST /account/password", protected.ThenFunc(app.accountPasswordUpdatePost))
+	mux.Handle("GET /user/passkeys", protected.ThenFunc(app.userPasskeys))
+	mux.Handle("POST /user/passkeys/register/begin", protected.ThenFunc(app.passkeyRegisterBegin))
+	mux.Handle("POST /user/passkeys/register/finish", protected.ThenFunc(app.passkeyRegisterFinish))
+	mux.Handle("POST /user/passkeys/delete/{id}", protected.ThenFunc(app.passkeyDeletePost))
+	mux.Handle("GET /account/tokens", protected.ThenFunc(app.accountTokens))
+	mux.Handle("POST /account/tokens", protected.ThenFunc(app.accountTokenCreatePost))
+	mux.Handle("POST /account/tokens/revoke/{id}", protected.ThenFunc(app.accountTokenRevokePost))
+	mux.Handle("GET /account/webhooks", protected.ThenFunc(app.accountWebhooks))
+	mux.Handle("POST /account/webhooks", protected.ThenFunc(app.accountWebhookCreatePost))
+	mux.Handle("POST /account/webhooks/delete/{id}", protected.ThenFunc(app.accountWebhookDeletePost))
 
-	// Create a middleware chain to be used on every request.
-	standard := alice.New(app.recoverPanic, app.logRequest, commonHeaders)
+	// Handlers reserved to authenticated users, which accept API tokens with
+	// the right scope too.
+	snippetWriter := dynamic.Append(app.requireScope(models.ScopeSnippetsWrite), app.requireAuthentication)
+	mux.Handle("POST /snippet/create", snippetWriter.ThenFunc(app.snippetCreatePost))
 
-	return standard.Then(mux)
+	// Handlers reserved to moderators and admins.
+	moderator := protected.Append(app.requireRole(models.RoleModerator, models.RoleAdmin))
+	mux.Handle("GET /moderation", moderator.ThenFunc(app.moderation))
+	mux.Handle("POST /moderation/dismiss/{id}", moderator.ThenFunc(app.moderationDismissPost))
+	mux.Handle("POST /moderation/hide/{id}", moderator.ThenFunc(app.moderationHidePost))
+	mux.Handle("POST /moderation/delete/{id}", moderator.ThenFunc(app.moderationDeletePost))
+
+	// Handlers reserved to admins only.
+	admin := protected.Append(app.requireRole(models.RoleAdmin))
+	mux.Handle("GET /admin", admin.ThenFunc(app.admin))
+	mux.Handle("GET /admin/users", admin.ThenFunc(app.adminUsers))
+	mux.Handle("POST /admin/users/suspend/{id}", admin.ThenFunc(app.adminUserSuspendPost))
+	mux.Handle("POST /admin/users/unsuspend/{id}", admin.ThenFunc(app.adminUserUnsuspendPost))
+	mux.Handle("POST /admin/users/role/{id}", admin.ThenFunc(app.adminUserRolePost))
+	mux.Handle("GET /admin/snippets", admin.ThenFunc(app.adminSnippets))
+	mux.Handle("POST /admin/snippets/delete/{id}", admin.ThenFunc(app.adminSnippetDeletePost))
+
+	// JSON API, used with API tokens only: it doesn't need the sessions nor
+	// the CSRF protection.
+	apiReader := alice.New(app.requireAPIScope(models.ScopeSnippetsRead))
+	apiWriter := alice.New(app.requireAPIScope(models.ScopeSnippetsWrite))
+	mux.Handle("GET /api/v1/snippets", apiReader.ThenFunc(app.apiSnippetList))
+	mux.Handle("GET /api/v1/snippets/{id}", apiReader.ThenFunc(app.apiSnippetView))
+	mux.Handle("POST /api/v1/snippets", apiWriter.ThenFunc(app.apiSnippetCreate))
+	mux.Handle("PUT /api/v1/snippets/{id}", apiWriter.ThenFunc(app.apiSnippetUpdate))
+	mux.Handle("DELETE /api/v1/snippets/{id}", apiWriter.ThenFunc(app.apiSnippetDelete))
+	mux.HandleFunc("GET /api/v1/openapi.json", app.apiOpenAPI)
+	mux.HandleFunc("GET /api/docs", app.apiDocs)
+	mux.HandleFunc("/api/", app.apiNotFound)
+
+	return mux
+}
+
+// adminRoutes configure the mux of the admin listener, which exposes the
+// metrics of the application to the monitoring system.
+func (app *application) adminRoutes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("GET /metrics", app.metrics.handler(app.logger))
+
+	return app.recoverPanic(mux)
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+)
+
+// background runs fn in a goroutine tracked by the application, so that the
+// shutdown waits for it. A panic in fn is logged instead of crashing the
+// server, as recoverPanic does for the requests.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			err := recover()
+			if err != nil {
+				app.logger.Error(fmt.Sprintf("%s", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// serve accepts the TLS connections on ln with the server, whose TLS config
+// holds the certificate, until ctx is done. It then shuts the server down
+// gracefully: the listener is closed, the requests in progress are completed
+// and the background goroutines are waited for, within the shutdown timeout
+// of the application. It returns nil if the shutdown has completed in time.
+func (app *application) serve(ctx context.Context, srv *http.Server, ln net.Listener) error {
+	shutdownErr := make(chan error, 1)
+
+	go func() {
+		<-ctx.Done()
+		app.logger.Info("shutting down server", slog.Duration("timeout", app.shutdownTimeout))
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.shutdownTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownErr <- err
+			return
+		}
+
+		app.logger.Info("completing background tasks")
+
+		done := make(chan struct{})
+		go func() {
+			app.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			shutdownErr <- nil
+		case <-ctx.Done():
+			shutdownErr <- ctx.Err()
+		}
+	}()
+
+	err := srv.ServeTLS(ln, "", "")
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return <-shutdownErr
+}
+
+// serveAdmin accepts the plain HTTP connections on ln with the admin server in
+// background, until ctx is done. It then shuts the server down gracefully,
+// within the shutdown timeout of the application, which waits for it along
+// with the other background goroutines.
+func (app *application) serveAdmin(ctx context.Context, srv *http.Server, ln net.Listener) {
+	app.background(func() {
+		err := srv.Serve(ln)
+		if !errors.Is(err, http.ErrServerClosed) {
+			app.logger.Error("admin server failed", slog.String("error", err.Error()))
+		}
+	})
+
+	app.background(func() {
+		<-ctx.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.shutdownTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			app.logger.Error("cannot shut down the admin server", slog.String("error", err.Error()))
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	// Borrow the certificate of httptest, with a client which trusts it.
+	certServer := httptest.NewTLSServer(nil)
+	certificates := certServer.TLS.Certificates
+	client := certServer.Client()
+	certServer.Close()
+
+	// start serves a slow handler, which waits for release before replying,
+	// and returns the URL of the server, the function which starts its
+	// shutdown, and the channel of the result of serve.
+	start := func(t *testing.T, app *application, started chan<- struct{}, release <-chan struct{}) (string, context.CancelFunc, <-chan error) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+			w.Write([]byte("completed"))
+		})
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		srv := &http.Server{
+			Handler:   mux,
+			TLSConfig: &tls.Config{Certificates: certificates},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		served := make(chan error, 1)
+		go func() {
+			served <- app.serve(ctx, srv, ln)
+		}()
+
+		return "https://" + ln.Addr().String(), cancel, served
+	}
+
+	t.Run("Slow request completes", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.shutdownTimeout = 5 * time.Second
+
+		// A background task which takes a while to stop.
+		var stopped atomic.Bool
+		shutdown := make(chan struct{})
+		app.background(func() {
+			<-shutdown
+			time.Sleep(50 * time.Millisecond)
+			stopped.Store(true)
+		})
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		url, cancel, served := start(t, app, started, release)
+
+		type result struct {
+			code int
+			body string
+			err  error
+		}
+		results := make(chan result, 1)
+		go func() {
+			rs, err := client.Get(url + "/slow")
+			if err != nil {
+				results <- result{err: err}
+				return
+			}
+			defer rs.Body.Close()
+			body, err := io.ReadAll(rs.Body)
+			results <- result{code: rs.StatusCode, body: string(body), err: err}
+		}()
+
+		// Shut down the server while the request is in progress.
+		<-started
+		cancel()
+		close(shutdown)
+
+		select {
+		case err := <-served:
+			t.Fatalf("serve returned before the request completed: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		// New connections are refused.
+		_, err := client.Get(url + "/slow")
+		assert.Equal(t, err != nil, true)
+
+		close(release)
+
+		res := <-results
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+		assert.Equal(t, res.code, http.StatusOK)
+		assert.Equal(t, res.body, "completed")
+
+		err = <-served
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, stopped.Load(), true)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.shutdownTimeout = 50 * time.Millisecond
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		url, cancel, served := start(t, app, started, release)
+
+		go client.Get(url + "/slow")
+
+		<-started
+		cancel()
+
+		err := <-served
+		assert.Equal(t, errors.Is(err, context.DeadlineExceeded), true)
+	})
+}
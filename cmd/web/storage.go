@@ -0,0 +1,161 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/migrate"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/AlessioPani/go-snippetbox/internal/models/postgres"
+	"github.com/AlessioPani/go-snippetbox/internal/postgresstore"
+	"github.com/AlessioPani/go-snippetbox/internal/sqlitestore"
+	"github.com/alexedwards/scs/v2"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// drivers maps the dialects to the database/sql drivers of their databases.
+var drivers = map[migrate.Dialect]string{
+	migrate.SQLite:   "sqlite3",
+	migrate.Postgres: "pgx",
+}
+
+// dbSystems maps the dialects to the database systems recorded in the spans
+// of the queries.
+var dbSystems = map[migrate.Dialect]attribute.KeyValue{
+	migrate.SQLite:   semconv.DBSystemSqlite,
+	migrate.Postgres: semconv.DBSystemPostgreSQL,
+}
+
+// dsnDialect returns the dialect of the database of a DSN: PostgreSQL for a
+// postgres:// or postgresql:// URL, SQLite otherwise, as the DSN is then the
+// path of the database file or a file: URI.
+func dsnDialect(dsn string) migrate.Dialect {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return migrate.Postgres
+	}
+
+	return migrate.SQLite
+}
+
+// newSnippetModel returns the snippet model of a database of a dialect.
+func newSnippetModel(db *sql.DB, dialect migrate.Dialect) models.SnippetModelInterface {
+	if dialect == migrate.Postgres {
+		return &postgres.SnippetModel{DB: db}
+	}
+
+	return &models.SnippetModel{DB: db}
+}
+
+// newUserModel returns the user model of a database of a dialect.
+func newUserModel(db *sql.DB, dialect migrate.Dialect) models.UserModelInterface {
+	if dialect == migrate.Postgres {
+		return &postgres.UserModel{DB: db}
+	}
+
+	return &models.UserModel{DB: db}
+}
+
+// newStatsModel returns the stats model of a database of a dialect.
+func newStatsModel(db *sql.DB, dialect migrate.Dialect) models.StatsModelInterface {
+	if dialect == migrate.Postgres {
+		return &postgres.StatsModel{DB: db}
+	}
+
+	return &models.StatsModel{DB: db}
+}
+
+// newReportModel returns the report model of a database of a dialect.
+func newReportModel(db *sql.DB, dialect migrate.Dialect) models.ReportModelInterface {
+	if dialect == migrate.Postgres {
+		return &postgres.ReportModel{DB: db}
+	}
+
+	return &models.ReportModel{DB: db}
+}
+
+// newAuditModel returns the audit model of a database of a dialect.
+func newAuditModel(db *sql.DB, dialect migrate.Dialect) models.AuditModelInterface {
+	if dialect == migrate.Postgres {
+		return &postgres.AuditModel{DB: db}
+	}
+
+	return &models.AuditModel{DB: db}
+}
+
+// newModerationModel returns the moderation model of a database of a
+// dialect.
+func newModerationModel(db *sql.DB, dialect migrate.Dialect) models.ModerationModelInterface {
+	if dialect == migrate.Postgres {
+		return &postgres.ModerationModel{DB: db}
+	}
+
+	return &models.ModerationModel{DB: db}
+}
+
+// newTokenModel returns the API token model of a database of a dialect.
+func newTokenModel(db *sql.DB, dialect migrate.Dialect) models.TokenModelInterface {
+	if dialect == migrate.Postgres {
+		return &postgres.TokenModel{DB: db}
+	}
+
+	return &models.TokenModel{DB: db}
+}
+
+// newWebhookModel returns the webhook model of a database of a dialect.
+func newWebhookModel(db *sql.DB, dialect migrate.Dialect) models.WebhookModelInterface {
+	if dialect == migrate.Postgres {
+		return &postgres.WebhookModel{DB: db}
+	}
+
+	return &models.WebhookModel{DB: db}
+}
+
+// newCredentialModel returns the passkey model of a database of a dialect.
+func newCredentialModel(db *sql.DB, dialect migrate.Dialect) models.CredentialModelInterface {
+	if dialect == migrate.Postgres {
+		return &postgres.CredentialModel{DB: db}
+	}
+
+	return &models.CredentialModel{DB: db}
+}
+
+// newSessionModel returns the login session model of a database of a
+// dialect, whose durations must be the settings of the session manager.
+func newSessionModel(db *sql.DB, dialect migrate.Dialect, lifetime, idleTimeout, rememberMeLifetime time.Duration) models.SessionModelInterface {
+	touchInterval := lastSeenInterval(idleTimeout)
+	if dialect == migrate.Postgres {
+		return &postgres.SessionModel{DB: db, Lifetime: lifetime, IdleTimeout: idleTimeout, RememberMeLifetime: rememberMeLifetime, TouchInterval: touchInterval}
+	}
+
+	return &models.SessionModel{DB: db, Lifetime: lifetime, IdleTimeout: idleTimeout, RememberMeLifetime: rememberMeLifetime, TouchInterval: touchInterval}
+}
+
+// newLoginAttemptModel returns the login attempt model of a database of a
+// dialect.
+func newLoginAttemptModel(db *sql.DB, dialect migrate.Dialect) models.LoginAttemptModelInterface {
+	if dialect == migrate.Postgres {
+		return &postgres.LoginAttemptModel{DB: db}
+	}
+
+	return &models.LoginAttemptModel{DB: db}
+}
+
+// sessionStore is the store of the sessions of the session manager, which
+// deletes the expired sessions in background until StopCleanup is called.
+type sessionStore interface {
+	scs.Store
+	StopCleanup()
+}
+
+// newSessionStore returns the session store of a database of a dialect. The
+// errors of its cleanup are logged to logger.
+func newSessionStore(db *sql.DB, dialect migrate.Dialect, logger *slog.Logger) sessionStore {
+	if dialect == migrate.Postgres {
+		return postgresstore.New(db, logger)
+	}
+
+	return sqlitestore.New(db, logger)
+}
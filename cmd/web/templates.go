@@ -4,6 +4,7 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/AlessioPani/go-snippetbox/internal/models"
@@ -12,13 +13,28 @@ import (
 
 // templateData is a struct that contains data to be passed on a template.
 type templateData struct {
-	CurrentYear     int
-	Snippet         models.Snippet
-	Snippets        []models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear       int
+	Snippet           models.Snippet
+	Snippets          []models.Snippet
+	Credentials       []models.Credential
+	User              models.User
+	Users             []models.User
+	Roles             []models.Role
+	Stats             models.Stats
+	ReportedSnippets  []models.ReportedSnippet
+	AuditEntries      []models.AuditEntry
+	Tokens            []models.Token
+	Scopes            []string
+	NewToken          string
+	Webhooks          []models.Webhook
+	Deliveries        []models.Delivery
+	Sessions          []models.Session
+	CurrentSessionID  string
+	Form              any
+	Flash             string
+	IsAuthenticated   bool
+	AuthenticatedUser models.User
+	CSRFToken         string
 }
 
 // humanDate is a function that returns a nicely formatted date.
@@ -37,10 +53,16 @@ func addNumbers(x int, y int) int {
 	return x + y
 }
 
+// contains is a function that reports whether a list contains a string.
+func contains(list []string, s string) bool {
+	return slices.Contains(list, s)
+}
+
 // functions is a global template.FuncMap to store the custom functions we made available to Go templates.
 var functions = template.FuncMap{
 	"humanDate":  humanDate,
 	"addNumbers": addNumbers,
+	"contains":   contains,
 }
 
 // newTemplateCache is a method that creates a in-memory template cache.
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"database/sql"
+	"encoding/json"
 	"html"
 	"io"
 	"log/slog"
@@ -10,17 +12,24 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/AlessioPani/go-snippetbox/internal/migrate"
 	"github.com/AlessioPani/go-snippetbox/internal/models/mocks"
+	"github.com/AlessioPani/go-snippetbox/internal/webauthn"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // Define a custom testServer type which embeds a httptest.Server instance.
+// The client is used to send the requests, so that many clients with their own
+// cookies can talk to the same server.
 type testServer struct {
 	*httptest.Server
+	client *http.Client
 }
 
 // Create a newTestServer helper which initalizes and returns a new instance
@@ -43,7 +52,21 @@ func newTestServer(t *testing.T, h http.Handler) *testServer {
 		return http.ErrUseLastResponse
 	}
 
-	return &testServer{ts}
+	return &testServer{ts, ts.Client()}
+}
+
+// newClient returns a copy of the test server with a new client, which has its
+// own cookie jar and therefore its own session, like a different browser.
+func (ts *testServer) newClient(t *testing.T) *testServer {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := *ts.client
+	client.Jar = jar
+
+	return &testServer{ts.Server, &client}
 }
 
 // Create a newTestApplication helper which returns an instance of our
@@ -58,24 +81,72 @@ func newTestApplication(t *testing.T) *application {
 	// Create a form decoder.
 	formDecoder := form.NewDecoder()
 
-	// Create a session manager instance with the same settings as
-	// production.
-	// If no store is set (as in production), the SCS package will default
-	// to using a in-memory store, which is ideal for testing purposes.
+	// Create a session manager instance with the cookie settings of
+	// production. Production keeps the sessions in the database (see
+	// newSessionStore); the tests deliberately leave the store unset, so the
+	// SCS package uses its in-memory store in place of the database-backed
+	// one.
 	sessionManager := scs.New()
 	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Cookie.Persist = false
 	sessionManager.Cookie.Secure = true
 
+	sessions := &mocks.SessionModel{}
+
+	// The moderation actions are taken on the other mocks.
+	snippets := &mocks.SnippetModel{}
+	users := &mocks.UserModel{}
+	reports := &mocks.ReportModel{}
+	audit := &mocks.AuditModel{}
+
 	return &application{
-		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
-		snippets:       &mocks.SnippetModel{}, // Use the mock.
-		users:          &mocks.UserModel{},    // Use the mock.
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
+		logger:             slog.New(slog.NewTextHandler(io.Discard, nil)),
+		baseURL:            "https://snippetbox.example.com",
+		snippets:           snippets, // Use the mock.
+		users:              users,    // Use the mock.
+		stats:              &mocks.StatsModel{},
+		reports:            reports,
+		audit:              audit,
+		actions:            &mocks.ModerationModel{Snippets: snippets, Users: users, Reports: reports, Audit: audit},
+		tokens:             &mocks.TokenModel{},
+		webhooks:           &mocks.WebhookModel{},
+		credentials:        &mocks.CredentialModel{},
+		sessions:           sessions,
+		metrics:            newMetrics(newSessionCollector(sessions)),
+		tracer:             noop.NewTracerProvider().Tracer(tracerName),
+		webAuthn:           &webauthn.RelyingParty{ID: "127.0.0.1", Name: "Snippetbox"},
+		loginThrottle:      newLoginThrottle(&mocks.LoginAttemptModel{}),
+		templateCache:      templateCache,
+		formDecoder:        formDecoder,
+		sessionManager:     sessionManager,
+		sessionIdleTimeout: 20 * time.Minute,
+		rememberMeLifetime: 30 * 24 * time.Hour,
+		queryTimeout:       5 * time.Second,
 	}
 }
 
+// openTestDB opens a database at dsn, with the migrations applied.
+func openTestDB(t *testing.T, dsn string) *sql.DB {
+	db, err := openDB(dsn, noop.NewTracerProvider())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := newMigrator(db, migrate.SQLite)
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+
+	_, err = migrator.Up()
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+
+	return db
+}
+
 // Define a regular expression which captures the CSRF token value from the
 // HTML for our user signup page.
 var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+)'>`)
@@ -99,7 +170,7 @@ func extractCSRFToken(t *testing.T, body string) string {
 // request to a given url path using the test server client, and returns the
 // response status code, headers and body.
 func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
-	rs, err := ts.Client().Get(ts.URL + urlPath)
+	rs, err := ts.client.Get(ts.URL + urlPath)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -116,7 +187,17 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, strin
 // final parameter to this method is a url.Values object which can contain any
 // form data that you want to send in the request body.
 func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
-	rs, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// NoSurf checks that unsafe requests come from the same origin, as a
+	// browser would report in the Origin header.
+	req.Header.Set("Origin", ts.URL)
+
+	rs, err := ts.client.Do(req)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -128,7 +209,70 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 		t.Fatal(err)
 	}
 	body = bytes.TrimSpace(body)
-	
+
 	// Return the response status, headers and body.
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// postJSON sends a POST request with a JSON body to the test server, passing
+// the CSRF token in the X-CSRF-Token header as the frontend scripts do. If dst
+// is not nil, a successful JSON response is decoded into it.
+func (ts *testServer) postJSON(t *testing.T, urlPath string, csrfToken string, body any, dst any) int {
+	js, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, bytes.NewReader(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", ts.URL)
+	req.Header.Set("X-CSRF-Token", csrfToken)
+
+	rs, err := ts.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	if dst != nil && rs.StatusCode == http.StatusOK {
+		err = json.NewDecoder(rs.Body).Decode(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return rs.StatusCode
+}
+
+// apiRequest sends a request to the JSON API of the test server, with the API
+// token in the Authorization header if it isn't empty, and returns the
+// response status code, headers and body.
+func (ts *testServer) apiRequest(t *testing.T, method string, urlPath string, token string, body string) (int, http.Header, string) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rs, err := ts.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	rsBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsBody = bytes.TrimSpace(rsBody)
+
+	return rs.StatusCode, rs.Header, string(rsBody)
+}
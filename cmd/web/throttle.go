@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// loginThrottle slows down password guessing. Failed logins are recorded per
+// email address and per client IP address; once a few failures have been made,
+// each further attempt has to wait for an exponentially growing delay, and
+// after too many failures the key is locked out for a while.
+// Failures are counted for any email address, whether it belongs to a user or
+// not, so the throttling doesn't reveal which accounts exist.
+type loginThrottle struct {
+	attempts models.LoginAttemptModelInterface
+
+	// freeAttempts is the number of failures allowed before delays apply.
+	freeAttempts int
+	// baseDelay is the delay after the first throttled failure. It doubles
+	// with each further failure, up to maxDelay.
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	// emailLockout and ipLockout are the number of failures after which an
+	// email address or an IP address is locked out for lockoutDuration.
+	// IP addresses get more room, as they can be shared by many users.
+	emailLockout    int
+	ipLockout       int
+	lockoutDuration time.Duration
+	// window is how far back failures are taken into account.
+	window time.Duration
+
+	now func() time.Time
+}
+
+// newLoginThrottle returns a loginThrottle with the default policy.
+func newLoginThrottle(attempts models.LoginAttemptModelInterface) *loginThrottle {
+	return &loginThrottle{
+		attempts:        attempts,
+		freeAttempts:    3,
+		baseDelay:       time.Second,
+		maxDelay:        5 * time.Minute,
+		emailLockout:    10,
+		ipLockout:       100,
+		lockoutDuration: 15 * time.Minute,
+		window:          time.Hour,
+		now:             time.Now,
+	}
+}
+
+// attempt records a login attempt for email from ip before its password is
+// checked, and returns how long the client has to wait before the attempt is
+// allowed. A zero duration means the attempt can go ahead, and it stays
+// recorded as a failure until the login succeeds. Otherwise the attempt is
+// refused and forgotten, so retrying while throttled doesn't extend the delay.
+// Recording the attempt and counting the earlier ones happen at once, so a
+// burst of parallel attempts can't all get through before any is recorded.
+func (lt *loginThrottle) attempt(email, ip string) (time.Duration, error) {
+	id, byEmail, byIP, err := lt.attempts.Insert(normalizeEmail(email), ip, lt.window)
+	if err != nil {
+		return 0, err
+	}
+
+	wait := max(lt.wait(byEmail, lt.emailLockout), lt.wait(byIP, lt.ipLockout))
+	if wait > 0 {
+		err = lt.attempts.Delete(id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return wait, nil
+}
+
+// succeed clears the failures of email after a successful login, including
+// the attempt recorded for the login itself. Failures of
+// the IP address are kept, so an attacker can't reset them by logging into
+// their own account.
+func (lt *loginThrottle) succeed(email string) error {
+	return lt.attempts.Reset(normalizeEmail(email))
+}
+
+// wait returns the time left before the next attempt allowed by failures.
+func (lt *loginThrottle) wait(failures models.LoginFailures, lockout int) time.Duration {
+	if failures.Count < lt.freeAttempts {
+		return 0
+	}
+
+	delay := lt.lockoutDuration
+	if failures.Count < lockout {
+		// Limit the shift so the delay can't overflow.
+		delay = lt.maxDelay
+		if shift := failures.Count - lt.freeAttempts; shift < 30 {
+			delay = min(lt.baseDelay<<shift, lt.maxDelay)
+		}
+	}
+
+	wait := failures.Last.Add(delay).Sub(lt.now())
+	if wait < 0 {
+		return 0
+	}
+
+	return wait
+}
+
+// normalizeEmail returns the form of an email address used as throttling key.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// clientIP returns the IP address of the client of a request.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
+}
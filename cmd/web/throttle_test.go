@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func TestLoginThrottleWait(t *testing.T) {
+	now := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	lt := newLoginThrottle(nil)
+	lt.now = func() time.Time { return now }
+
+	// Create a slice of anonymous structs containing the test case name,
+	// the failures made so far and the expected wait before the next attempt.
+	tests := []struct {
+		name     string
+		failures models.LoginFailures
+		lockout  int
+		want     time.Duration
+	}{
+		{"No failures", models.LoginFailures{}, 10, 0},
+		{"Free attempts", models.LoginFailures{Count: 2, Last: now}, 10, 0},
+		{"First delay", models.LoginFailures{Count: 3, Last: now}, 10, time.Second},
+		{"Exponential backoff", models.LoginFailures{Count: 6, Last: now}, 10, 8 * time.Second},
+		{"Delay partially elapsed", models.LoginFailures{Count: 6, Last: now.Add(-5 * time.Second)}, 10, 3 * time.Second},
+		{"Delay elapsed", models.LoginFailures{Count: 6, Last: now.Add(-time.Minute)}, 10, 0},
+		{"Maximum delay", models.LoginFailures{Count: 60, Last: now}, 100, 5 * time.Minute},
+		{"Locked out", models.LoginFailures{Count: 10, Last: now.Add(-time.Minute)}, 10, 14 * time.Minute},
+		{"Lockout expired", models.LoginFailures{Count: 12, Last: now.Add(-time.Hour)}, 10, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, lt.wait(test.failures, test.lockout), test.want)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the name of the tracer of the application, which identifies
+// the instrumentation in the exported spans.
+const tracerName = "github.com/AlessioPani/go-snippetbox/cmd/web"
+
+// propagator reads the trace context of the requests from their W3C
+// traceparent and tracestate headers.
+var propagator = propagation.TraceContext{}
+
+// newTracerProvider returns the provider of the tracers of the application,
+// which exports the spans to the standard output or to the OTLP/HTTP endpoint
+// (host:port) of a collector, depending on the exporter: stdout, otlp or none
+// to disable the tracing. The returned function flushes the spans and stops
+// the exporter.
+func newTracerProvider(ctx context.Context, exporter string, endpoint string) (trace.TracerProvider, func(context.Context) error, error) {
+	var spanExporter sdktrace.SpanExporter
+	var err error
+
+	switch exporter {
+	case "none":
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	case "stdout":
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		spanExporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+	default:
+		err = fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "snippetbox"))),
+	)
+
+	return tp, tp.Shutdown, nil
+}
+
+// traceRequest is a middleware which traces the requests in a server span
+// named after the method and the route resolved by the routeRequest
+// middleware. The span continues the trace of the traceparent header of the
+// request, if any, and covers the rest of the middleware chain.
+func (app *application) traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeFromContext(r.Context())
+
+		name := r.Method
+		if route != "unmatched" {
+			// The method of the pattern, if any, is the method of the
+			// request.
+			path := route
+			if _, p, ok := strings.Cut(route, " "); ok {
+				path = p
+			}
+			name += " " + path
+		}
+
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := app.tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rw := newResponseWriter(w)
+
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", rw.status),
+			attribute.Int("http.response.body.size", rw.size),
+			attribute.String("http.request.id", rw.Header().Get("X-Request-ID")),
+		)
+		if rw.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rw.status))
+		}
+	})
+}
+
+// tracedMux is a http.ServeMux which traces the handler of each route, along
+// with the middleware of the route, in a span of its own. It records the
+// patterns of the routes, in the order they are registered.
+type tracedMux struct {
+	*http.ServeMux
+	tracer   trace.Tracer
+	patterns []string
+}
+
+// Handle registers the handler of a pattern, wrapped in a span named after
+// the pattern.
+func (mux *tracedMux) Handle(pattern string, handler http.Handler) {
+	mux.patterns = append(mux.patterns, pattern)
+	name := "handler " + pattern
+
+	mux.ServeMux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := mux.tracer.Start(r.Context(), name)
+		defer span.End()
+
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	}))
+}
+
+// HandleFunc registers the handler function of a pattern, wrapped in a span
+// named after the pattern.
+func (mux *tracedMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	mux.Handle(pattern, http.HandlerFunc(handler))
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/migrate"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	// traceparent is the trace context sent by the client, with its trace ID
+	// and the ID of its span.
+	const (
+		traceparent  = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		traceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentSpanID = "00f067aa0ba902b7"
+	)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		queryTimeout bool
+		wantSpans    map[string]string
+		wantStatus   int
+		wantError    bool
+	}{
+		{
+			name:    "Page",
+			urlPath: "/snippet/view/1/",
+			wantSpans: map[string]string{
+				"GET /snippet/view/{id}/":         "",
+				"handler GET /snippet/view/{id}/": "GET /snippet/view/{id}/",
+				"sql.conn.prepare":                "handler GET /snippet/view/{id}/",
+				"sql.stmt.query":                  "handler GET /snippet/view/{id}/",
+				"render view.tmpl.html":           "handler GET /snippet/view/{id}/",
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "Query timeout",
+			urlPath:      "/snippet/view/1/",
+			queryTimeout: true,
+			// The query isn't sent to the database once the time is up.
+			wantSpans: map[string]string{
+				"GET /snippet/view/{id}/":         "",
+				"handler GET /snippet/view/{id}/": "GET /snippet/view/{id}/",
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantError:  true,
+		},
+		{
+			name:    "Route without method",
+			urlPath: "/ping",
+			wantSpans: map[string]string{
+				"GET /ping":     "",
+				"handler /ping": "GET /ping",
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:    "Unmatched route",
+			urlPath: "/missing",
+			wantSpans: map[string]string{
+				"GET": "",
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Record the spans in memory, as soon as they end.
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+			// The snippets are read from a database whose queries are
+			// traced.
+			db, err := openDB(filepath.Join(t.TempDir(), "snippetbox.db"), tp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			migrator, err := newMigrator(db, migrate.SQLite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = migrator.Up()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			app := newTestApplication(t)
+			app.tracer = tp.Tracer(tracerName)
+			app.snippets = &models.SnippetModel{DB: db}
+			err = (&models.UserModel{DB: db}).Insert(context.Background(), "John Doe", "test@test.com", "password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = app.snippets.Insert(context.Background(), 1, "Title", "Content", "", 7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.queryTimeout {
+				app.queryTimeout = 0
+			}
+
+			// Only the spans of the request are checked.
+			exporter.Reset()
+
+			// The request is served synchronously, so that every span has
+			// ended once it returns.
+			rw := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.urlPath, nil)
+			r.Header.Set("traceparent", traceparent)
+
+			app.routes().ServeHTTP(rw, r)
+			assert.Equal(t, rw.Code, tt.wantStatus)
+
+			spans := map[string]tracetest.SpanStub{}
+			for _, span := range exporter.GetSpans() {
+				spans[span.Name] = span
+			}
+			assert.Equal(t, len(spans), len(tt.wantSpans))
+
+			for name, parent := range tt.wantSpans {
+				span, ok := spans[name]
+				if !ok {
+					t.Fatalf("missing span %q", name)
+				}
+
+				// Every span belongs to the trace of the client.
+				assert.Equal(t, span.SpanContext.TraceID().String(), traceID)
+
+				// The server span is the child of the span of the client, and
+				// the others are nested in it.
+				if parent == "" {
+					assert.Equal(t, span.Parent.SpanID().String(), parentSpanID)
+					assert.Equal(t, span.Parent.IsRemote(), true)
+				} else {
+					assert.Equal(t, span.Parent.SpanID(), spans[parent].SpanContext.SpanID())
+				}
+			}
+
+			// The server span records the response, and fails with it.
+			var root tracetest.SpanStub
+			for name, parent := range tt.wantSpans {
+				if parent == "" {
+					root = spans[name]
+				}
+			}
+			attributes := attribute.NewSet(root.Attributes...)
+			status, _ := attributes.Value("http.response.status_code")
+			assert.Equal(t, status.AsInt64(), int64(tt.wantStatus))
+			requestID, _ := attributes.Value("http.request.id")
+			assert.Equal(t, requestID.AsString(), rw.Header().Get("X-Request-ID"))
+
+			wantCode := codes.Unset
+			if tt.wantError {
+				wantCode = codes.Error
+			}
+			assert.Equal(t, root.Status.Code, wantCode)
+
+			// The query, which the SQLite driver prepares first, records the
+			// statement and the database system.
+			if query, ok := spans["sql.stmt.query"]; ok {
+				attributes := attribute.NewSet(query.Attributes...)
+				statement, _ := attributes.Value("db.statement")
+				assert.StringContains(t, statement.AsString(), "FROM snippets WHERE")
+				system, _ := attributes.Value("db.system")
+				assert.Equal(t, system.AsString(), "sqlite")
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// webhookPayload is the JSON body sent to the webhooks on a snippet event.
+type webhookPayload struct {
+	Event   string     `json:"event"`
+	Created time.Time  `json:"created"`
+	Snippet apiSnippet `json:"snippet"`
+}
+
+// snippetEvent enqueues the delivery of an event of a snippet to the webhooks
+// of its author. The event is only logged if it can't be enqueued, as the
+// change of the snippet has already been made.
+func (app *application) snippetEvent(event string, snippet models.Snippet) {
+	if snippet.UserID == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(webhookPayload{
+		Event:   event,
+		Created: time.Now().UTC(),
+		Snippet: newAPISnippet(snippet),
+	})
+	if err == nil {
+		err = app.webhooks.Enqueue(snippet.UserID, event, payload)
+	}
+	if err != nil {
+		app.logger.Error("cannot enqueue webhook event", slog.String("event", event), slog.Int("snippet", snippet.ID), slog.String("error", err.Error()))
+	}
+}
+
+// errWebhookAddress is returned when a webhook resolves to an address it isn't
+// allowed to reach.
+var errWebhookAddress = errors.New("the webhook address isn't a public address")
+
+// sharedAddressSpace is the range of the carrier-grade NAT addresses (RFC
+// 6598), which aren't public either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr reports whether a webhook may be sent to addr. The loopback,
+// private (RFC 1918 and unique local), link-local, which include the cloud
+// metadata endpoints such as 169.254.169.254, shared, unspecified and
+// multicast addresses are reserved to the host and its network, so a user
+// could reach internal services with a webhook, such as the admin listener.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddressSpace.Contains(addr) &&
+		!(addr.Is4() && addr.As4()[0] == 0)
+}
+
+// checkWebhookHost returns errWebhookAddress if the host of a webhook URL is
+// an address which isn't public, or a name resolving to one. A name which
+// can't be resolved yet is accepted: the deliveries are checked again when
+// they are sent, by the dialer of the dispatcher.
+func checkWebhookHost(ctx context.Context, host string) error {
+	addr, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	if err == nil {
+		if !publicAddr(addr) {
+			return errWebhookAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return errWebhookAddress
+		}
+	}
+
+	return nil
+}
+
+// webhookDialControl is the Control function of the dialer of the webhooks,
+// which refuses to connect to the addresses which aren't public. The check is
+// made on the address being dialed, after the name of the webhook has been
+// resolved, so it can't be bypassed by a DNS record changed after the
+// registration.
+func webhookDialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(addr) {
+		return errWebhookAddress
+	}
+
+	return nil
+}
+
+// webhookSignature returns the signature of a payload sent to a webhook: the
+// hex encoded HMAC-SHA256 of the body keyed with the secret of the webhook.
+func webhookSignature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookDispatcher delivers the events in the outbox of the webhooks. Its
+// workers claim the deliveries which are due and send them; a failed attempt
+// is retried after an exponentially growing delay, until too many attempts
+// have been made.
+type webhookDispatcher struct {
+	webhooks models.WebhookModelInterface
+	logger   *slog.Logger
+	client   *http.Client
+
+	// workers is the number of deliveries sent concurrently. Each of them
+	// claims a delivery right before sending it, and waits for pollInterval
+	// when none is due.
+	workers      int
+	pollInterval time.Duration
+	// lease is how long a claimed delivery is reserved to a worker. It must
+	// be longer than the timeout of the client, so that a delivery is never
+	// claimed again while it is being sent.
+	lease time.Duration
+	// maxAttempts is the number of attempts after which a delivery fails.
+	maxAttempts int
+	// baseBackoff is the delay after the first failed attempt. It doubles
+	// with each further attempt, up to maxBackoff.
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newWebhookDispatcher returns a webhookDispatcher with the default policy.
+// Redirects aren't followed, so a webhook always gets the payload at the URL
+// it has been registered with. The client only connects to public addresses
+// (see webhookDialControl), and never through a proxy, which would connect
+// to the webhook on its behalf.
+func newWebhookDispatcher(webhooks models.WebhookModelInterface, logger *slog.Logger, workers int) *webhookDispatcher {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: webhookDialControl,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &webhookDispatcher{
+		webhooks: webhooks,
+		logger:   logger,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   10 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		workers:      workers,
+		pollInterval: 5 * time.Second,
+		lease:        time.Minute,
+		maxAttempts:  8,
+		baseBackoff:  30 * time.Second,
+		maxBackoff:   time.Hour,
+		done:         make(chan struct{}),
+	}
+}
+
+// start starts the workers in the background.
+func (d *webhookDispatcher) start() {
+	for range d.workers {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.work()
+		}()
+	}
+}
+
+// stop stops the workers and waits for the deliveries in progress.
+func (d *webhookDispatcher) stop() {
+	close(d.done)
+	d.wg.Wait()
+}
+
+// work sends the deliveries which are due, one at a time, until the
+// dispatcher is stopped.
+func (d *webhookDispatcher) work() {
+	for {
+		select {
+		case <-d.done:
+			return
+		default:
+		}
+
+		delivery, err := d.webhooks.Claim(d.lease)
+		if err == nil {
+			d.deliver(delivery)
+			continue
+		}
+		if !errors.Is(err, models.ErrNoRecord) {
+			d.logger.Error("cannot claim webhook delivery", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-time.After(d.pollInterval):
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// deliver makes an attempt of a delivery and records its result.
+func (d *webhookDispatcher) deliver(delivery models.Delivery) {
+	code, err := d.send(delivery)
+
+	switch {
+	case err == nil:
+		err = d.webhooks.Delivered(delivery.ID, code)
+	case delivery.Attempts >= d.maxAttempts:
+		d.logger.Warn("webhook delivery failed", slog.Int("delivery", delivery.ID), slog.String("url", delivery.URL), slog.String("error", err.Error()))
+		err = d.webhooks.Fail(delivery.ID, code, truncate(err.Error(), 255))
+	default:
+		err = d.webhooks.Retry(delivery.ID, code, truncate(err.Error(), 255), d.backoff(delivery.Attempts))
+	}
+	if err != nil {
+		d.logger.Error("cannot record webhook delivery", slog.Int("delivery", delivery.ID), slog.String("error", err.Error()))
+	}
+}
+
+// send posts the payload of a delivery to its webhook. It returns the status
+// code of the response, or 0 if none has been received, and an error unless
+// the status code is 2xx.
+func (d *webhookDispatcher) send(delivery models.Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Snippetbox-Webhook/1.0")
+	req.Header.Set("X-Snippetbox-Event", delivery.Event)
+	req.Header.Set("X-Snippetbox-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set("X-Snippetbox-Signature", webhookSignature(delivery.Secret, delivery.Payload))
+
+	rs, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer rs.Body.Close()
+
+	// Drain a bit of the body, so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(rs.Body, 64<<10))
+
+	if rs.StatusCode < 200 || rs.StatusCode > 299 {
+		return rs.StatusCode, fmt.Errorf("unexpected response status %s", rs.Status)
+	}
+	return rs.StatusCode, nil
+}
+
+// backoff returns the delay before the attempt following the given number of
+// attempts.
+func (d *webhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.baseBackoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxBackoff)
+}
+
+// truncate returns s cut to at most n bytes, without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
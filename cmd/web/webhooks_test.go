@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func TestWebhooks(t *testing.T) {
+	// Create a new test application config.
+	app := newTestApplication(t)
+
+	// Create a new test server.
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "test@test.com")
+	form.Add("password", "password")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// post sends a form to the webhooks pages with a valid CSRF token.
+	post := func(t *testing.T, urlPath string, form url.Values) (int, string) {
+		_, _, body := ts.get(t, "/account/webhooks")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		code, _, body := ts.postForm(t, urlPath, form)
+		return code, body
+	}
+
+	t.Run("Invalid data", func(t *testing.T) {
+		code, body := post(t, "/account/webhooks", url.Values{"url": {"ftp://example.com"}, "secret": {"short"}})
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field must be a valid http or https URL")
+		assert.StringContains(t, body, "This field must be at least 16 characters long")
+	})
+
+	t.Run("Local address", func(t *testing.T) {
+		for _, u := range []string{
+			"http://127.0.0.1:8081/metrics",
+			"http://localhost:8081/metrics",
+			"http://[::1]/hook",
+			"http://10.0.0.1/hook",
+			"http://192.168.1.1/hook",
+			"http://169.254.169.254/latest/meta-data/",
+			"http://[::ffff:127.0.0.1]/hook",
+		} {
+			code, body := post(t, "/account/webhooks", url.Values{"url": {u}, "secret": {"0123456789abcdef"}})
+			assert.Equal(t, code, http.StatusUnprocessableEntity)
+			assert.StringContains(t, body, "This field must be the URL of a public host")
+		}
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		code, _ := post(t, "/account/webhooks", url.Values{"url": {"https://example.com/hook"}, "secret": {"0123456789abcdef"}})
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		_, _, body := ts.get(t, "/account/webhooks")
+		assert.StringContains(t, body, "https://example.com/hook")
+		assert.StringContains(t, body, "No event has been sent yet.")
+	})
+
+	t.Run("Snippet event", func(t *testing.T) {
+		code, _ := post(t, "/snippet/create", url.Values{"title": {"Hooked"}, "content": {"Content"}, "expires": {"7"}})
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		_, _, body := ts.get(t, "/account/webhooks")
+		assert.StringContains(t, body, "<td>snippet.created</td>")
+		assert.StringContains(t, body, "<td>pending</td>")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		code, _ := post(t, "/account/webhooks/delete/99", url.Values{})
+		assert.Equal(t, code, http.StatusNotFound)
+
+		code, _ = post(t, "/account/webhooks/delete/1", url.Values{})
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		_, _, body := ts.get(t, "/account/webhooks")
+		assert.StringContains(t, body, "registered any webhook yet.")
+	})
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	d := newWebhookDispatcher(nil, nil, 1)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, d.backoff(tt.attempts), tt.want)
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.0.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, publicAddr(netip.MustParseAddr(tt.addr)), tt.want)
+		})
+	}
+}
+
+func TestWebhookClient(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the webhook client reached a loopback address")
+	}))
+	defer receiver.Close()
+
+	// The client refuses to connect to the receiver, whatever the URL it
+	// was registered with resolves to.
+	d := newWebhookDispatcher(nil, nil, 1)
+	code, err := d.send(models.Delivery{ID: 1, URL: receiver.URL, Event: models.EventSnippetCreated})
+	assert.Equal(t, code, 0)
+	if !errors.Is(err, errWebhookAddress) {
+		t.Fatalf("got error %v; want %v", err, errWebhookAddress)
+	}
+}
+
+func TestWebhookClaim(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db := openTestDB(t, filepath.Join(t.TempDir(), "snippetbox.db"))
+	defer db.Close()
+
+	users := &models.UserModel{DB: db}
+	webhooks := &models.WebhookModel{DB: db}
+
+	err := users.Insert(context.Background(), "John Doe", "test@test.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{"https://example.com/first", "https://example.com/second"} {
+		_, err = webhooks.Insert(1, u, "0123456789abcdef")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = webhooks.Enqueue(1, models.EventSnippetCreated, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each claim leases a single delivery, which isn't claimed again until
+	// its lease is over.
+	first, err := webhooks.Claim(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, first.URL, "https://example.com/first")
+	assert.Equal(t, first.Attempts, 1)
+
+	second, err := webhooks.Claim(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, second.URL, "https://example.com/second")
+
+	_, err = webhooks.Claim(time.Minute)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	// A delivery whose lease is over is claimed again, for a new attempt.
+	err = webhooks.Retry(first.ID, 0, "timeout", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := webhooks.Claim(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, again.ID, first.ID)
+	assert.Equal(t, again.Attempts, 2)
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db := openTestDB(t, filepath.Join(t.TempDir(), "snippetbox.db"))
+	defer db.Close()
+
+	app := newTestApplication(t)
+	app.snippets = &models.SnippetModel{DB: db}
+	app.users = &models.UserModel{DB: db}
+	app.webhooks = &models.WebhookModel{DB: db}
+
+	const secret = "0123456789abcdef"
+
+	// The receiver answers /ok with 200, /flaky with 500 on the first
+	// request and 204 afterwards, and /down with 503. The requests with an
+	// invalid signature are answered with 401. It records the payloads it
+	// accepts.
+	var (
+		mu       sync.Mutex
+		requests = map[string]int{}
+		payloads []webhookPayload
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if !hmac.Equal([]byte(r.Header.Get("X-Snippetbox-Signature")), []byte(webhookSignature(secret, body))) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests[r.URL.Path]++
+		switch {
+		case r.URL.Path == "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case r.URL.Path == "/flaky" && requests[r.URL.Path] == 1:
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case r.URL.Path == "/flaky":
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		var payload webhookPayload
+		err = json.Unmarshal(body, &payload)
+		if err != nil {
+			t.Error(err)
+		}
+		assert.Equal(t, r.Header.Get("X-Snippetbox-Event"), payload.Event)
+		payloads = append(payloads, payload)
+	}))
+	defer receiver.Close()
+
+	err := app.users.Insert(context.Background(), "John Doe", "test@test.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/ok", "/flaky", "/down"} {
+		_, err = app.webhooks.Insert(1, receiver.URL+path, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = app.webhooks.Insert(1, receiver.URL+"/forged", "another secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := app.snippets.Insert(context.Background(), 1, "Hooked", "Content", "go", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snippet, err := app.snippets.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.snippetEvent(models.EventSnippetCreated, snippet)
+
+	// Retry immediately, so the test doesn't wait for the backoff.
+	// The receiver listens on the loopback, which the client of the
+	// dispatcher can't reach: use the client of the receiver instead.
+	dispatcher := newWebhookDispatcher(app.webhooks, slog.New(slog.NewTextHandler(io.Discard, nil)), 2)
+	dispatcher.client.Transport = receiver.Client().Transport
+	dispatcher.pollInterval = 10 * time.Millisecond
+	dispatcher.baseBackoff = 0
+	dispatcher.maxAttempts = 3
+	dispatcher.start()
+
+	// Wait until no delivery is pending.
+	var deliveries []models.Delivery
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		deliveries, err = app.webhooks.Deliveries(1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pending := false
+		for _, d := range deliveries {
+			pending = pending || d.Status == models.DeliveryStatusPending
+		}
+		if !pending {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries still pending: %+v", deliveries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	dispatcher.stop()
+
+	results := map[string]models.Delivery{}
+	for _, d := range deliveries {
+		results[strings.TrimPrefix(d.URL, receiver.URL)] = d
+	}
+	assert.Equal(t, len(results), 4)
+
+	tests := []struct {
+		path     string
+		status   string
+		attempts int
+		code     int
+		err      string
+	}{
+		{"/ok", models.DeliveryStatusDelivered, 1, http.StatusOK, ""},
+		{"/flaky", models.DeliveryStatusDelivered, 2, http.StatusNoContent, ""},
+		{"/down", models.DeliveryStatusFailed, 3, http.StatusServiceUnavailable, "unexpected response status 503 Service Unavailable"},
+		{"/forged", models.DeliveryStatusFailed, 3, http.StatusUnauthorized, "unexpected response status 401 Unauthorized"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			d := results[tt.path]
+			assert.Equal(t, d.Event, models.EventSnippetCreated)
+			assert.Equal(t, d.Status, tt.status)
+			assert.Equal(t, d.Attempts, tt.attempts)
+			assert.Equal(t, d.ResponseCode, tt.code)
+			assert.Equal(t, d.Error, tt.err)
+			assert.Equal(t, d.Delivered.Valid, tt.status == models.DeliveryStatusDelivered)
+		})
+	}
+
+	// Both delivered payloads describe the snippet.
+	assert.Equal(t, len(payloads), 2)
+	for _, payload := range payloads {
+		assert.Equal(t, payload.Event, models.EventSnippetCreated)
+		assert.Equal(t, payload.Snippet.ID, id)
+		assert.Equal(t, payload.Snippet.Title, "Hooked")
+		assert.Equal(t, payload.Snippet.Language, "go")
+	}
+}
@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// usage is the help message of the command.
+const usage = `Usage: snippet [-config file] [-output text|json] <command> [arguments]
+
+Commands:
+  create -title title [-expires days] [-lang language] < file
+        create a snippet with the content read from the standard input
+  get <id>
+        print a snippet
+  list [-page n] [-page-size n]
+        list the valid snippets, newest first
+  delete <id>
+        delete a snippet
+
+Global flags:
+`
+
+// errUsage is returned by the commands called with invalid arguments.
+var errUsage = errors.New("invalid arguments")
+
+// command is a run of the command-line client.
+type command struct {
+	client *Client
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	json   bool
+}
+
+// Run runs the command-line client with the arguments, without the program
+// name, and returns its exit status: 0 on success or when the help is
+// requested, 1 on errors and 2 on invalid arguments.
+func Run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("snippet", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "configuration `file` (default $SNIPPET_CONFIG or snippetbox/config.json in the user configuration directory)")
+	output := flags.String("output", "text", "output `format`: text or json")
+
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || (*output != "text" && *output != "json") {
+		flags.Usage()
+		return 2
+	}
+
+	if *configPath == "" {
+		*configPath, err = DefaultConfigPath()
+		if err != nil {
+			fmt.Fprintln(stderr, "snippet:", err)
+			return 1
+		}
+	}
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "snippet:", err)
+		return 1
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, "snippet:", err)
+		return 1
+	}
+
+	cmd := &command{client: client, stdin: stdin, stdout: stdout, stderr: stderr, json: *output == "json"}
+
+	name, args := flags.Arg(0), flags.Args()[1:]
+	switch name {
+	case "create":
+		err = cmd.create(args)
+	case "get":
+		err = cmd.get(args)
+	case "list":
+		err = cmd.list(args)
+	case "delete":
+		err = cmd.delete(args)
+	default:
+		fmt.Fprintf(stderr, "snippet: unknown command %q\n", name)
+		flags.Usage()
+		return 2
+	}
+
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	case err != nil:
+		fmt.Fprintln(stderr, "snippet:", err)
+		return 1
+	}
+	return 0
+}
+
+// create creates a snippet with the content read from the standard input.
+func (cmd *command) create(args []string) error {
+	flags := cmd.flagSet("create")
+	title := flags.String("title", "", "title of the snippet")
+	expires := flags.Int("expires", 365, "number of `days` the snippet is valid for: 1, 7 or 365")
+	lang := flags.String("lang", "", "programming `language` of the snippet")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	content, err := io.ReadAll(cmd.stdin)
+	if err != nil {
+		return err
+	}
+
+	snippet, err := cmd.client.Create(SnippetInput{
+		Title:    *title,
+		Content:  string(content),
+		Language: *lang,
+		Expires:  *expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	if cmd.json {
+		return cmd.writeJSON(snippet)
+	}
+	fmt.Fprintf(cmd.stdout, "Created snippet #%d: %s\n", snippet.ID, cmd.client.ViewURL(snippet.ID))
+	return nil
+}
+
+// get prints a snippet. As text, only its content is printed, so it can be
+// redirected to a file.
+func (cmd *command) get(args []string) error {
+	id, err := cmd.idArg("get", args)
+	if err != nil {
+		return err
+	}
+
+	snippet, err := cmd.client.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.json {
+		return cmd.writeJSON(snippet)
+	}
+	content := snippet.Content
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	_, err = io.WriteString(cmd.stdout, content)
+	return err
+}
+
+// list prints a page of the valid snippets.
+func (cmd *command) list(args []string) error {
+	flags := cmd.flagSet("list")
+	page := flags.Int("page", 1, "number of the page")
+	pageSize := flags.Int("page-size", 20, "number of snippets in a page")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	snippets, err := cmd.client.List(*page, *pageSize)
+	if err != nil {
+		return err
+	}
+
+	if cmd.json {
+		return cmd.writeJSON(snippets)
+	}
+	tw := tabwriter.NewWriter(cmd.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tLANGUAGE\tEXPIRES")
+	for _, s := range snippets.Snippets {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.ID, s.Title, s.Language, s.Expires.Format("2006-01-02 15:04"))
+	}
+	tw.Flush()
+	fmt.Fprintf(cmd.stdout, "Page %d of %d, %d snippets\n", snippets.Metadata.Page, snippets.Metadata.LastPage, snippets.Metadata.Total)
+	return nil
+}
+
+// delete deletes a snippet.
+func (cmd *command) delete(args []string) error {
+	id, err := cmd.idArg("delete", args)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.client.Delete(id)
+	if err != nil {
+		return err
+	}
+
+	if !cmd.json {
+		fmt.Fprintf(cmd.stdout, "Deleted snippet #%d\n", id)
+	}
+	return nil
+}
+
+// flagSet returns the flag set of a command.
+func (cmd *command) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet("snippet "+name, flag.ContinueOnError)
+	flags.SetOutput(cmd.stderr)
+	return flags
+}
+
+// parseFlags parses the arguments of the commands taking flags only. It
+// returns flag.ErrHelp if the help is requested, errUsage if the arguments
+// are invalid.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	if err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// idArg parses the arguments of the commands taking a snippet ID only.
+func (cmd *command) idArg(name string, args []string) (int, error) {
+	if len(args) == 1 {
+		id, err := strconv.Atoi(args[0])
+		if err == nil && id > 0 {
+			return id, nil
+		}
+	}
+
+	fmt.Fprintf(cmd.stderr, "Usage: snippet %s <id>\n", name)
+	return 0, errUsage
+}
+
+// writeJSON prints v as indented JSON.
+func (cmd *command) writeJSON(v any) error {
+	enc := json.NewEncoder(cmd.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
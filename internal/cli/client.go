@@ -0,0 +1,255 @@
+// Package cli implements snippet, the command-line client of Snippetbox,
+// which manages the snippets through the JSON API of a server.
+//
+// The client is configured with a JSON file containing the URL of the
+// server and a personal API token, created from the account page:
+//
+//	{
+//		"url": "https://localhost:8080",
+//		"token": "sbx_...",
+//		"ca_cert": "./tls/cert.pem"
+//	}
+//
+// The optional CA certificate is trusted in addition to the system ones, so
+// the client can talk to a server using a self-signed certificate.
+package cli
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the client.
+type Config struct {
+	URL    string `json:"url"`
+	Token  string `json:"token"`
+	CACert string `json:"ca_cert,omitempty"`
+}
+
+// DefaultConfigPath returns the path of the configuration file used when none
+// is given: the SNIPPET_CONFIG environment variable if set, otherwise
+// snippetbox/config.json in the user configuration directory.
+func DefaultConfigPath() (string, error) {
+	if path := os.Getenv("SNIPPET_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snippetbox", "config.json"), nil
+}
+
+// LoadConfig reads the configuration file at path. A relative CA certificate
+// path is resolved from the directory of the file.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var cfg Config
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return Config{}, fmt.Errorf("config %s: %w", path, err)
+	}
+
+	if cfg.URL == "" || cfg.Token == "" {
+		return Config{}, fmt.Errorf("config %s: url and token are required", path)
+	}
+
+	if cfg.CACert != "" && !filepath.IsAbs(cfg.CACert) {
+		cfg.CACert = filepath.Join(filepath.Dir(path), cfg.CACert)
+	}
+
+	return cfg, nil
+}
+
+// Snippet is a snippet returned by the API.
+type Snippet struct {
+	ID       int       `json:"id"`
+	UserID   int       `json:"user_id,omitempty"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	Language string    `json:"language,omitempty"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"`
+}
+
+// SnippetInput contains the data of a snippet to create. Expires is the
+// number of days the snippet is valid for: 1, 7 or 365.
+type SnippetInput struct {
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Language string `json:"language,omitempty"`
+	Expires  int    `json:"expires"`
+}
+
+// Page is a page of the snippets list.
+type Page struct {
+	Snippets []Snippet `json:"snippets"`
+	Metadata struct {
+		Page     int `json:"page"`
+		PageSize int `json:"page_size"`
+		Total    int `json:"total"`
+		LastPage int `json:"last_page"`
+	} `json:"metadata"`
+}
+
+// APIError is an error response of the API. Fields contains the validation
+// errors of the request fields, if any.
+type APIError struct {
+	Status  int               `json:"-"`
+	Message string            `json:"error"`
+	Fields  map[string]string `json:"fields"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%d %s", e.Status, e.Message)
+
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		msg += fmt.Sprintf("\n  %s: %s", field, e.Fields[field])
+	}
+
+	return msg
+}
+
+// Client is a client of the JSON API of a Snippetbox server.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient returns a client of the server in the configuration.
+func NewClient(cfg Config) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", cfg.CACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &Client{
+		baseURL:    strings.TrimRight(cfg.URL, "/"),
+		token:      cfg.Token,
+		httpClient: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// ViewURL returns the URL of the page of a snippet.
+func (c *Client) ViewURL(id int) string {
+	return fmt.Sprintf("%s/snippet/view/%d/", c.baseURL, id)
+}
+
+// Create creates a snippet.
+func (c *Client) Create(input SnippetInput) (Snippet, error) {
+	var rs struct {
+		Snippet Snippet `json:"snippet"`
+	}
+	err := c.do(http.MethodPost, "/snippets", input, &rs)
+	return rs.Snippet, err
+}
+
+// Get returns a snippet.
+func (c *Client) Get(id int) (Snippet, error) {
+	var rs struct {
+		Snippet Snippet `json:"snippet"`
+	}
+	err := c.do(http.MethodGet, fmt.Sprintf("/snippets/%d", id), nil, &rs)
+	return rs.Snippet, err
+}
+
+// List returns a page of the snippets, newest first.
+func (c *Client) List(page int, pageSize int) (Page, error) {
+	query := url.Values{}
+	query.Set("page", fmt.Sprint(page))
+	query.Set("page_size", fmt.Sprint(pageSize))
+
+	var rs Page
+	err := c.do(http.MethodGet, "/snippets?"+query.Encode(), nil, &rs)
+	return rs, err
+}
+
+// Delete deletes a snippet.
+func (c *Client) Delete(id int) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/snippets/%d", id), nil, nil)
+}
+
+// do sends a request to the API, with body encoded as JSON if it isn't nil,
+// and decodes the response into dst if it isn't nil. Error responses are
+// returned as an *APIError.
+func (c *Client) do(method string, path string, body any, dst any) error {
+	var reqBody io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+"/api/v1"+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rs, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode >= 400 {
+		apiErr := &APIError{Status: rs.StatusCode}
+		err = json.NewDecoder(rs.Body).Decode(apiErr)
+		if err != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(rs.StatusCode)
+		}
+		return apiErr
+	}
+
+	if dst == nil {
+		return nil
+	}
+
+	err = json.NewDecoder(rs.Body).Decode(dst)
+	if err != nil {
+		return errors.New("invalid response from the server: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+// Package migrate applies versioned SQL migrations to a database.
+//
+// The migrations are read from a file system, as pairs of files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql. The migrations which
+// have been applied are recorded in the schema_migrations table. Each
+// migration is applied or reverted in its own transaction together with its
+// record, so a migration which fails leaves the database as it was.
+//
+// The migrations are written in the SQL dialect of the database, SQLite or
+// PostgreSQL, which both support transactional schema changes.
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Dialect is the SQL dialect of a database.
+type Dialect string
+
+// Dialects of the supported databases.
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// ErrNoMigration is returned when there is no migration to revert, or when a
+// version doesn't match any migration.
+var ErrNoMigration = errors.New("migrate: no such migration")
+
+// Migration is a versioned change of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is the state of a migration in a database. Applied is the time it
+// was applied at, if it has been.
+type Status struct {
+	Migration
+	Applied sql.NullTime
+}
+
+// fileRX matches the names of the migration files.
+var fileRX = regexp.MustCompile(`^([0-9]+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in the root directory of fsys, sorted by version.
+// Every migration must have both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		matches := fileRX.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(matches[1])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migrate: invalid version in %s", entry.Name())
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		}
+		if m.Name != matches[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by %s and %s", version, m.Name, matches[2])
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(".", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if matches[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrate: migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    Dialect
+	Migrations []Migration
+
+	// Baseline, if set, is called in a transaction the first time the
+	// migrations are used on a database, to detect a database created before
+	// them. It brings the database up to the schema of a version, which is
+	// returned; the migrations up to that version are then recorded as
+	// applied without being run. It returns 0 for an empty database.
+	Baseline func(tx *sql.Tx) (int, error)
+}
+
+// New returns a Migrator of a database of a dialect, with the migrations read
+// from fsys.
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	if dialect != SQLite && dialect != Postgres {
+		return nil, fmt.Errorf("migrate: unknown dialect %q", dialect)
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, Dialect: dialect, Migrations: migrations}, nil
+}
+
+// init creates the schema_migrations table if it doesn't exist, calling the
+// baseline function when it does so.
+func (m *Migrator) init() error {
+	var exists bool
+
+	// The table is looked up in the current schema of PostgreSQL.
+	query := "SELECT EXISTS(SELECT true FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')"
+	timeType := "DATETIME"
+	if m.Dialect == Postgres {
+		query = "SELECT to_regclass('schema_migrations') IS NOT NULL"
+		timeType = "TIMESTAMPTZ"
+	}
+
+	err := m.DB.QueryRow(query).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	return m.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE schema_migrations (
+				version INTEGER PRIMARY KEY,
+				name VARCHAR(255) NOT NULL,
+				applied ` + timeType + ` NOT NULL
+			)`)
+		if err != nil || m.Baseline == nil {
+			return err
+		}
+
+		version, err := m.Baseline(tx)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			return nil
+		}
+
+		if _, ok := m.find(version); !ok {
+			return fmt.Errorf("%w: baseline version %d", ErrNoMigration, version)
+		}
+		for _, migration := range m.Migrations {
+			if migration.Version <= version {
+				err = record(tx, migration)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Status returns the state of every migration, by version.
+func (m *Migrator) Status() ([]Status, error) {
+	err := m.init()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query("SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var t time.Time
+		err = rows.Scan(&version, &t)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = t
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.Migrations))
+	for i, migration := range m.Migrations {
+		statuses[i].Migration = migration
+		if t, ok := applied[migration.Version]; ok {
+			statuses[i].Applied = sql.NullTime{Time: t, Valid: true}
+		}
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations which haven't been applied, by version.
+func (m *Migrator) Pending() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, s := range statuses {
+		if !s.Applied.Valid {
+			pending = append(pending, s.Migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Up applies the pending migrations in order, and returns the ones it has
+// applied. It stops at the first migration which fails.
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range pending {
+		err = m.inTx(func(tx *sql.Tx) error {
+			_, err := tx.Exec(migration.Up)
+			if err != nil {
+				return err
+			}
+			return record(tx, migration)
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migrate: applying %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Down reverts the last applied migration and returns it. It returns
+// ErrNoMigration if no migration has been applied.
+func (m *Migrator) Down() (Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return Migration{}, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if !statuses[i].Applied.Valid {
+			continue
+		}
+
+		migration := statuses[i].Migration
+		err = m.inTx(func(tx *sql.Tx) error {
+			_, err := tx.Exec(migration.Down)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			return err
+		})
+		if err != nil {
+			return Migration{}, fmt.Errorf("migrate: reverting %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		return migration, nil
+	}
+
+	return Migration{}, ErrNoMigration
+}
+
+// Force records the migrations up to version as applied and the following
+// ones as pending, without running any of them. It is used to repair the
+// records after the schema has been fixed by hand. Version 0 records every
+// migration as pending.
+func (m *Migrator) Force(version int) error {
+	if _, ok := m.find(version); !ok && version != 0 {
+		return fmt.Errorf("%w: version %d", ErrNoMigration, version)
+	}
+
+	err := m.init()
+	if err != nil {
+		return err
+	}
+
+	return m.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM schema_migrations")
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			if migration.Version <= version {
+				err = record(tx, migration)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// find returns the migration with a version.
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.Migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds and rolled
+// back otherwise.
+func (m *Migrator) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// record records a migration as applied. The $N placeholders of PostgreSQL
+// are understood by SQLite too.
+func record(tx *sql.Tx, migration Migration) error {
+	query := "INSERT INTO schema_migrations (version, name, applied) VALUES($1, $2, $3)"
+
+	_, err := tx.Exec(query, migration.Version, migration.Name, time.Now().UTC())
+	return err
+}
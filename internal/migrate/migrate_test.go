@@ -0,0 +1,207 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// testFiles contains two valid migrations, out of order.
+var testFiles = fstest.MapFS{
+	"0002_add_color.up.sql":   {Data: []byte("ALTER TABLE items ADD COLUMN color TEXT NOT NULL DEFAULT '';")},
+	"0002_add_color.down.sql": {Data: []byte("ALTER TABLE items DROP COLUMN color;")},
+	"0001_items.up.sql":       {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY); CREATE INDEX idx_items ON items (id);")},
+	"0001_items.down.sql":     {Data: []byte("DROP TABLE items;")},
+	"README.md":               {Data: []byte("Not a migration.")},
+}
+
+// newTestMigrator returns a migrator of the test migrations on a temporary
+// database.
+func newTestMigrator(t *testing.T, files fstest.MapFS) *Migrator {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := New(db, SQLite, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// columnExists reports whether the items table has a column.
+func columnExists(t *testing.T, db *sql.DB, name string) bool {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT true FROM pragma_table_info('items') WHERE name = ?)", name).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exists
+}
+
+// versions returns the versions of the applied migrations.
+func versions(t *testing.T, m *Migrator) []int {
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var applied []int
+	for _, s := range statuses {
+		if s.Applied.Valid {
+			applied = append(applied, s.Version)
+		}
+	}
+	return applied
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(migrations), 2)
+	assert.Equal(t, migrations[0].Version, 1)
+	assert.Equal(t, migrations[0].Name, "items")
+	assert.Equal(t, migrations[1].Version, 2)
+	assert.Equal(t, migrations[1].Down, "ALTER TABLE items DROP COLUMN color;")
+
+	_, err = Load(fstest.MapFS{"0001_items.up.sql": {Data: []byte("SELECT 1;")}})
+	assert.Equal(t, err != nil, true)
+
+	_, err = Load(fstest.MapFS{
+		"0001_items.up.sql":   {Data: []byte("SELECT 1;")},
+		"0001_items.down.sql": {Data: []byte("SELECT 1;")},
+		"0001_other.up.sql":   {Data: []byte("SELECT 1;")},
+	})
+	assert.Equal(t, err != nil, true)
+}
+
+func TestNewUnknownDialect(t *testing.T) {
+	_, err := New(nil, "oracle", testFiles)
+	assert.Equal(t, err != nil, true)
+}
+
+func TestUpDown(t *testing.T) {
+	m := newTestMigrator(t, testFiles)
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(applied), 2)
+	assert.Equal(t, columnExists(t, m.DB, "color"), true)
+	assert.Equal(t, len(versions(t, m)), 2)
+
+	// Nothing is left to apply.
+	applied, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(applied), 0)
+
+	migration, err := m.Down()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, migration.Version, 2)
+	assert.Equal(t, columnExists(t, m.DB, "color"), false)
+	assert.Equal(t, len(versions(t, m)), 1)
+
+	migration, err = m.Down()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, migration.Version, 1)
+
+	_, err = m.Down()
+	assert.Equal(t, errors.Is(err, ErrNoMigration), true)
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	files := fstest.MapFS{
+		"0001_items.up.sql":     testFiles["0001_items.up.sql"],
+		"0001_items.down.sql":   testFiles["0001_items.down.sql"],
+		"0002_broken.up.sql":    {Data: []byte("ALTER TABLE items ADD COLUMN size INTEGER; INSERT INTO missing VALUES (1);")},
+		"0002_broken.down.sql":  {Data: []byte("SELECT 1;")},
+		"0003_skipped.up.sql":   {Data: []byte("SELECT 1;")},
+		"0003_skipped.down.sql": {Data: []byte("SELECT 1;")},
+	}
+	m := newTestMigrator(t, files)
+
+	applied, err := m.Up()
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, len(applied), 1)
+
+	// The first statement of the failed migration has been rolled back, and
+	// the following migration hasn't been applied.
+	assert.Equal(t, columnExists(t, m.DB, "size"), false)
+	assert.Equal(t, len(versions(t, m)), 1)
+}
+
+func TestForce(t *testing.T) {
+	m := newTestMigrator(t, testFiles)
+
+	err := m.Force(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(versions(t, m)), 2)
+
+	// No migration has been run.
+	var exists bool
+	err = m.DB.QueryRow("SELECT EXISTS(SELECT true FROM sqlite_master WHERE name = 'items')").Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, exists, false)
+
+	err = m.Force(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(versions(t, m)), 0)
+
+	err = m.Force(3)
+	assert.Equal(t, errors.Is(err, ErrNoMigration), true)
+}
+
+func TestBaseline(t *testing.T) {
+	m := newTestMigrator(t, testFiles)
+
+	// The database has been created before the migrations, with the schema
+	// of the first one.
+	_, err := m.DB.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	m.Baseline = func(tx *sql.Tx) (int, error) {
+		calls++
+		return 1, nil
+	}
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(applied), 1)
+	assert.Equal(t, applied[0].Version, 2)
+	assert.Equal(t, len(versions(t, m)), 2)
+
+	// The baseline is only made once.
+	_, err = m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, calls, 1)
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Actions recorded in the audit trail.
+const (
+	AuditDismissReports = "dismiss_reports"
+	AuditHideSnippet    = "hide_snippet"
+	AuditDeleteSnippet  = "delete_snippet"
+	AuditSuspendUser    = "suspend_user"
+	AuditUnsuspendUser  = "unsuspend_user"
+	AuditChangeRole     = "change_role"
+)
+
+// AuditEntry is a struct containing a moderation action, made by the actor on
+// a snippet and/or a user. SnippetID and UserID are 0 when they don't apply.
+// They aren't foreign keys, so the entries outlive what they refer to.
+type AuditEntry struct {
+	ID        int
+	ActorID   int
+	Action    string
+	SnippetID int
+	UserID    int
+	Details   string
+	Created   time.Time
+}
+
+// AuditModelInterface interface. The entries are recorded by ModerationModel,
+// together with the actions they describe.
+type AuditModelInterface interface {
+	Latest(limit int) ([]AuditEntry, error)
+}
+
+// AuditModel is a struct used to call DB operations.
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// Latest is a method used to get the latest entries of the audit trail.
+func (m *AuditModel) Latest(limit int) ([]AuditEntry, error) {
+	query := `SELECT id, actor_id, action, snippet_id, user_id, details, created FROM audit_log
+			  ORDER BY id DESC LIMIT ?`
+
+	results, err := m.DB.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var entries []AuditEntry
+
+	for results.Next() {
+		var e AuditEntry
+		err := results.Scan(&e.ID, &e.ActorID, &e.Action, &e.SnippetID, &e.UserID, &e.Details, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Credential is a struct containing the data of a WebAuthn credential (passkey).
+type Credential struct {
+	ID           int
+	UserID       int
+	Name         string
+	CredentialID []byte
+	PublicKey    []byte
+	SignCount    uint32
+	Created      time.Time
+	LastUsed     sql.NullTime
+}
+
+// CredentialModelInterface interface.
+type CredentialModelInterface interface {
+	Insert(userID int, name string, credentialID, publicKey []byte, signCount uint32) error
+	Get(credentialID []byte) (Credential, error)
+	GetByUser(userID int) ([]Credential, error)
+	UpdateSignCount(id int, signCount uint32) error
+	Delete(id, userID int) error
+}
+
+// CredentialModel is a struct used to call DB operations.
+type CredentialModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new credential to the credentials table.
+func (m *CredentialModel) Insert(userID int, name string, credentialID, publicKey []byte, signCount uint32) error {
+	query := `INSERT INTO credentials (user_id, name, credential_id, public_key, sign_count, created)
+			  VALUES(?, ?, ?, ?, ?, datetime())`
+
+	_, err := m.DB.Exec(query, userID, name, credentialID, publicKey, signCount)
+	return err
+}
+
+// Get is a method used to get a credential by the ID assigned by the authenticator.
+func (m *CredentialModel) Get(credentialID []byte) (Credential, error) {
+	query := `SELECT id, user_id, name, credential_id, public_key, sign_count, created, last_used
+			  FROM credentials WHERE credential_id = ?`
+
+	var c Credential
+	err := m.DB.QueryRow(query, credentialID).Scan(&c.ID, &c.UserID, &c.Name, &c.CredentialID,
+		&c.PublicKey, &c.SignCount, &c.Created, &c.LastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Credential{}, ErrNoRecord
+		} else {
+			return Credential{}, err
+		}
+	}
+
+	return c, nil
+}
+
+// GetByUser is a method used to get all the credentials registered by a user.
+func (m *CredentialModel) GetByUser(userID int) ([]Credential, error) {
+	query := `SELECT id, user_id, name, credential_id, public_key, sign_count, created, last_used
+			  FROM credentials WHERE user_id = ? ORDER BY id`
+
+	results, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var credentials []Credential
+
+	for results.Next() {
+		var c Credential
+		err := results.Scan(&c.ID, &c.UserID, &c.Name, &c.CredentialID,
+			&c.PublicKey, &c.SignCount, &c.Created, &c.LastUsed)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, c)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// UpdateSignCount stores the signature counter received with the latest
+// successful assertion and marks the credential as used.
+func (m *CredentialModel) UpdateSignCount(id int, signCount uint32) error {
+	query := "UPDATE credentials SET sign_count = ?, last_used = datetime() WHERE id = ?"
+
+	_, err := m.DB.Exec(query, signCount, id)
+	return err
+}
+
+// Delete removes a credential owned by a user. It returns ErrNoRecord if no
+// such credential exists.
+func (m *CredentialModel) Delete(id, userID int) error {
+	query := "DELETE FROM credentials WHERE id = ? AND user_id = ?"
+
+	result, err := m.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -1,6 +1,12 @@
 package models
 
-import "errors"
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+)
 
 // ErrNoRecord is a custom error which occurs when no rows
 // has been retrieved by a SQL query.
@@ -13,3 +19,30 @@ var ErrInvalidCredentials = errors.New("models: invalid credentials")
 // ErrDuplicateEmail is a custom error which occurs when a user
 // tries to signup with an email address that's already in use.
 var ErrDuplicateEmail = errors.New("models: duplicate email")
+
+// ErrForeignKeyViolation is a custom error which occurs when a record
+// refers to another one which doesn't exist, e.g. a snippet of an unknown
+// user.
+var ErrForeignKeyViolation = errors.New("models: foreign key violation")
+
+// ErrDuplicateReport is a custom error which occurs when a user
+// tries to report a snippet they have already reported.
+var ErrDuplicateReport = errors.New("models: duplicate report")
+
+// sqliteError translates an error of the SQLite driver into the error of the
+// models it stands for: ErrNoRecord when no row has been found,
+// ErrDuplicateEmail when the unique email of the users is violated and
+// ErrForeignKeyViolation when a foreign key is. The other errors are returned
+// as they are.
+func sqliteError(err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNoRecord
+	case errors.Is(err, sqlite3.CONSTRAINT_UNIQUE) && strings.Contains(err.Error(), "users.email"):
+		return ErrDuplicateEmail
+	case errors.Is(err, sqlite3.CONSTRAINT_FOREIGNKEY):
+		return ErrForeignKeyViolation
+	default:
+		return err
+	}
+}
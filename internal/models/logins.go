@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// LoginFailures is a struct containing a summary of the failed login attempts
+// made with the same key (an email address or an IP address).
+type LoginFailures struct {
+	Count int
+	Last  time.Time
+}
+
+// LoginAttemptModelInterface interface.
+type LoginAttemptModelInterface interface {
+	Insert(email, ip string, window time.Duration) (int, LoginFailures, LoginFailures, error)
+	Failures(email, ip string, window time.Duration) (LoginFailures, LoginFailures, error)
+	Delete(id int) error
+	Reset(email string) error
+}
+
+// LoginAttemptModel is a struct used to call DB operations.
+type LoginAttemptModel struct {
+	DB *sql.DB
+}
+
+// Insert records a login attempt for an email address from an IP address,
+// before the password is checked, and returns its ID along with the failed
+// attempts made before it within the time window, both for the email address
+// and for the IP address. The attempt is recorded and the failures counted in
+// a single write transaction, so concurrent attempts are serialised and each
+// one counts those made before it.
+// The attempts older than the time window of the throttling, which are never
+// counted again, are deleted along the way so that the table doesn't grow
+// without bound.
+func (m *LoginAttemptModel) Insert(email, ip string, window time.Duration) (int, LoginFailures, LoginFailures, error) {
+	// A serializable transaction is started with BEGIN IMMEDIATE, which takes
+	// the write lock straight away.
+	tx, err := m.DB.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return 0, LoginFailures{}, LoginFailures{}, err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	query := "DELETE FROM login_attempts WHERE created <= datetime('now', ?)"
+
+	_, err = tx.Exec(query, modifier(window))
+	if err != nil {
+		return 0, LoginFailures{}, LoginFailures{}, err
+	}
+
+	query = `INSERT INTO login_attempts (email, ip, created) VALUES(?, ?, datetime())`
+
+	result, err := tx.Exec(query, email, ip)
+	if err != nil {
+		return 0, LoginFailures{}, LoginFailures{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, LoginFailures{}, LoginFailures{}, err
+	}
+
+	byEmail, byIP, err := failures(tx, email, ip, window, int(id))
+	if err != nil {
+		return 0, LoginFailures{}, LoginFailures{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, LoginFailures{}, LoginFailures{}, err
+	}
+
+	return int(id), byEmail, byIP, nil
+}
+
+// Failures returns the failed login attempts made within a time window, both
+// for an email address and for an IP address.
+func (m *LoginAttemptModel) Failures(email, ip string, window time.Duration) (LoginFailures, LoginFailures, error) {
+	return failures(m.DB, email, ip, window, 0)
+}
+
+// queryRower is implemented by *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// failures returns the failed login attempts made within a time window for
+// an email address and for an IP address, leaving out the attempt with the
+// ID excluded.
+func failures(db queryRower, email, ip string, window time.Duration, excluded int) (LoginFailures, LoginFailures, error) {
+	byEmail, err := countFailures(db, "email", email, window, excluded)
+	if err != nil {
+		return LoginFailures{}, LoginFailures{}, err
+	}
+
+	byIP, err := countFailures(db, "ip", ip, window, excluded)
+	if err != nil {
+		return LoginFailures{}, LoginFailures{}, err
+	}
+
+	return byEmail, byIP, nil
+}
+
+// countFailures counts the failed login attempts matching a value of a column.
+// The column name is never taken from user input.
+func countFailures(db queryRower, column, value string, window time.Duration, excluded int) (LoginFailures, error) {
+	// The window is passed as a datetime() modifier, e.g. '-3600 seconds'.
+	query := `SELECT COUNT(*), unixepoch(MAX(created)) FROM login_attempts
+			  WHERE ` + column + ` = ? AND created > datetime('now', ?) AND id <> ?`
+
+	var f LoginFailures
+	var last sql.NullInt64
+	err := db.QueryRow(query, value, modifier(window), excluded).Scan(&f.Count, &last)
+	if err != nil {
+		return LoginFailures{}, err
+	}
+
+	if last.Valid {
+		f.Last = time.Unix(last.Int64, 0).UTC()
+	}
+
+	return f, nil
+}
+
+// Delete forgets a login attempt, when it has been refused without checking
+// the password.
+func (m *LoginAttemptModel) Delete(id int) error {
+	query := "DELETE FROM login_attempts WHERE id = ?"
+
+	_, err := m.DB.Exec(query, id)
+	return err
+}
+
+// Reset deletes the failed login attempts of an email address, after a
+// successful login.
+func (m *LoginAttemptModel) Reset(email string) error {
+	query := "DELETE FROM login_attempts WHERE email = ?"
+
+	_, err := m.DB.Exec(query, email)
+	return err
+}
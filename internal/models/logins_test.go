@@ -0,0 +1,56 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func TestLoginAttemptModelInsert(t *testing.T) {
+	db := newTestDB(t)
+	m := models.LoginAttemptModel{DB: db}
+
+	_, _, _, err := m.Insert("alice@example.com", "192.0.2.1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, err = m.Insert("bob@example.com", "192.0.2.2", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Move the attempt of Alice out of the window.
+	_, err = db.Exec("UPDATE login_attempts SET created = datetime('now', '-2 hours') WHERE email = 'alice@example.com'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next attempt deletes the attempts which have left the window, even
+	// those of other email and IP addresses.
+	_, _, _, err = m.Insert("carol@example.com", "192.0.2.3", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM login_attempts WHERE email = 'alice@example.com'").Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, count, 0)
+
+	err = db.QueryRow("SELECT COUNT(*) FROM login_attempts").Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, count, 2)
+
+	byEmail, byIP, err := m.Failures("bob@example.com", "192.0.2.2", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, byEmail.Count, 1)
+	assert.Equal(t, byIP.Count, 1)
+}
@@ -0,0 +1,37 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// AuditModel keeps the audit trail in memory, so tests can check which
+// actions have been recorded.
+type AuditModel struct {
+	mu      sync.Mutex
+	entries []models.AuditEntry
+}
+
+// Insert records an entry, as ModerationModel does along with each action.
+func (m *AuditModel) Insert(entry models.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry.ID = len(m.entries) + 1
+	entry.Created = time.Now()
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func (m *AuditModel) Latest(limit int) ([]models.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []models.AuditEntry
+	for i := len(m.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, m.entries[i])
+	}
+	return entries, nil
+}
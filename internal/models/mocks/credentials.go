@@ -0,0 +1,85 @@
+package mocks
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// CredentialModel keeps the registered credentials in memory, so tests can
+// run a full registration and login with a software authenticator.
+type CredentialModel struct {
+	mu          sync.Mutex
+	lastID      int
+	credentials []models.Credential
+}
+
+func (m *CredentialModel) Insert(userID int, name string, credentialID, publicKey []byte, signCount uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	m.credentials = append(m.credentials, models.Credential{
+		ID:           m.lastID,
+		UserID:       userID,
+		Name:         name,
+		CredentialID: credentialID,
+		PublicKey:    publicKey,
+		SignCount:    signCount,
+		Created:      time.Now(),
+	})
+	return nil
+}
+
+func (m *CredentialModel) Get(credentialID []byte) (models.Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.credentials {
+		if bytes.Equal(c.CredentialID, credentialID) {
+			return c, nil
+		}
+	}
+	return models.Credential{}, models.ErrNoRecord
+}
+
+func (m *CredentialModel) GetByUser(userID int) ([]models.Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var credentials []models.Credential
+	for _, c := range m.credentials {
+		if c.UserID == userID {
+			credentials = append(credentials, c)
+		}
+	}
+	return credentials, nil
+}
+
+func (m *CredentialModel) UpdateSignCount(id int, signCount uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.credentials {
+		if m.credentials[i].ID == id {
+			m.credentials[i].SignCount = signCount
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *CredentialModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, c := range m.credentials {
+		if c.ID == id && c.UserID == userID {
+			m.credentials = append(m.credentials[:i], m.credentials[i+1:]...)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
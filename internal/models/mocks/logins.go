@@ -0,0 +1,102 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// loginAttempt is a failed login attempt recorded by LoginAttemptModel.
+type loginAttempt struct {
+	id      int
+	email   string
+	ip      string
+	created time.Time
+}
+
+// LoginAttemptModel keeps the failed login attempts in memory, so tests can
+// trigger the login throttling.
+type LoginAttemptModel struct {
+	mu       sync.Mutex
+	attempts []loginAttempt
+	lastID   int
+}
+
+func (m *LoginAttemptModel) Insert(email, ip string, window time.Duration) (int, models.LoginFailures, models.LoginFailures, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var attempts []loginAttempt
+	since := time.Now().Add(-window)
+	for _, a := range m.attempts {
+		if a.created.After(since) {
+			attempts = append(attempts, a)
+		}
+	}
+	byEmail, byIP := failures(attempts, email, ip)
+
+	m.lastID++
+	m.attempts = append(attempts, loginAttempt{id: m.lastID, email: email, ip: ip, created: time.Now()})
+	return m.lastID, byEmail, byIP, nil
+}
+
+func (m *LoginAttemptModel) Failures(email, ip string, window time.Duration) (models.LoginFailures, models.LoginFailures, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var attempts []loginAttempt
+	since := time.Now().Add(-window)
+	for _, a := range m.attempts {
+		if a.created.After(since) {
+			attempts = append(attempts, a)
+		}
+	}
+	byEmail, byIP := failures(attempts, email, ip)
+	return byEmail, byIP, nil
+}
+
+// failures counts the attempts made with an email address and from an IP
+// address.
+func failures(attempts []loginAttempt, email, ip string) (models.LoginFailures, models.LoginFailures) {
+	var byEmail, byIP models.LoginFailures
+	for _, a := range attempts {
+		if a.email == email {
+			byEmail.Count++
+			byEmail.Last = a.created
+		}
+		if a.ip == ip {
+			byIP.Count++
+			byIP.Last = a.created
+		}
+	}
+	return byEmail, byIP
+}
+
+func (m *LoginAttemptModel) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var attempts []loginAttempt
+	for _, a := range m.attempts {
+		if a.id != id {
+			attempts = append(attempts, a)
+		}
+	}
+	m.attempts = attempts
+	return nil
+}
+
+func (m *LoginAttemptModel) Reset(email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var attempts []loginAttempt
+	for _, a := range m.attempts {
+		if a.email != email {
+			attempts = append(attempts, a)
+		}
+	}
+	m.attempts = attempts
+	return nil
+}
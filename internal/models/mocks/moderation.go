@@ -0,0 +1,73 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// ModerationModel takes the moderation actions on the other mock models, and
+// records them in the mock audit trail.
+type ModerationModel struct {
+	Snippets *SnippetModel
+	Users    *UserModel
+	Reports  *ReportModel
+	Audit    *AuditModel
+}
+
+func (m *ModerationModel) DismissReports(ctx context.Context, entry models.AuditEntry) error {
+	err := m.Reports.Dismiss(entry.SnippetID)
+	if err != nil {
+		return err
+	}
+
+	err = m.Snippets.SetHidden(ctx, entry.SnippetID, false)
+	if err != nil {
+		return err
+	}
+
+	return m.Audit.Insert(entry)
+}
+
+func (m *ModerationModel) HideSnippet(ctx context.Context, entry models.AuditEntry) error {
+	err := m.Snippets.SetHidden(ctx, entry.SnippetID, true)
+	if err != nil {
+		return err
+	}
+
+	return m.Audit.Insert(entry)
+}
+
+func (m *ModerationModel) DeleteSnippet(ctx context.Context, entry models.AuditEntry) (models.Snippet, error) {
+	s, err := m.Snippets.GetAny(ctx, entry.SnippetID)
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	err = m.Snippets.Delete(ctx, s.ID)
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	entry.UserID = s.UserID
+	entry.Details = s.Title
+	return s, m.Audit.Insert(entry)
+}
+
+func (m *ModerationModel) SetSuspended(ctx context.Context, entry models.AuditEntry, suspended bool) error {
+	err := m.Users.SetSuspended(ctx, entry.UserID, suspended)
+	if err != nil {
+		return err
+	}
+
+	return m.Audit.Insert(entry)
+}
+
+func (m *ModerationModel) SetRole(ctx context.Context, entry models.AuditEntry, role models.Role) error {
+	err := m.Users.SetRole(ctx, entry.UserID, role)
+	if err != nil {
+		return err
+	}
+
+	return m.Audit.Insert(entry)
+}
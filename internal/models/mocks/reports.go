@@ -0,0 +1,80 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// ReportModel keeps the reports of the mock snippet in memory, so tests can
+// report it and moderate it.
+type ReportModel struct {
+	mu      sync.Mutex
+	reports []mockReport
+}
+
+type mockReport struct {
+	reporterID int
+	reason     string
+	status     string
+	created    time.Time
+}
+
+func (m *ReportModel) Insert(snippetID, reporterID int, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.reports {
+		if r.reporterID == reporterID {
+			return models.ErrDuplicateReport
+		}
+	}
+
+	m.reports = append(m.reports, mockReport{
+		reporterID: reporterID,
+		reason:     reason,
+		status:     models.ReportStatusOpen,
+		created:    time.Now(),
+	})
+	return nil
+}
+
+func (m *ReportModel) Open() ([]models.ReportedSnippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rs := models.ReportedSnippet{Snippet: mockSnippet}
+	for _, r := range m.reports {
+		if r.status == models.ReportStatusOpen {
+			if rs.Reports == 0 {
+				rs.FirstReported = r.created
+			}
+			rs.Reports++
+			rs.Reasons = append(rs.Reasons, r.reason)
+		}
+	}
+
+	if rs.Reports == 0 {
+		return nil, nil
+	}
+	return []models.ReportedSnippet{rs}, nil
+}
+
+func (m *ReportModel) Dismiss(snippetID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dismissed := false
+	for i, r := range m.reports {
+		if r.status == models.ReportStatusOpen {
+			m.reports[i].status = models.ReportStatusDismissed
+			dismissed = true
+		}
+	}
+
+	if !dismissed {
+		return models.ErrNoRecord
+	}
+	return nil
+}
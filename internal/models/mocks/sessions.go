@@ -0,0 +1,96 @@
+package mocks
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// SessionModel keeps the logged in sessions in memory, so tests can list and
+// revoke them.
+type SessionModel struct {
+	mu       sync.Mutex
+	lastID   int
+	sessions []models.Session
+}
+
+func (m *SessionModel) Insert(userID int, userAgent, ip string, rememberMe bool) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	s := models.Session{
+		ID:         strconv.Itoa(m.lastID),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		RememberMe: rememberMe,
+		Created:    time.Now(),
+		LastSeen:   time.Now(),
+	}
+	m.sessions = append(m.sessions, s)
+	return s.ID, nil
+}
+
+func (m *SessionModel) Touch(id string, userID int, ip string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, s := range m.sessions {
+		if s.ID == id && s.UserID == userID {
+			m.sessions[i].LastSeen = time.Now()
+			m.sessions[i].IP = ip
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *SessionModel) GetByUser(userID int) ([]models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []models.Session
+	for _, s := range m.sessions {
+		if s.UserID == userID {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *SessionModel) Count() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.sessions), nil
+}
+
+func (m *SessionModel) Delete(id string, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, s := range m.sessions {
+		if s.ID == id && s.UserID == userID {
+			m.sessions = append(m.sessions[:i], m.sessions[i+1:]...)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SessionModel) DeleteByUser(userID int, exceptID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []models.Session
+	for _, s := range m.sessions {
+		if s.UserID != userID || s.ID == exceptID {
+			sessions = append(sessions, s)
+		}
+	}
+	m.sessions = sessions
+	return nil
+}
@@ -1,6 +1,9 @@
 package mocks
 
 import (
+	"context"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/AlessioPani/go-snippetbox/internal/models"
@@ -8,27 +11,171 @@ import (
 
 var mockSnippet = models.Snippet{
 	ID:      1,
+	UserID:  1,
 	Title:   "An old silent pond",
 	Content: "An old silent pond...",
 	Created: time.Now(),
+	Updated: time.Now(),
 	Expires: time.Now(),
 }
 
-type SnippetModel struct{}
+// SnippetModel keeps in memory the mock snippet and the snippets created by
+// the tests, so they can be moderated, updated and deleted.
+type SnippetModel struct {
+	mu       sync.Mutex
+	snippets map[int]models.Snippet
+	nextID   int
+}
+
+// init adds the mock snippet to the snippets the first time it is called.
+// It must be called with the mutex locked.
+func (m *SnippetModel) init() {
+	if m.snippets == nil {
+		m.snippets = map[int]models.Snippet{mockSnippet.ID: mockSnippet}
+		m.nextID = mockSnippet.ID + 1
+	}
+}
+
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, language string, expires int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+	id := m.nextID
+	m.nextID++
+	m.snippets[id] = models.Snippet{
+		ID:       id,
+		UserID:   userID,
+		Title:    title,
+		Content:  content,
+		Language: language,
+		Created:  time.Now(),
+		Updated:  time.Now(),
+		Expires:  time.Now().AddDate(0, 0, expires),
+	}
+	return id, nil
+}
+
+func (m *SnippetModel) Get(ctx context.Context, id int) (models.Snippet, error) {
+	s, err := m.GetAny(ctx, id)
+	if err != nil {
+		return models.Snippet{}, err
+	}
+	if s.Hidden {
+		return models.Snippet{}, models.ErrNoRecord
+	}
+	return s, nil
 }
 
-func (m *SnippetModel) Get(id int) (models.Snippet, error) {
-	switch id {
-	case 1:
-		return mockSnippet, nil
-	default:
+// GetAny returns the error of the context if it is done, so that tests can
+// check how the handlers deal with a query timing out.
+func (m *SnippetModel) GetAny(ctx context.Context, id int) (models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Snippet{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	s, ok := m.snippets[id]
+	if !ok {
 		return models.Snippet{}, models.ErrNoRecord
 	}
+	return s, nil
+}
+
+func (m *SnippetModel) Latest(ctx context.Context) ([]models.Snippet, error) {
+	snippets, _, err := m.Page(ctx, 1, 10)
+	return snippets, err
+}
+
+func (m *SnippetModel) LatestBy(ctx context.Context, filter models.SnippetFilter) ([]models.Snippet, error) {
+	all, _ := m.List(ctx, models.SnippetFilter{})
+
+	var snippets []models.Snippet
+	for _, s := range all {
+		if s.Hidden || (filter.UserID != 0 && s.UserID != filter.UserID) ||
+			(filter.Language != "" && !strings.EqualFold(s.Language, filter.Language)) {
+			continue
+		}
+		snippets = append(snippets, s)
+		if len(snippets) == 10 {
+			break
+		}
+	}
+	return snippets, nil
 }
 
-func (m *SnippetModel) Latest() ([]models.Snippet, error) {
-	return []models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) List(ctx context.Context, filter models.SnippetFilter) ([]models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	var snippets []models.Snippet
+	for id := m.nextID - 1; id > 0; id-- {
+		if s, ok := m.snippets[id]; ok {
+			snippets = append(snippets, s)
+		}
+	}
+	return snippets, nil
+}
+
+func (m *SnippetModel) Page(ctx context.Context, page int, pageSize int) ([]models.Snippet, int, error) {
+	all, _ := m.List(ctx, models.SnippetFilter{})
+
+	var visible []models.Snippet
+	for _, s := range all {
+		if !s.Hidden {
+			visible = append(visible, s)
+		}
+	}
+
+	start := min((page-1)*pageSize, len(visible))
+	end := min(start+pageSize, len(visible))
+	return visible[start:end], len(visible), nil
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, language string, expires int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	s, ok := m.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.Title = title
+	s.Content = content
+	s.Language = language
+	s.Updated = time.Now()
+	s.Expires = time.Now().AddDate(0, 0, expires)
+	m.snippets[id] = s
+	return nil
+}
+
+func (m *SnippetModel) SetHidden(ctx context.Context, id int, hidden bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	s, ok := m.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.Hidden = hidden
+	m.snippets[id] = s
+	return nil
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	if _, ok := m.snippets[id]; !ok {
+		return models.ErrNoRecord
+	}
+	delete(m.snippets, id)
+	return nil
 }
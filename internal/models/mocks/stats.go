@@ -0,0 +1,18 @@
+package mocks
+
+import (
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+var mockStats = models.Stats{
+	Users:           2,
+	LiveSnippets:    1,
+	ExpiredSnippets: 0,
+	Signups:         []models.DailyCount{{Day: "2024-12-01", Count: 2}},
+}
+
+type StatsModel struct{}
+
+func (m *StatsModel) Get(days int) (models.Stats, error) {
+	return mockStats, nil
+}
@@ -0,0 +1,83 @@
+package mocks
+
+import (
+	"database/sql"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// TokenModel keeps the API tokens in memory, so tests can create them and use
+// them to authenticate.
+type TokenModel struct {
+	mu     sync.Mutex
+	lastID int
+	tokens map[string]models.Token
+}
+
+func (m *TokenModel) Insert(userID int, name string, scopes []string, expiresIn int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	t := models.Token{
+		ID:      m.lastID,
+		UserID:  userID,
+		Name:    name,
+		Scopes:  scopes,
+		Created: time.Now(),
+	}
+	if expiresIn > 0 {
+		t.Expires = sql.NullTime{Time: time.Now().AddDate(0, 0, expiresIn), Valid: true}
+	}
+
+	token := "sbx_mock" + strconv.Itoa(t.ID)
+	if m.tokens == nil {
+		m.tokens = map[string]models.Token{}
+	}
+	m.tokens[token] = t
+	return token, nil
+}
+
+func (m *TokenModel) Authenticate(token string) (models.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[token]
+	if !ok || (t.Expires.Valid && t.Expires.Time.Before(time.Now())) {
+		return models.Token{}, models.ErrInvalidCredentials
+	}
+	if !t.Used(models.TokenUseInterval) {
+		t.LastUsed = sql.NullTime{Time: time.Now(), Valid: true}
+		m.tokens[token] = t
+	}
+	return t, nil
+}
+
+func (m *TokenModel) GetByUser(userID int) ([]models.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var tokens []models.Token
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+func (m *TokenModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for token, t := range m.tokens {
+		if t.ID == id && t.UserID == userID {
+			delete(m.tokens, token)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/AlessioPani/go-snippetbox/internal/models"
@@ -11,12 +13,40 @@ var mockUser = models.User{
 	Name:           "John Doe",
 	Email:          "test@test.com",
 	HashedPassword: []byte("password"),
+	Role:           models.RoleUser,
 	Created:        time.Now(),
 }
 
-type UserModel struct{}
+var mockAdmin = models.User{
+	ID:             2,
+	Name:           "Jane Doe",
+	Email:          "admin@test.com",
+	HashedPassword: []byte("password"),
+	Role:           models.RoleAdmin,
+	Created:        time.Now(),
+}
+
+// UserModel keeps the changes made to the mock users in memory, so tests can
+// suspend them or change their role.
+type UserModel struct {
+	mu        sync.Mutex
+	suspended map[int]bool
+	roles     map[int]models.Role
+}
+
+// user returns a mock user with the changes made to it.
+func (m *UserModel) user(u models.User) models.User {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u.Suspended = m.suspended[u.ID]
+	if role, ok := m.roles[u.ID]; ok {
+		u.Role = role
+	}
+	return u
+}
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
 	switch email {
 	case "duplicate@mail.com":
 		return models.ErrDuplicateEmail
@@ -25,27 +55,74 @@ func (m *UserModel) Insert(name, email, password string) error {
 	}
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
 	if email == "test@test.com" && password == "password" {
 		return 1, nil
 	}
+	if email == "admin@test.com" && password == "password" {
+		return 2, nil
+	}
 	return 0, models.ErrInvalidCredentials
 }
 
-func (m *UserModel) EmailTaken(email string) (bool, error) {
-	switch email {
-	case "duplicate@mail.com":
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	switch id {
+	case 1, 2:
 		return true, nil
 	default:
 		return false, nil
 	}
 }
 
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Get(ctx context.Context, id int) (models.User, error) {
 	switch id {
 	case 1:
-		return true, nil
+		return m.user(mockUser), nil
+	case 2:
+		return m.user(mockAdmin), nil
 	default:
-		return false, nil
+		return models.User{}, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
+	if id == 1 {
+		if currentPassword != "password" {
+			return models.ErrInvalidCredentials
+		}
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *UserModel) List(ctx context.Context, search string) ([]models.User, error) {
+	return []models.User{m.user(mockAdmin), m.user(mockUser)}, nil
+}
+
+func (m *UserModel) SetSuspended(ctx context.Context, id int, suspended bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id != 1 && id != 2 {
+		return models.ErrNoRecord
+	}
+	if m.suspended == nil {
+		m.suspended = map[int]bool{}
+	}
+	m.suspended[id] = suspended
+	return nil
+}
+
+func (m *UserModel) SetRole(ctx context.Context, id int, role models.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id != 1 && id != 2 {
+		return models.ErrNoRecord
+	}
+	if m.roles == nil {
+		m.roles = map[int]models.Role{}
 	}
+	m.roles[id] = role
+	return nil
 }
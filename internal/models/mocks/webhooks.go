@@ -0,0 +1,106 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// WebhookModel keeps the webhooks in memory. The events enqueued for them are
+// recorded as deliveries, which are never claimed.
+type WebhookModel struct {
+	mu         sync.Mutex
+	lastID     int
+	webhooks   []models.Webhook
+	deliveries []models.Delivery
+}
+
+func (m *WebhookModel) Insert(userID int, url string, secret string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	m.webhooks = append(m.webhooks, models.Webhook{ID: m.lastID, UserID: userID, URL: url, Created: time.Now()})
+	return m.lastID, nil
+}
+
+func (m *WebhookModel) GetByUser(userID int) ([]models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var webhooks []models.Webhook
+	for _, w := range m.webhooks {
+		if w.UserID == userID {
+			webhooks = append(webhooks, w)
+		}
+	}
+	return webhooks, nil
+}
+
+func (m *WebhookModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, w := range m.webhooks {
+		if w.ID == id && w.UserID == userID {
+			m.webhooks = append(m.webhooks[:i], m.webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *WebhookModel) Enqueue(userID int, event string, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, w := range m.webhooks {
+		if w.UserID == userID {
+			m.deliveries = append(m.deliveries, models.Delivery{
+				ID:        len(m.deliveries) + 1,
+				WebhookID: w.ID,
+				URL:       w.URL,
+				Event:     event,
+				Payload:   payload,
+				Status:    models.DeliveryStatusPending,
+				Created:   time.Now(),
+			})
+		}
+	}
+	return nil
+}
+
+func (m *WebhookModel) Claim(lease time.Duration) (models.Delivery, error) {
+	return models.Delivery{}, models.ErrNoRecord
+}
+
+func (m *WebhookModel) Delivered(id int, responseCode int) error {
+	return models.ErrNoRecord
+}
+
+func (m *WebhookModel) Retry(id int, responseCode int, deliveryErr string, retryIn time.Duration) error {
+	return models.ErrNoRecord
+}
+
+func (m *WebhookModel) Fail(id int, responseCode int, deliveryErr string) error {
+	return models.ErrNoRecord
+}
+
+func (m *WebhookModel) Deliveries(userID int, limit int) ([]models.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := map[int]bool{}
+	for _, w := range m.webhooks {
+		owned[w.ID] = w.UserID == userID
+	}
+
+	var deliveries []models.Delivery
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if owned[m.deliveries[i].WebhookID] {
+			deliveries = append(deliveries, m.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,31 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/AlessioPani/go-snippetbox/internal/models/modelstest"
+)
+
+// TestConformance runs the conformance suite of the models on SQLite, which
+// needs nothing but a temporary directory and so always runs.
+func TestConformance(t *testing.T) {
+	modelstest.Run(t, func(t *testing.T) modelstest.Models {
+		db := openTestDB(t)
+
+		return modelstest.Models{
+			Snippets:      &models.SnippetModel{DB: db},
+			Users:         &models.UserModel{DB: db},
+			Stats:         &models.StatsModel{DB: db},
+			Reports:       &models.ReportModel{DB: db},
+			Audit:         &models.AuditModel{DB: db},
+			Moderation:    &models.ModerationModel{DB: db},
+			Tokens:        &models.TokenModel{DB: db},
+			Webhooks:      &models.WebhookModel{DB: db},
+			Credentials:   &models.CredentialModel{DB: db},
+			Sessions:      &models.SessionModel{DB: db, Lifetime: 12 * time.Hour, IdleTimeout: 20 * time.Minute, RememberMeLifetime: 30 * 24 * time.Hour, TouchInterval: time.Minute},
+			LoginAttempts: &models.LoginAttemptModel{DB: db},
+		}
+	})
+}
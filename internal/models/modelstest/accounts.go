@@ -0,0 +1,348 @@
+package modelstest
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func testTokens(t *testing.T, open func(t *testing.T) Models) {
+	t.Run("Insert and Authenticate", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+
+		token, err := m.Tokens.Insert(userID, "CI", []string{models.ScopeSnippetsRead, models.ScopeSnippetsWrite}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, strings.HasPrefix(token, models.TokenPrefix), true)
+
+		tok, err := m.Tokens.Authenticate(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tok.UserID, userID)
+		assert.Equal(t, tok.Name, "CI")
+		assert.Equal(t, strings.Join(tok.Scopes, " "), "snippets:read snippets:write")
+		assert.Equal(t, tok.Expires.Valid, false)
+		assert.Equal(t, tok.LastUsed.Valid, false)
+		around(t, tok.Created, time.Now())
+
+		// The first use is recorded, the next ones within the use interval
+		// leave it as it is.
+		first, err := m.Tokens.Authenticate(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, first.LastUsed.Valid, true)
+		around(t, first.LastUsed.Time, time.Now())
+
+		next, err := m.Tokens.Authenticate(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, next.LastUsed.Time.Equal(first.LastUsed.Time), true)
+
+		for _, invalid := range []string{"", "token", models.TokenPrefix + "0123456789abcdef"} {
+			_, err = m.Tokens.Authenticate(invalid)
+			assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+		}
+	})
+
+	t.Run("GetByUser and Delete", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+		otherID := insertUser(t, m, "Bob", "bob@example.com")
+
+		used, err := m.Tokens.Insert(userID, "Used", []string{models.ScopeSnippetsRead}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Tokens.Authenticate(used)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expiring, err := m.Tokens.Insert(userID, "Expiring", []string{models.ScopeSnippetsRead}, 30)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The tokens are listed newest first, with their use and their expiry.
+		tokens, err := m.Tokens.GetByUser(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(tokens), 2)
+		assert.Equal(t, tokens[0].Name, "Expiring")
+		assert.Equal(t, tokens[0].Expires.Valid, true)
+		around(t, tokens[0].Expires.Time, time.Now().AddDate(0, 0, 30))
+		assert.Equal(t, tokens[0].LastUsed.Valid, false)
+		assert.Equal(t, tokens[1].Name, "Used")
+		assert.Equal(t, tokens[1].LastUsed.Valid, true)
+		around(t, tokens[1].LastUsed.Time, time.Now())
+
+		err = m.Tokens.Delete(tokens[0].ID, otherID)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		err = m.Tokens.Delete(tokens[0].ID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Tokens.Authenticate(expiring)
+		assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+	})
+}
+
+func testCredentials(t *testing.T, open func(t *testing.T) Models) {
+	t.Run("Insert, Get and Delete", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+		otherID := insertUser(t, m, "Bob", "bob@example.com")
+
+		err := m.Credentials.Insert(userID, "Laptop", []byte{1, 2, 3}, []byte{4, 5, 6}, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := m.Credentials.Get([]byte{1, 2, 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, c.UserID, userID)
+		assert.Equal(t, c.Name, "Laptop")
+		assert.Equal(t, string(c.PublicKey), string([]byte{4, 5, 6}))
+		assert.Equal(t, c.SignCount, uint32(7))
+		assert.Equal(t, c.LastUsed.Valid, false)
+		around(t, c.Created, time.Now())
+
+		_, err = m.Credentials.Get([]byte{9})
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		// The whole range of the counter is kept.
+		err = m.Credentials.UpdateSignCount(c.ID, math.MaxUint32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		credentials, err := m.Credentials.GetByUser(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(credentials), 1)
+		assert.Equal(t, credentials[0].SignCount, uint32(math.MaxUint32))
+		assert.Equal(t, credentials[0].LastUsed.Valid, true)
+		around(t, credentials[0].LastUsed.Time, time.Now())
+
+		err = m.Credentials.Delete(c.ID, otherID)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		err = m.Credentials.Delete(c.ID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		credentials, err = m.Credentials.GetByUser(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(credentials), 0)
+	})
+}
+
+func testSessions(t *testing.T, open func(t *testing.T) Models) {
+	t.Run("Insert, Touch and Delete", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+		otherID := insertUser(t, m, "Bob", "bob@example.com")
+
+		first, err := m.Sessions.Insert(userID, "Firefox", "192.0.2.1", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(first), 32)
+		second, err := m.Sessions.Insert(userID, "Chrome", "192.0.2.2", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Sessions.Insert(otherID, "Safari", "192.0.2.3", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count, err := m.Sessions.Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, count, 3)
+
+		// A session seen within the touch interval isn't updated.
+		ok, err := m.Sessions.Touch(first, userID, "192.0.2.4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, ok, true)
+		ok, err = m.Sessions.Touch(first, otherID, "192.0.2.4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, ok, false)
+
+		sessions, err := m.Sessions.GetByUser(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(sessions), 2)
+		for _, s := range sessions {
+			assert.Equal(t, s.UserID, userID)
+			around(t, s.Created, time.Now())
+			around(t, s.LastSeen, time.Now())
+			switch s.ID {
+			case first:
+				assert.Equal(t, s.UserAgent, "Firefox")
+				assert.Equal(t, s.IP, "192.0.2.1")
+				assert.Equal(t, s.RememberMe, false)
+			case second:
+				assert.Equal(t, s.UserAgent, "Chrome")
+				assert.Equal(t, s.RememberMe, true)
+			default:
+				t.Errorf("unexpected session %s", s.ID)
+			}
+		}
+
+		// Revoke the other sessions of the user, then the remaining one.
+		err = m.Sessions.DeleteByUser(userID, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err = m.Sessions.Touch(second, userID, "192.0.2.2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, ok, false)
+
+		err = m.Sessions.Delete(first, otherID)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+		err = m.Sessions.Delete(first, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count, err = m.Sessions.Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, count, 1)
+	})
+}
+
+func testLoginAttempts(t *testing.T, open func(t *testing.T) Models) {
+	t.Run("Failures and Reset", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+
+		// Each attempt is returned the failures made before it.
+		attempts := []struct {
+			email, ip         string
+			wantEmail, wantIP int
+		}{
+			{"alice@example.com", "192.0.2.1", 0, 0},
+			{"alice@example.com", "192.0.2.1", 1, 1},
+			{"bob@example.com", "192.0.2.1", 0, 2},
+		}
+		for _, a := range attempts {
+			_, byEmail, byIP, err := m.LoginAttempts.Insert(a.email, a.ip, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, byEmail.Count, a.wantEmail)
+			assert.Equal(t, byIP.Count, a.wantIP)
+		}
+
+		byEmail, byIP, err := m.LoginAttempts.Failures("alice@example.com", "192.0.2.1", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, byEmail.Count, 2)
+		around(t, byEmail.Last, time.Now())
+		assert.Equal(t, byIP.Count, 3)
+
+		err = m.LoginAttempts.Reset("alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		byEmail, byIP, err = m.LoginAttempts.Failures("alice@example.com", "192.0.2.1", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, byEmail.Count, 0)
+		assert.Equal(t, byEmail.Last.IsZero(), true)
+		assert.Equal(t, byIP.Count, 1)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+
+		first, _, _, err := m.LoginAttempts.Insert("alice@example.com", "192.0.2.1", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, _, _, err := m.LoginAttempts.Insert("alice@example.com", "192.0.2.1", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, first != second, true)
+
+		err = m.LoginAttempts.Delete(second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		byEmail, byIP, err := m.LoginAttempts.Failures("alice@example.com", "192.0.2.1", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, byEmail.Count, 1)
+		assert.Equal(t, byIP.Count, 1)
+	})
+
+	t.Run("Concurrent attempts", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+
+		// Concurrent attempts are serialised, so each one is returned a
+		// different number of earlier failures.
+		const n = 10
+		counts := make(chan int, n)
+		var wg sync.WaitGroup
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, byEmail, _, err := m.LoginAttempts.Insert("alice@example.com", "192.0.2.1", time.Hour)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				counts <- byEmail.Count
+			}()
+		}
+		wg.Wait()
+		close(counts)
+
+		seen := make(map[int]bool)
+		for count := range counts {
+			seen[count] = true
+		}
+		assert.Equal(t, len(seen), n)
+	})
+}
@@ -0,0 +1,584 @@
+// Package modelstest provides the conformance test suite of the models, which
+// every storage backend must pass so that the application behaves the same
+// whatever the database it runs on.
+package modelstest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// Models are the models of a storage backend, on the same database. The
+// lifetimes of the sessions must be at least an hour, and their touch interval
+// a minute.
+type Models struct {
+	Snippets      models.SnippetModelInterface
+	Users         models.UserModelInterface
+	Stats         models.StatsModelInterface
+	Reports       models.ReportModelInterface
+	Audit         models.AuditModelInterface
+	Moderation    models.ModerationModelInterface
+	Tokens        models.TokenModelInterface
+	Webhooks      models.WebhookModelInterface
+	Credentials   models.CredentialModelInterface
+	Sessions      models.SessionModelInterface
+	LoginAttempts models.LoginAttemptModelInterface
+}
+
+// Run runs the conformance suite of the models. open is called by each test
+// to get the models on a new database, which has the schema and no data; the
+// tests run in parallel, so it must be safe for concurrent use.
+func Run(t *testing.T, open func(t *testing.T) Models) {
+	t.Run("Snippets", func(t *testing.T) {
+		testSnippets(t, open)
+	})
+	t.Run("Users", func(t *testing.T) {
+		testUsers(t, open)
+	})
+	t.Run("Stats", func(t *testing.T) {
+		testStats(t, open)
+	})
+	t.Run("Moderation", func(t *testing.T) {
+		testModeration(t, open)
+	})
+	t.Run("Tokens", func(t *testing.T) {
+		testTokens(t, open)
+	})
+	t.Run("Credentials", func(t *testing.T) {
+		testCredentials(t, open)
+	})
+	t.Run("Sessions", func(t *testing.T) {
+		testSessions(t, open)
+	})
+	t.Run("Login attempts", func(t *testing.T) {
+		testLoginAttempts(t, open)
+	})
+	t.Run("Webhooks", func(t *testing.T) {
+		testWebhooks(t, open)
+	})
+}
+
+// insertUser inserts a user with the password "password" and returns its ID.
+func insertUser(t *testing.T, m Models, name, email string) int {
+	t.Helper()
+	ctx := context.Background()
+
+	err := m.Users.Insert(ctx, name, email, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := m.Users.List(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("got %d users with email %s, want 1", len(users), email)
+	}
+
+	return users[0].ID
+}
+
+// insertSnippet inserts a snippet and returns its ID.
+func insertSnippet(t *testing.T, m Models, userID int, title, content, language string, expires int) int {
+	t.Helper()
+	ctx := context.Background()
+
+	id, err := m.Snippets.Insert(ctx, userID, title, content, language, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+// ids returns the IDs of the snippets, in order.
+func ids(snippets []models.Snippet) []int {
+	var ids []int
+	for _, s := range snippets {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// equalIDs asserts that the IDs of the snippets are the expected ones, in
+// order.
+func equalIDs(t *testing.T, snippets []models.Snippet, want ...int) {
+	t.Helper()
+
+	got := ids(snippets)
+	if len(got) != len(want) {
+		t.Errorf("got IDs %v, want %v", got, want)
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("got IDs %v, want %v", got, want)
+			return
+		}
+	}
+}
+
+// around asserts that a time is in UTC and within a minute of the expected
+// one, as the databases don't all keep the same precision.
+func around(t *testing.T, got, want time.Time) {
+	t.Helper()
+
+	if got.Location() != time.UTC {
+		t.Errorf("got time in %s, want UTC", got.Location())
+	}
+	if d := got.Sub(want); d < -time.Minute || d > time.Minute {
+		t.Errorf("got %v, want about %v", got, want)
+	}
+}
+
+func testSnippets(t *testing.T, open func(t *testing.T) Models) {
+	t.Run("Insert and Get", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+
+		id := insertSnippet(t, m, userID, "Title", "Content", "Go", 7)
+
+		s, err := m.Snippets.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s.ID, id)
+		assert.Equal(t, s.UserID, userID)
+		assert.Equal(t, s.Title, "Title")
+		assert.Equal(t, s.Content, "Content")
+		assert.Equal(t, s.Language, "Go")
+		assert.Equal(t, s.Hidden, false)
+		around(t, s.Created, time.Now())
+		around(t, s.Expires, time.Now().AddDate(0, 0, 7))
+
+		_, err = m.Snippets.Get(ctx, id+1)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+		_, err = m.Snippets.GetAny(ctx, id+1)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		_, err = m.Snippets.Insert(ctx, userID+1, "Title", "Content", "", 7)
+		assert.Equal(t, errors.Is(err, models.ErrForeignKeyViolation), true)
+	})
+
+	t.Run("Insert without a user", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+
+		// A user ID of 0 stands for no user, like the one of a snippet
+		// whose user has been deleted.
+		id := insertSnippet(t, m, 0, "Title", "Content", "", 7)
+
+		s, err := m.Snippets.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s.UserID, 0)
+	})
+
+	t.Run("Expired and hidden", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+
+		expired := insertSnippet(t, m, userID, "Expired", "Content", "", 0)
+		hidden := insertSnippet(t, m, userID, "Hidden", "Content", "", 7)
+
+		err := m.Snippets.SetHidden(ctx, hidden, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The snippets can't be seen, but are still there.
+		for _, id := range []int{expired, hidden} {
+			_, err = m.Snippets.Get(ctx, id)
+			assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+			_, err := m.Snippets.GetAny(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		s, err := m.Snippets.GetAny(ctx, hidden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s.Hidden, true)
+
+		err = m.Snippets.SetHidden(ctx, hidden, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Snippets.Get(ctx, hidden)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = m.Snippets.SetHidden(ctx, hidden+1, true)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	})
+
+	t.Run("Latest", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+
+		var live []int
+		for range 12 {
+			live = append(live, insertSnippet(t, m, userID, "Live", "Content", "", 7))
+		}
+		insertSnippet(t, m, userID, "Expired", "Content", "", 0)
+		hidden := insertSnippet(t, m, userID, "Hidden", "Content", "", 7)
+		err := m.Snippets.SetHidden(ctx, hidden, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The 10 newest live snippets, newest first.
+		snippets, err := m.Snippets.Latest(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		equalIDs(t, snippets, live[11], live[10], live[9], live[8], live[7], live[6], live[5], live[4], live[3], live[2])
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		alice := insertUser(t, m, "Alice", "alice@example.com")
+		bob := insertUser(t, m, "Bob", "bob@example.com")
+
+		hello := insertSnippet(t, m, alice, "Hello World", "fmt.Println()", "Go", 7)
+		percent := insertSnippet(t, m, bob, "Progress", "100% done", "Python", 7)
+		underscore := insertSnippet(t, m, bob, "Names", "snake_case", "python", 7)
+		expired := insertSnippet(t, m, alice, "Old", "Content", "Go", 0)
+
+		tests := []struct {
+			name   string
+			filter models.SnippetFilter
+			want   []int
+		}{
+			{name: "No filter", filter: models.SnippetFilter{}, want: []int{expired, underscore, percent, hello}},
+			{name: "Search without regard to case", filter: models.SnippetFilter{Search: "hello world"}, want: []int{hello}},
+			{name: "Search in the content", filter: models.SnippetFilter{Search: "println"}, want: []int{hello}},
+			{name: "Search a percent sign", filter: models.SnippetFilter{Search: "%"}, want: []int{percent}},
+			{name: "Search an underscore", filter: models.SnippetFilter{Search: "_"}, want: []int{underscore}},
+			{name: "Search without match", filter: models.SnippetFilter{Search: "missing"}},
+			{name: "Author", filter: models.SnippetFilter{UserID: alice}, want: []int{expired, hello}},
+			{name: "Language without regard to case", filter: models.SnippetFilter{Language: "PYTHON"}, want: []int{underscore, percent}},
+			{name: "Live", filter: models.SnippetFilter{Status: models.SnippetStatusLive}, want: []int{underscore, percent, hello}},
+			{name: "Expired", filter: models.SnippetFilter{Status: models.SnippetStatusExpired}, want: []int{expired}},
+			{name: "Combined", filter: models.SnippetFilter{UserID: bob, Search: "case", Language: "python"}, want: []int{underscore}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				snippets, err := m.Snippets.List(ctx, tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				equalIDs(t, snippets, tt.want...)
+			})
+		}
+
+		t.Run("LatestBy", func(t *testing.T) {
+			// The expired snippets are left out whatever the status of the
+			// filter.
+			snippets, err := m.Snippets.LatestBy(ctx, models.SnippetFilter{UserID: alice, Status: models.SnippetStatusExpired})
+			if err != nil {
+				t.Fatal(err)
+			}
+			equalIDs(t, snippets, hello)
+
+			snippets, err = m.Snippets.LatestBy(ctx, models.SnippetFilter{Language: "python"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			equalIDs(t, snippets, underscore, percent)
+		})
+	})
+
+	t.Run("Page", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+
+		var live []int
+		for range 5 {
+			live = append(live, insertSnippet(t, m, userID, "Live", "Content", "", 7))
+		}
+		insertSnippet(t, m, userID, "Expired", "Content", "", 0)
+
+		snippets, total, err := m.Snippets.Page(ctx, 1, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, total, 5)
+		equalIDs(t, snippets, live[4], live[3])
+
+		snippets, total, err = m.Snippets.Page(ctx, 3, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, total, 5)
+		equalIDs(t, snippets, live[0])
+
+		snippets, _, err = m.Snippets.Page(ctx, 4, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		equalIDs(t, snippets)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+		id := insertSnippet(t, m, userID, "Title", "Content", "Go", 1)
+
+		err := m.Snippets.Update(ctx, id, "New title", "New content", "Rust", 30)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := m.Snippets.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s.Title, "New title")
+		assert.Equal(t, s.Content, "New content")
+		assert.Equal(t, s.Language, "Rust")
+		assert.Equal(t, s.UserID, userID)
+		around(t, s.Expires, time.Now().AddDate(0, 0, 30))
+
+		// A snippet updated to expire now can't be seen anymore.
+		err = m.Snippets.Update(ctx, id, "Title", "Content", "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Snippets.Get(ctx, id)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		err = m.Snippets.Update(ctx, id+1, "Title", "Content", "", 7)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	})
+
+	t.Run("Cancelled context", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+		id := insertSnippet(t, m, userID, "Title", "Content", "", 7)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := m.Snippets.Get(ctx, id)
+		assert.Equal(t, errors.Is(err, context.Canceled), true)
+		_, err = m.Snippets.Latest(ctx)
+		assert.Equal(t, errors.Is(err, context.Canceled), true)
+		_, err = m.Users.Get(ctx, userID)
+		assert.Equal(t, errors.Is(err, context.Canceled), true)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+		id := insertSnippet(t, m, userID, "Title", "Content", "", 7)
+
+		err := m.Snippets.Delete(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Snippets.GetAny(ctx, id)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		err = m.Snippets.Delete(ctx, id)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	})
+}
+
+func testUsers(t *testing.T, open func(t *testing.T) Models) {
+	t.Run("Insert and Get", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		id := insertUser(t, m, "Alice", "alice@example.com")
+
+		u, err := m.Users.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, u.ID, id)
+		assert.Equal(t, u.Name, "Alice")
+		assert.Equal(t, u.Email, "alice@example.com")
+		assert.Equal(t, u.Role, models.RoleUser)
+		assert.Equal(t, u.Suspended, false)
+		around(t, u.Created, time.Now())
+
+		_, err = m.Users.Get(ctx, id+1)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		exists, err := m.Users.Exists(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, exists, true)
+
+		exists, err = m.Users.Exists(ctx, id+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, exists, false)
+	})
+
+	t.Run("Duplicate email", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		insertUser(t, m, "Alice", "alice@example.com")
+
+		err := m.Users.Insert(ctx, "Other Alice", "alice@example.com", "password")
+		assert.Equal(t, errors.Is(err, models.ErrDuplicateEmail), true)
+
+		users, err := m.Users.List(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(users), 1)
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		id := insertUser(t, m, "Alice", "alice@example.com")
+
+		tests := []struct {
+			name     string
+			email    string
+			password string
+			wantID   int
+			wantErr  error
+		}{
+			{name: "Valid", email: "alice@example.com", password: "password", wantID: id},
+			{name: "Wrong password", email: "alice@example.com", password: "wrong", wantErr: models.ErrInvalidCredentials},
+			{name: "Unknown email", email: "bob@example.com", password: "password", wantErr: models.ErrInvalidCredentials},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				id, err := m.Users.Authenticate(ctx, tt.email, tt.password)
+				assert.Equal(t, id, tt.wantID)
+				assert.Equal(t, errors.Is(err, tt.wantErr), true)
+			})
+		}
+	})
+
+	t.Run("PasswordUpdate", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		id := insertUser(t, m, "Alice", "alice@example.com")
+
+		err := m.Users.PasswordUpdate(ctx, id, "wrong", "new password")
+		assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+		err = m.Users.PasswordUpdate(ctx, id, "password", "new password")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = m.Users.Authenticate(ctx, "alice@example.com", "password")
+		assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+		authenticated, err := m.Users.Authenticate(ctx, "alice@example.com", "new password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, authenticated, id)
+
+		err = m.Users.PasswordUpdate(ctx, id+1, "password", "new password")
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		alice := insertUser(t, m, "Alice", "alice@example.com")
+		bob := insertUser(t, m, "Bob", "bob_smith@example.com")
+
+		tests := []struct {
+			name   string
+			search string
+			want   []int
+		}{
+			{name: "Everyone", search: "", want: []int{bob, alice}},
+			{name: "Name without regard to case", search: "ALICE", want: []int{alice}},
+			{name: "Email", search: "smith", want: []int{bob}},
+			{name: "Underscore", search: "_", want: []int{bob}},
+			{name: "No match", search: "carol"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				users, err := m.Users.List(ctx, tt.search)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var got []int
+				for _, u := range users {
+					got = append(got, u.ID)
+				}
+				assert.Equal(t, len(got), len(tt.want))
+				for i := range min(len(got), len(tt.want)) {
+					assert.Equal(t, got[i], tt.want[i])
+				}
+			})
+		}
+	})
+
+	t.Run("SetSuspended and SetRole", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		id := insertUser(t, m, "Alice", "alice@example.com")
+
+		err := m.Users.SetSuspended(ctx, id, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.Users.SetRole(ctx, id, models.RoleModerator)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := m.Users.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, u.Suspended, true)
+		assert.Equal(t, u.Role, models.RoleModerator)
+
+		err = m.Users.SetSuspended(ctx, id+1, true)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+		err = m.Users.SetRole(ctx, id+1, models.RoleAdmin)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	})
+}
@@ -0,0 +1,176 @@
+package modelstest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func testStats(t *testing.T, open func(t *testing.T) Models) {
+	t.Run("Get", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+		insertUser(t, m, "Bob", "bob@example.com")
+		insertSnippet(t, m, userID, "Live", "Content", "", 7)
+		insertSnippet(t, m, userID, "Expired", "Content", "", 0)
+
+		s, err := m.Stats.Get(7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s.Users, 2)
+		assert.Equal(t, s.LiveSnippets, 1)
+		assert.Equal(t, s.ExpiredSnippets, 1)
+
+		// The signups are counted by day, in UTC.
+		assert.Equal(t, len(s.Signups), 1)
+		assert.Equal(t, s.Signups[0].Day, time.Now().UTC().Format("2006-01-02"))
+		assert.Equal(t, s.Signups[0].Count, 2)
+	})
+}
+
+func testModeration(t *testing.T, open func(t *testing.T) Models) {
+	t.Run("Reports", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		authorID := insertUser(t, m, "Alice", "alice@example.com")
+		bobID := insertUser(t, m, "Bob", "bob@example.com")
+		carolID := insertUser(t, m, "Carol", "carol@example.com")
+		id := insertSnippet(t, m, authorID, "Title", "Content", "", 7)
+
+		for _, r := range []struct {
+			reporterID int
+			reason     string
+		}{{bobID, "Spam"}, {carolID, "Off topic"}} {
+			err := m.Reports.Insert(id, r.reporterID, r.reason)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := m.Reports.Insert(id, bobID, "Spam again")
+		assert.Equal(t, errors.Is(err, models.ErrDuplicateReport), true)
+
+		reported, err := m.Reports.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(reported), 1)
+		assert.Equal(t, reported[0].Snippet.ID, id)
+		assert.Equal(t, reported[0].Snippet.UserID, authorID)
+		assert.Equal(t, reported[0].Snippet.Title, "Title")
+		assert.Equal(t, reported[0].Reports, 2)
+		reasons := slices.Sorted(slices.Values(reported[0].Reasons))
+		assert.Equal(t, strings.Join(reasons, ", "), "Off topic, Spam")
+		around(t, reported[0].FirstReported, time.Now())
+
+		err = m.Reports.Dismiss(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reported, err = m.Reports.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(reported), 0)
+
+		err = m.Reports.Dismiss(id)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	})
+
+	t.Run("Actions", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		ctx := context.Background()
+		adminID := insertUser(t, m, "Alice", "alice@example.com")
+		authorID := insertUser(t, m, "Bob", "bob@example.com")
+		id := insertSnippet(t, m, authorID, "Title", "Content", "", 7)
+
+		err := m.Moderation.HideSnippet(ctx, models.AuditEntry{ActorID: adminID, Action: models.AuditHideSnippet, SnippetID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := m.Snippets.GetAny(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s.Hidden, true)
+
+		// The failed actions aren't recorded.
+		err = m.Moderation.HideSnippet(ctx, models.AuditEntry{ActorID: adminID, Action: models.AuditHideSnippet, SnippetID: id + 1})
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+		err = m.Moderation.DismissReports(ctx, models.AuditEntry{ActorID: adminID, Action: models.AuditDismissReports, SnippetID: id})
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		err = m.Reports.Insert(id, adminID, "Spam")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.Moderation.DismissReports(ctx, models.AuditEntry{ActorID: adminID, Action: models.AuditDismissReports, SnippetID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Snippets.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = m.Moderation.SetSuspended(ctx, models.AuditEntry{ActorID: adminID, Action: models.AuditSuspendUser, UserID: authorID}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.Moderation.SetRole(ctx, models.AuditEntry{ActorID: adminID, Action: models.AuditChangeRole, UserID: authorID, Details: "moderator"}, models.RoleModerator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.Moderation.SetRole(ctx, models.AuditEntry{ActorID: adminID, Action: models.AuditChangeRole, UserID: authorID + 99}, models.RoleModerator)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+		u, err := m.Users.Get(ctx, authorID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, u.Suspended, true)
+		assert.Equal(t, u.Role, models.RoleModerator)
+
+		// The deleted snippet is returned, and recorded with its author and
+		// its title.
+		s, err = m.Moderation.DeleteSnippet(ctx, models.AuditEntry{ActorID: adminID, Action: models.AuditDeleteSnippet, SnippetID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s.Title, "Title")
+		_, err = m.Snippets.GetAny(ctx, id)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+		_, err = m.Moderation.DeleteSnippet(ctx, models.AuditEntry{ActorID: adminID, Action: models.AuditDeleteSnippet, SnippetID: id})
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		entries, err := m.Audit.Latest(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actions []string
+		for _, e := range entries {
+			assert.Equal(t, e.ActorID, adminID)
+			around(t, e.Created, time.Now())
+			actions = append(actions, e.Action)
+		}
+		assert.Equal(t, strings.Join(actions, " "), "delete_snippet change_role suspend_user dismiss_reports hide_snippet")
+		assert.Equal(t, entries[0].SnippetID, id)
+		assert.Equal(t, entries[0].UserID, authorID)
+		assert.Equal(t, entries[0].Details, "Title")
+		assert.Equal(t, entries[1].Details, "moderator")
+
+		entries, err = m.Audit.Latest(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(entries), 1)
+	})
+}
@@ -0,0 +1,120 @@
+package modelstest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func testWebhooks(t *testing.T, open func(t *testing.T) Models) {
+	t.Run("Insert, GetByUser and Delete", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+		otherID := insertUser(t, m, "Bob", "bob@example.com")
+
+		id, err := m.Webhooks.Insert(userID, "https://example.com/hook", "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		webhooks, err := m.Webhooks.GetByUser(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(webhooks), 1)
+		assert.Equal(t, webhooks[0].ID, id)
+		assert.Equal(t, webhooks[0].URL, "https://example.com/hook")
+		around(t, webhooks[0].Created, time.Now())
+
+		err = m.Webhooks.Delete(id, otherID)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		err = m.Webhooks.Delete(id, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		webhooks, err = m.Webhooks.GetByUser(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(webhooks), 0)
+	})
+
+	t.Run("Deliveries", func(t *testing.T) {
+		t.Parallel()
+		m := open(t)
+		userID := insertUser(t, m, "Alice", "alice@example.com")
+
+		id, err := m.Webhooks.Insert(userID, "https://example.com/hook", "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.Webhooks.Enqueue(userID, models.EventSnippetCreated, []byte(`{"id":1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := m.Webhooks.Claim(time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, d.WebhookID, id)
+		assert.Equal(t, d.URL, "https://example.com/hook")
+		assert.Equal(t, d.Secret, "secret")
+		assert.Equal(t, d.Event, models.EventSnippetCreated)
+		assert.Equal(t, string(d.Payload), `{"id":1}`)
+		assert.Equal(t, d.Status, models.DeliveryStatusPending)
+		assert.Equal(t, d.Attempts, 1)
+
+		// The claimed delivery is leased.
+		_, err = m.Webhooks.Claim(time.Minute)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		// A delivery retried right away is due again.
+		err = m.Webhooks.Retry(d.ID, 500, "server error", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err = m.Webhooks.Claim(time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, d.Attempts, 2)
+
+		err = m.Webhooks.Delivered(d.ID, 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.Webhooks.Fail(d.ID+1, 0, "error")
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		deliveries, err := m.Webhooks.Deliveries(userID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(deliveries), 1)
+		assert.Equal(t, deliveries[0].URL, "https://example.com/hook")
+		assert.Equal(t, deliveries[0].Status, models.DeliveryStatusDelivered)
+		assert.Equal(t, deliveries[0].Attempts, 2)
+		assert.Equal(t, deliveries[0].ResponseCode, 200)
+		assert.Equal(t, deliveries[0].Error, "")
+		around(t, deliveries[0].Created, time.Now())
+		assert.Equal(t, deliveries[0].Delivered.Valid, true)
+		around(t, deliveries[0].Delivered.Time, time.Now())
+
+		// The deliveries are deleted with their webhook.
+		err = m.Webhooks.Delete(id, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deliveries, err = m.Webhooks.Deliveries(userID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(deliveries), 0)
+	})
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ModerationModelInterface interface.
+type ModerationModelInterface interface {
+	DismissReports(ctx context.Context, entry AuditEntry) error
+	HideSnippet(ctx context.Context, entry AuditEntry) error
+	DeleteSnippet(ctx context.Context, entry AuditEntry) (Snippet, error)
+	SetSuspended(ctx context.Context, entry AuditEntry, suspended bool) error
+	SetRole(ctx context.Context, entry AuditEntry, role Role) error
+}
+
+// ModerationModel is a struct used to call DB operations. Each of its methods
+// takes a moderation action and records it in the audit trail as entry, in a
+// single transaction: the action is never taken without being recorded, nor
+// recorded without being taken.
+type ModerationModel struct {
+	DB *sql.DB
+}
+
+// DismissReports closes the open reports of the snippet of the entry, and
+// makes it visible again if it was hidden. It returns ErrNoRecord if the
+// snippet has no open report.
+func (m *ModerationModel) DismissReports(ctx context.Context, entry AuditEntry) error {
+	return m.inTx(ctx, &entry, func(tx *sql.Tx) error {
+		err := execOne(ctx, tx, "UPDATE reports SET status = ? WHERE snippet_id = ? AND status = ?",
+			ReportStatusDismissed, entry.SnippetID, ReportStatusOpen)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE snippets SET hidden = FALSE WHERE id = ?", entry.SnippetID)
+		return err
+	})
+}
+
+// HideSnippet hides the snippet of the entry until its reports are dismissed.
+// It returns ErrNoRecord if no such snippet exists.
+func (m *ModerationModel) HideSnippet(ctx context.Context, entry AuditEntry) error {
+	return m.inTx(ctx, &entry, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, "UPDATE snippets SET hidden = TRUE WHERE id = ?", entry.SnippetID)
+	})
+}
+
+// DeleteSnippet deletes the snippet of the entry, even if it is hidden or
+// expired, together with its reports. The entry is recorded with the author
+// and the title of the snippet. It returns the deleted snippet, or
+// ErrNoRecord if no such snippet exists.
+func (m *ModerationModel) DeleteSnippet(ctx context.Context, entry AuditEntry) (Snippet, error) {
+	var s Snippet
+
+	err := m.inTx(ctx, &entry, func(tx *sql.Tx) error {
+		query := "SELECT " + snippetColumns + " FROM snippets WHERE id = ?"
+
+		err := tx.QueryRowContext(ctx, query, entry.SnippetID).Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Hidden, &s.Created, &s.Updated, &s.Expires)
+		if err != nil {
+			return sqliteError(err)
+		}
+
+		entry.UserID = s.UserID
+		entry.Details = s.Title
+
+		return execOne(ctx, tx, "DELETE FROM snippets WHERE id = ?", s.ID)
+	})
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return s, nil
+}
+
+// SetSuspended suspends or reinstates the user of the entry. It returns
+// ErrNoRecord if no such user exists.
+func (m *ModerationModel) SetSuspended(ctx context.Context, entry AuditEntry, suspended bool) error {
+	return m.inTx(ctx, &entry, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, "UPDATE users SET suspended = ? WHERE id = ?", suspended, entry.UserID)
+	})
+}
+
+// SetRole changes the role of the user of the entry. It returns ErrNoRecord
+// if no such user exists.
+func (m *ModerationModel) SetRole(ctx context.Context, entry AuditEntry, role Role) error {
+	return m.inTx(ctx, &entry, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, "UPDATE users SET role = ? WHERE id = ?", string(role), entry.UserID)
+	})
+}
+
+// inTx runs the action in a transaction, then records entry in the audit
+// trail and commits. The action can complete the entry before it is recorded.
+// If the action fails, the transaction is rolled back.
+func (m *ModerationModel) inTx(ctx context.Context, entry *AuditEntry, action func(tx *sql.Tx) error) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	err = action(tx)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO audit_log (actor_id, action, snippet_id, user_id, details, created)
+			  VALUES(?, ?, ?, ?, ?, datetime())`
+
+	_, err = tx.ExecContext(ctx, query, entry.ActorID, entry.Action, entry.SnippetID, entry.UserID, entry.Details)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// execOne runs a query in a transaction which must change a single row,
+// returning ErrNoRecord if none has been changed.
+func execOne(ctx context.Context, tx *sql.Tx, query string, args ...any) error {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
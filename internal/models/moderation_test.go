@@ -0,0 +1,128 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func TestModerationModel(t *testing.T) {
+	db := newTestDB(t)
+	m := models.ModerationModel{DB: db}
+	audit := models.AuditModel{DB: db}
+	reports := models.ReportModel{DB: db}
+	ctx := context.Background()
+
+	// actions returns the actions of the audit trail, the latest first.
+	actions := func(t *testing.T) []string {
+		entries, err := audit.Latest(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var actions []string
+		for _, e := range entries {
+			assert.Equal(t, e.ActorID, 2)
+			actions = append(actions, e.Action)
+		}
+		return actions
+	}
+
+	t.Run("Hide", func(t *testing.T) {
+		err := m.HideSnippet(ctx, models.AuditEntry{ActorID: 2, Action: models.AuditHideSnippet, SnippetID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var hidden bool
+		err = db.QueryRow("SELECT hidden FROM snippets WHERE id = 1").Scan(&hidden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, hidden, true)
+		assert.Equal(t, len(actions(t)), 1)
+	})
+
+	t.Run("Failed action", func(t *testing.T) {
+		// The failed actions aren't recorded.
+		err := m.HideSnippet(ctx, models.AuditEntry{ActorID: 2, Action: models.AuditHideSnippet, SnippetID: 99})
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		err = m.DismissReports(ctx, models.AuditEntry{ActorID: 2, Action: models.AuditDismissReports, SnippetID: 1})
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		err = m.SetSuspended(ctx, models.AuditEntry{ActorID: 2, Action: models.AuditSuspendUser, UserID: 99}, true)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		assert.Equal(t, len(actions(t)), 1)
+	})
+
+	t.Run("Dismiss", func(t *testing.T) {
+		err := reports.Insert(1, 3, "Spam")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = m.DismissReports(ctx, models.AuditEntry{ActorID: 2, Action: models.AuditDismissReports, SnippetID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var hidden bool
+		err = db.QueryRow("SELECT hidden FROM snippets WHERE id = 1").Scan(&hidden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, hidden, false)
+
+		reported, err := reports.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(reported), 0)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s, err := m.DeleteSnippet(ctx, models.AuditEntry{ActorID: 2, Action: models.AuditDeleteSnippet, SnippetID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s.Title, "First snippet")
+
+		_, err = m.DeleteSnippet(ctx, models.AuditEntry{ActorID: 2, Action: models.AuditDeleteSnippet, SnippetID: 1})
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		// The entry is recorded with the author and the title.
+		entries, err := audit.Latest(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, entries[0].UserID, 1)
+		assert.Equal(t, entries[0].Details, "First snippet")
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		err := m.SetSuspended(ctx, models.AuditEntry{ActorID: 2, Action: models.AuditSuspendUser, UserID: 1}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.SetRole(ctx, models.AuditEntry{ActorID: 2, Action: models.AuditChangeRole, UserID: 1, Details: "moderator"}, models.RoleModerator)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		users := models.UserModel{DB: db}
+		user, err := users.Get(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, user.Suspended, true)
+		assert.Equal(t, user.Role, models.RoleModerator)
+
+		assert.Equal(t, strings.Join(actions(t), " "), "change_role suspend_user delete_snippet dismiss_reports hide_snippet")
+	})
+}
@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// AuditModel is a struct used to call DB operations. The entries are
+// recorded by ModerationModel, together with the actions they describe.
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// Latest is a method used to get the latest entries of the audit trail.
+func (m *AuditModel) Latest(limit int) ([]models.AuditEntry, error) {
+	query := `SELECT id, actor_id, action, snippet_id, user_id, details, created FROM audit_log
+			  ORDER BY id DESC LIMIT $1`
+
+	results, err := m.DB.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var entries []models.AuditEntry
+
+	for results.Next() {
+		var e models.AuditEntry
+		err := results.Scan(&e.ID, &e.ActorID, &e.Action, &e.SnippetID, &e.UserID, &e.Details, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+		e.Created = e.Created.UTC()
+		entries = append(entries, e)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// CredentialModel is a struct used to call DB operations.
+type CredentialModel struct {
+	DB *sql.DB
+}
+
+// credentialColumns are the columns selected for a Credential, in the order
+// they are scanned.
+const credentialColumns = "id, user_id, name, credential_id, public_key, sign_count, created, last_used"
+
+// Insert adds a new credential to the credentials table.
+func (m *CredentialModel) Insert(userID int, name string, credentialID, publicKey []byte, signCount uint32) error {
+	query := `INSERT INTO credentials (user_id, name, credential_id, public_key, sign_count, created)
+			  VALUES($1, $2, $3, $4, $5, now())`
+
+	_, err := m.DB.Exec(query, userID, name, credentialID, publicKey, int64(signCount))
+	return err
+}
+
+// Get is a method used to get a credential by the ID assigned by the authenticator.
+func (m *CredentialModel) Get(credentialID []byte) (models.Credential, error) {
+	query := "SELECT " + credentialColumns + " FROM credentials WHERE credential_id = $1"
+
+	c, err := scanCredential(m.DB.QueryRow(query, credentialID))
+	if err != nil {
+		return models.Credential{}, pgError(err)
+	}
+
+	return c, nil
+}
+
+// GetByUser is a method used to get all the credentials registered by a user.
+func (m *CredentialModel) GetByUser(userID int) ([]models.Credential, error) {
+	query := "SELECT " + credentialColumns + " FROM credentials WHERE user_id = $1 ORDER BY id"
+
+	results, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var credentials []models.Credential
+
+	for results.Next() {
+		c, err := scanCredential(results)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, c)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// UpdateSignCount stores the signature counter received with the latest
+// successful assertion and marks the credential as used.
+func (m *CredentialModel) UpdateSignCount(id int, signCount uint32) error {
+	query := "UPDATE credentials SET sign_count = $1, last_used = now() WHERE id = $2"
+
+	_, err := m.DB.Exec(query, int64(signCount), id)
+	return err
+}
+
+// Delete removes a credential owned by a user. It returns ErrNoRecord if no
+// such credential exists.
+func (m *CredentialModel) Delete(id, userID int) error {
+	return update(context.Background(), m.DB, "DELETE FROM credentials WHERE id = $1 AND user_id = $2", id, userID)
+}
+
+// scanCredential scans the credentialColumns of a row.
+func scanCredential(row scanner) (models.Credential, error) {
+	var c models.Credential
+
+	err := row.Scan(&c.ID, &c.UserID, &c.Name, &c.CredentialID, &c.PublicKey, &c.SignCount, &c.Created, &c.LastUsed)
+	if err != nil {
+		return models.Credential{}, err
+	}
+	c.Created = c.Created.UTC()
+	c.LastUsed.Time = c.LastUsed.Time.UTC()
+
+	return c, nil
+}
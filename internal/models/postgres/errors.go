@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLSTATE codes of the constraint violations translated into errors of the
+// models.
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// pgError translates an error of the pgx driver into the error of the models
+// it stands for, as the SQLite models do: models.ErrNoRecord when no row has
+// been found, models.ErrDuplicateEmail when the unique email of the users is
+// violated and models.ErrForeignKeyViolation when a foreign key is. The other
+// errors are returned as they are.
+func pgError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ErrNoRecord
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch {
+	case pgErr.Code == uniqueViolation && pgErr.ConstraintName == "uc_email":
+		return models.ErrDuplicateEmail
+	case pgErr.Code == foreignKeyViolation:
+		return models.ErrForeignKeyViolation
+	default:
+		return err
+	}
+}
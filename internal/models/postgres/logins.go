@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// LoginAttemptModel is a struct used to call DB operations.
+type LoginAttemptModel struct {
+	DB *sql.DB
+}
+
+// Insert records a login attempt for an email address from an IP address,
+// before the password is checked, and returns its ID along with the failed
+// attempts made before it within the time window, both for the email address
+// and for the IP address. The table is locked against other writers for the
+// length of the transaction, so concurrent attempts are serialised and each
+// one counts those made before it.
+// The attempts older than the time window of the throttling, which are never
+// counted again, are deleted along the way so that the table doesn't grow
+// without bound.
+func (m *LoginAttemptModel) Insert(email, ip string, window time.Duration) (int, models.LoginFailures, models.LoginFailures, error) {
+	tx, err := m.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, models.LoginFailures{}, models.LoginFailures{}, err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	// SHARE ROW EXCLUSIVE conflicts with itself but not with plain reads.
+	_, err = tx.Exec("LOCK TABLE login_attempts IN SHARE ROW EXCLUSIVE MODE")
+	if err != nil {
+		return 0, models.LoginFailures{}, models.LoginFailures{}, err
+	}
+
+	query := "DELETE FROM login_attempts WHERE created <= now() - make_interval(secs => $1)"
+
+	_, err = tx.Exec(query, window.Seconds())
+	if err != nil {
+		return 0, models.LoginFailures{}, models.LoginFailures{}, err
+	}
+
+	query = "INSERT INTO login_attempts (email, ip, created) VALUES($1, $2, now()) RETURNING id"
+
+	var id int
+	err = tx.QueryRow(query, email, ip).Scan(&id)
+	if err != nil {
+		return 0, models.LoginFailures{}, models.LoginFailures{}, err
+	}
+
+	byEmail, byIP, err := failures(tx, email, ip, window, id)
+	if err != nil {
+		return 0, models.LoginFailures{}, models.LoginFailures{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, models.LoginFailures{}, models.LoginFailures{}, err
+	}
+
+	return id, byEmail, byIP, nil
+}
+
+// Failures returns the failed login attempts made within a time window, both
+// for an email address and for an IP address.
+func (m *LoginAttemptModel) Failures(email, ip string, window time.Duration) (models.LoginFailures, models.LoginFailures, error) {
+	return failures(m.DB, email, ip, window, 0)
+}
+
+// queryRower is implemented by *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// failures returns the failed login attempts made within a time window for
+// an email address and for an IP address, leaving out the attempt with the
+// ID excluded.
+func failures(db queryRower, email, ip string, window time.Duration, excluded int) (models.LoginFailures, models.LoginFailures, error) {
+	byEmail, err := countFailures(db, "email", email, window, excluded)
+	if err != nil {
+		return models.LoginFailures{}, models.LoginFailures{}, err
+	}
+
+	byIP, err := countFailures(db, "ip", ip, window, excluded)
+	if err != nil {
+		return models.LoginFailures{}, models.LoginFailures{}, err
+	}
+
+	return byEmail, byIP, nil
+}
+
+// countFailures counts the failed login attempts matching a value of a column.
+// The column name is never taken from user input.
+func countFailures(db queryRower, column, value string, window time.Duration, excluded int) (models.LoginFailures, error) {
+	query := `SELECT count(*), max(created) FROM login_attempts
+			  WHERE ` + column + ` = $1 AND created > now() - make_interval(secs => $2) AND id <> $3`
+
+	var f models.LoginFailures
+	var last sql.NullTime
+	err := db.QueryRow(query, value, window.Seconds(), excluded).Scan(&f.Count, &last)
+	if err != nil {
+		return models.LoginFailures{}, err
+	}
+
+	if last.Valid {
+		f.Last = last.Time.UTC()
+	}
+
+	return f, nil
+}
+
+// Delete forgets a login attempt, when it has been refused without checking
+// the password.
+func (m *LoginAttemptModel) Delete(id int) error {
+	query := "DELETE FROM login_attempts WHERE id = $1"
+
+	_, err := m.DB.Exec(query, id)
+	return err
+}
+
+// Reset deletes the failed login attempts of an email address, after a
+// successful login.
+func (m *LoginAttemptModel) Reset(email string) error {
+	query := "DELETE FROM login_attempts WHERE email = $1"
+
+	_, err := m.DB.Exec(query, email)
+	return err
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// ModerationModel is a struct used to call DB operations. Each of its methods
+// takes a moderation action and records it in the audit trail as entry, in a
+// single transaction: the action is never taken without being recorded, nor
+// recorded without being taken.
+type ModerationModel struct {
+	DB *sql.DB
+}
+
+// DismissReports closes the open reports of the snippet of the entry, and
+// makes it visible again if it was hidden. It returns ErrNoRecord if the
+// snippet has no open report.
+func (m *ModerationModel) DismissReports(ctx context.Context, entry models.AuditEntry) error {
+	return m.inTx(ctx, &entry, func(tx *sql.Tx) error {
+		err := update(ctx, tx, "UPDATE reports SET status = $1 WHERE snippet_id = $2 AND status = $3",
+			models.ReportStatusDismissed, entry.SnippetID, models.ReportStatusOpen)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE snippets SET hidden = FALSE WHERE id = $1", entry.SnippetID)
+		return err
+	})
+}
+
+// HideSnippet hides the snippet of the entry until its reports are dismissed.
+// It returns ErrNoRecord if no such snippet exists.
+func (m *ModerationModel) HideSnippet(ctx context.Context, entry models.AuditEntry) error {
+	return m.inTx(ctx, &entry, func(tx *sql.Tx) error {
+		return update(ctx, tx, "UPDATE snippets SET hidden = TRUE WHERE id = $1", entry.SnippetID)
+	})
+}
+
+// DeleteSnippet deletes the snippet of the entry, even if it is hidden or
+// expired, together with its reports. The entry is recorded with the author
+// and the title of the snippet. It returns the deleted snippet, or
+// ErrNoRecord if no such snippet exists.
+func (m *ModerationModel) DeleteSnippet(ctx context.Context, entry models.AuditEntry) (models.Snippet, error) {
+	var s models.Snippet
+
+	err := m.inTx(ctx, &entry, func(tx *sql.Tx) error {
+		query := "DELETE FROM snippets WHERE id = $1 RETURNING " + snippetColumns
+
+		var err error
+		s, err = scanSnippet(tx.QueryRowContext(ctx, query, entry.SnippetID))
+		if err != nil {
+			return pgError(err)
+		}
+
+		entry.UserID = s.UserID
+		entry.Details = s.Title
+
+		return nil
+	})
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	return s, nil
+}
+
+// SetSuspended suspends or reinstates the user of the entry. It returns
+// ErrNoRecord if no such user exists.
+func (m *ModerationModel) SetSuspended(ctx context.Context, entry models.AuditEntry, suspended bool) error {
+	return m.inTx(ctx, &entry, func(tx *sql.Tx) error {
+		return update(ctx, tx, "UPDATE users SET suspended = $1 WHERE id = $2", suspended, entry.UserID)
+	})
+}
+
+// SetRole changes the role of the user of the entry. It returns ErrNoRecord
+// if no such user exists.
+func (m *ModerationModel) SetRole(ctx context.Context, entry models.AuditEntry, role models.Role) error {
+	return m.inTx(ctx, &entry, func(tx *sql.Tx) error {
+		return update(ctx, tx, "UPDATE users SET role = $1 WHERE id = $2", string(role), entry.UserID)
+	})
+}
+
+// inTx runs the action in a transaction, then records entry in the audit
+// trail and commits. The action can complete the entry before it is recorded.
+// If the action fails, the transaction is rolled back.
+func (m *ModerationModel) inTx(ctx context.Context, entry *models.AuditEntry, action func(tx *sql.Tx) error) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	err = action(tx)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO audit_log (actor_id, action, snippet_id, user_id, details, created)
+			  VALUES($1, $2, $3, $4, $5, now())`
+
+	_, err = tx.ExecContext(ctx, query, entry.ActorID, entry.Action, entry.SnippetID, entry.UserID, entry.Details)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,112 @@
+package postgres_test
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/migrate"
+	"github.com/AlessioPani/go-snippetbox/internal/models/modelstest"
+	"github.com/AlessioPani/go-snippetbox/internal/models/postgres"
+	"github.com/AlessioPani/go-snippetbox/migrations"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// dsnEnv is the environment variable holding the URL of the PostgreSQL
+// database to run the tests on. The tests are skipped if it isn't set.
+const dsnEnv = "SNIPPETBOX_TEST_POSTGRES_DSN"
+
+// TestConformance runs the conformance suite of the models on PostgreSQL. Each
+// test gets a schema of its own, dropped once it's done.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s isn't set", dsnEnv)
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	modelstest.Run(t, func(t *testing.T) modelstest.Models {
+		schema := randomSchema(t)
+
+		_, err := admin.Exec("CREATE SCHEMA " + schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			_, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+			if err != nil {
+				t.Error(err)
+			}
+		})
+
+		db, err := sql.Open("pgx", withSearchPath(t, dsn, schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		files, err := migrations.Dialect("postgres")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		migrator, err := migrate.New(db, migrate.Postgres, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = migrator.Up()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return modelstest.Models{
+			Snippets:      &postgres.SnippetModel{DB: db},
+			Users:         &postgres.UserModel{DB: db},
+			Stats:         &postgres.StatsModel{DB: db},
+			Reports:       &postgres.ReportModel{DB: db},
+			Audit:         &postgres.AuditModel{DB: db},
+			Moderation:    &postgres.ModerationModel{DB: db},
+			Tokens:        &postgres.TokenModel{DB: db},
+			Webhooks:      &postgres.WebhookModel{DB: db},
+			Credentials:   &postgres.CredentialModel{DB: db},
+			Sessions:      &postgres.SessionModel{DB: db, Lifetime: 12 * time.Hour, IdleTimeout: 20 * time.Minute, RememberMeLifetime: 30 * 24 * time.Hour, TouchInterval: time.Minute},
+			LoginAttempts: &postgres.LoginAttemptModel{DB: db},
+		}
+	})
+}
+
+// randomSchema returns a random name for a schema.
+func randomSchema(t *testing.T) string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "snippetbox_test_" + hex.EncodeToString(b)
+}
+
+// withSearchPath returns the DSN with the search path set to a schema, so
+// that the tables are created and used in it.
+func withSearchPath(t *testing.T, dsn, schema string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// ReportModel is a struct used to call DB operations.
+type ReportModel struct {
+	DB *sql.DB
+}
+
+// Insert records the report of a snippet by a user. It returns
+// ErrDuplicateReport if the user has already reported the snippet, even if
+// the report has been dismissed since.
+func (m *ReportModel) Insert(snippetID, reporterID int, reason string) error {
+	query := `INSERT INTO reports (snippet_id, reporter_id, reason, status, created)
+			  VALUES($1, $2, $3, $4, now())
+			  ON CONFLICT (snippet_id, reporter_id) DO NOTHING`
+
+	result, err := m.DB.Exec(query, snippetID, reporterID, reason, models.ReportStatusOpen)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return models.ErrDuplicateReport
+	}
+
+	return nil
+}
+
+// Open is a method used to get the snippets with open reports, the ones
+// reported first coming first.
+func (m *ReportModel) Open() ([]models.ReportedSnippet, error) {
+	// The reasons are joined with the unit separator, which can't be typed in
+	// the report form.
+	query := `SELECT s.id, COALESCE(s.user_id, 0), s.title, s.content, s.hidden, s.created, s.expires,
+			  count(*), string_agg(r.reason, chr(31) ORDER BY r.id), min(r.created)
+			  FROM reports r JOIN snippets s ON s.id = r.snippet_id
+			  WHERE r.status = $1
+			  GROUP BY s.id ORDER BY min(r.created), s.id`
+
+	results, err := m.DB.Query(query, models.ReportStatusOpen)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var reported []models.ReportedSnippet
+
+	for results.Next() {
+		var rs models.ReportedSnippet
+		var reasons string
+		s := &rs.Snippet
+		err := results.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Hidden, &s.Created, &s.Expires,
+			&rs.Reports, &reasons, &rs.FirstReported)
+		if err != nil {
+			return nil, err
+		}
+		s.Created = s.Created.UTC()
+		s.Expires = s.Expires.UTC()
+		rs.Reasons = strings.Split(reasons, "\x1f")
+		rs.FirstReported = rs.FirstReported.UTC()
+		reported = append(reported, rs)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return reported, nil
+}
+
+// Dismiss closes the open reports of a snippet. It returns ErrNoRecord if the
+// snippet has no open report.
+func (m *ReportModel) Dismiss(snippetID int) error {
+	query := "UPDATE reports SET status = $1 WHERE snippet_id = $2 AND status = $3"
+
+	result, err := m.DB.Exec(query, models.ReportStatusDismissed, snippetID, models.ReportStatusOpen)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
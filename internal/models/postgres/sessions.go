@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// SessionModel is a struct used to call DB operations.
+// The durations must match the settings of the application, so that sessions
+// which have expired are not listed: Lifetime and IdleTimeout apply to normal
+// sessions, RememberMeLifetime to sessions of users who asked to be remembered.
+// The last seen time of a session is only updated once it's older than
+// TouchInterval, which must be well below IdleTimeout.
+type SessionModel struct {
+	DB                 *sql.DB
+	Lifetime           time.Duration
+	IdleTimeout        time.Duration
+	RememberMeLifetime time.Duration
+	TouchInterval      time.Duration
+}
+
+// activeCondition returns the SQL condition matching the sessions which have
+// not expired, whose arguments are the intervals returned by m.intervals(),
+// numbered from n.
+func activeCondition(n int) string {
+	return fmt.Sprintf(`((remember_me AND created > now() - make_interval(secs => $%d))
+	OR (NOT remember_me AND created > now() - make_interval(secs => $%d) AND last_seen > now() - make_interval(secs => $%d)))`,
+		n, n+1, n+2)
+}
+
+// intervals returns the intervals, in seconds, used by activeCondition.
+func (m *SessionModel) intervals() []any {
+	return []any{m.RememberMeLifetime.Seconds(), m.Lifetime.Seconds(), m.IdleTimeout.Seconds()}
+}
+
+// Insert records a new session for a user and returns its ID. The expired
+// sessions of the user are removed at the same time.
+func (m *SessionModel) Insert(userID int, userAgent, ip string, rememberMe bool) (string, error) {
+	id, err := models.NewSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	query := "DELETE FROM user_sessions WHERE user_id = $1 AND NOT " + activeCondition(2)
+
+	_, err = m.DB.Exec(query, append([]any{userID}, m.intervals()...)...)
+	if err != nil {
+		return "", err
+	}
+
+	query = `INSERT INTO user_sessions (id, user_id, user_agent, ip, remember_me, created, last_seen)
+			 VALUES($1, $2, $3, $4, $5, now(), now())`
+
+	_, err = m.DB.Exec(query, id, userID, userAgent, ip, rememberMe)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Touch updates the last seen time and IP address of a session, if the last
+// seen time is older than TouchInterval: sessions are checked on every request,
+// but they don't need to be written that often. It returns false if the
+// session doesn't exist anymore, i.e. it has been revoked.
+func (m *SessionModel) Touch(id string, userID int, ip string) (bool, error) {
+	query := "SELECT last_seen > now() - make_interval(secs => $1) FROM user_sessions WHERE id = $2 AND user_id = $3"
+
+	var recent bool
+	err := m.DB.QueryRow(query, m.TouchInterval.Seconds(), id, userID).Scan(&recent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if recent {
+		return true, nil
+	}
+
+	query = "UPDATE user_sessions SET last_seen = now(), ip = $1 WHERE id = $2 AND user_id = $3"
+
+	result, err := m.DB.Exec(query, ip, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// GetByUser is a method used to get the active sessions of a user, most
+// recently used first.
+func (m *SessionModel) GetByUser(userID int) ([]models.Session, error) {
+	query := `SELECT id, user_id, user_agent, ip, remember_me, created, last_seen FROM user_sessions
+			  WHERE user_id = $1 AND ` + activeCondition(2) + ` ORDER BY last_seen DESC`
+
+	results, err := m.DB.Query(query, append([]any{userID}, m.intervals()...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var sessions []models.Session
+
+	for results.Next() {
+		var s models.Session
+		err := results.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.RememberMe, &s.Created, &s.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		s.Created = s.Created.UTC()
+		s.LastSeen = s.LastSeen.UTC()
+		sessions = append(sessions, s)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Count returns the number of active sessions, of all the users.
+func (m *SessionModel) Count() (int, error) {
+	var count int
+
+	query := "SELECT count(*) FROM user_sessions WHERE " + activeCondition(1)
+
+	err := m.DB.QueryRow(query, m.intervals()...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Delete revokes a session of a user. It returns ErrNoRecord if no such
+// session exists.
+func (m *SessionModel) Delete(id string, userID int) error {
+	return update(context.Background(), m.DB, "DELETE FROM user_sessions WHERE id = $1 AND user_id = $2", id, userID)
+}
+
+// DeleteByUser revokes all the sessions of a user but exceptID, which can be
+// empty to revoke every session.
+func (m *SessionModel) DeleteByUser(userID int, exceptID string) error {
+	query := "DELETE FROM user_sessions WHERE user_id = $1 AND id != $2"
+
+	_, err := m.DB.Exec(query, userID, exceptID)
+	return err
+}
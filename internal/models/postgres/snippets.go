@@ -0,0 +1,222 @@
+// Package postgres provides the models of the application backed by a
+// PostgreSQL database, opened with the pgx driver of database/sql. They behave
+// as the SQLite models of the models package, which are the reference: the
+// text is searched without regard to case, and the times are returned in UTC.
+// Every model of the models package has its PostgreSQL counterpart.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// snippetColumns are the columns selected for a Snippet, in the order they
+// are scanned.
+const snippetColumns = "id, COALESCE(user_id, 0), title, content, language, hidden, created, updated, expires"
+
+// SnippetModel is a struct used to call DB operations.
+type SnippetModel struct {
+	DB *sql.DB
+}
+
+// where returns the conditions of the WHERE clause selecting the snippets
+// which match a filter, with their arguments, numbered from 1.
+func where(f models.SnippetFilter) ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	if f.Search != "" {
+		args = append(args, likePattern(f.Search))
+		conditions = append(conditions, fmt.Sprintf(`(title ILIKE $%d ESCAPE '\' OR content ILIKE $%[1]d ESCAPE '\')`, len(args)))
+	}
+
+	if f.UserID != 0 {
+		args = append(args, f.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+
+	if f.Language != "" {
+		args = append(args, f.Language)
+		conditions = append(conditions, fmt.Sprintf("lower(language) = lower($%d)", len(args)))
+	}
+
+	switch f.Status {
+	case models.SnippetStatusLive:
+		conditions = append(conditions, "expires > now()")
+	case models.SnippetStatusExpired:
+		conditions = append(conditions, "expires <= now()")
+	}
+
+	return conditions, args
+}
+
+// Insert is a function used to insert a snippet of a user on the DB, which
+// expires the given number of days from now. A userID of 0 inserts a snippet
+// without a user, as a deleted user leaves behind. It returns
+// ErrForeignKeyViolation if no such user exists.
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, language string, expires int) (int, error) {
+	query := `INSERT INTO snippets (user_id, title, content, language, created, updated, expires)
+			  VALUES(NULLIF($1, 0), $2, $3, $4, now(), now(), now() + make_interval(days => $5))
+			  RETURNING id`
+
+	var id int
+	err := m.DB.QueryRowContext(ctx, query, userID, title, content, language, expires).Scan(&id)
+	if err != nil {
+		return 0, pgError(err)
+	}
+
+	return id, nil
+}
+
+// Get is a method used to get a snippet based on its ID, as long as it can be
+// seen: it hasn't expired and it isn't hidden.
+func (m *SnippetModel) Get(ctx context.Context, id int) (models.Snippet, error) {
+	query := "SELECT " + snippetColumns + " FROM snippets WHERE expires > now() AND NOT hidden AND id = $1"
+
+	return m.get(ctx, query, id)
+}
+
+// GetAny is a method used to get a snippet based on its ID, even if it has
+// expired or it is hidden.
+func (m *SnippetModel) GetAny(ctx context.Context, id int) (models.Snippet, error) {
+	query := "SELECT " + snippetColumns + " FROM snippets WHERE id = $1"
+
+	return m.get(ctx, query, id)
+}
+
+// get runs a query returning a single snippet.
+func (m *SnippetModel) get(ctx context.Context, query string, args ...any) (models.Snippet, error) {
+	s, err := scanSnippet(m.DB.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return models.Snippet{}, pgError(err)
+	}
+
+	return s, nil
+}
+
+// Latest is a method used to get the latest 10 valid snippets.
+func (m *SnippetModel) Latest(ctx context.Context) ([]models.Snippet, error) {
+	return m.LatestBy(ctx, models.SnippetFilter{})
+}
+
+// LatestBy is a method used to get the latest 10 valid snippets matching a
+// filter. The status of the filter is ignored, as only the live snippets are
+// valid.
+func (m *SnippetModel) LatestBy(ctx context.Context, filter models.SnippetFilter) ([]models.Snippet, error) {
+	filter.Status = models.SnippetStatusLive
+	conditions, args := where(filter)
+	conditions = append(conditions, "NOT hidden")
+
+	query := "SELECT " + snippetColumns + " FROM snippets WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY id DESC LIMIT 10"
+
+	return m.query(ctx, query, args...)
+}
+
+// List is a method used to get the latest 100 snippets matching a filter,
+// including the hidden and the expired ones unless the filter says otherwise.
+func (m *SnippetModel) List(ctx context.Context, filter models.SnippetFilter) ([]models.Snippet, error) {
+	conditions, args := where(filter)
+
+	query := "SELECT " + snippetColumns + " FROM snippets"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT 100"
+
+	return m.query(ctx, query, args...)
+}
+
+// Page is a method used to get a page of the valid snippets, newest first,
+// along with the total number of valid snippets. Pages are numbered from 1.
+func (m *SnippetModel) Page(ctx context.Context, page int, pageSize int) ([]models.Snippet, int, error) {
+	var total int
+
+	query := "SELECT count(*) FROM snippets WHERE expires > now() AND NOT hidden"
+
+	err := m.DB.QueryRowContext(ctx, query).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query = "SELECT " + snippetColumns + ` FROM snippets
+			 WHERE expires > now() AND NOT hidden ORDER BY id DESC LIMIT $1 OFFSET $2`
+
+	snippets, err := m.query(ctx, query, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return snippets, total, nil
+}
+
+// Update is a method used to change the title, the content and the language
+// of a snippet, which then expires the given number of days from now. It
+// returns ErrNoRecord if no such snippet exists.
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, language string, expires int) error {
+	query := `UPDATE snippets SET title = $1, content = $2, language = $3, updated = now(), expires = now() + make_interval(days => $4)
+			  WHERE id = $5`
+
+	return update(ctx, m.DB, query, title, content, language, expires, id)
+}
+
+// SetHidden hides a snippet pending review, or makes it visible again. It
+// returns ErrNoRecord if no such snippet exists.
+func (m *SnippetModel) SetHidden(ctx context.Context, id int, hidden bool) error {
+	return update(ctx, m.DB, "UPDATE snippets SET hidden = $1 WHERE id = $2", hidden, id)
+}
+
+// Delete is a method used to delete a snippet, even if it hasn't expired yet.
+// It returns ErrNoRecord if no such snippet exists.
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	return update(ctx, m.DB, "DELETE FROM snippets WHERE id = $1", id)
+}
+
+// query runs a query returning snippets and collects them.
+func (m *SnippetModel) query(ctx context.Context, query string, args ...any) ([]models.Snippet, error) {
+	results, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var snippets []models.Snippet
+
+	for results.Next() {
+		s, err := scanSnippet(results)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSnippet scans the snippetColumns of a row.
+func scanSnippet(row scanner) (models.Snippet, error) {
+	var s models.Snippet
+
+	err := row.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Hidden, &s.Created, &s.Updated, &s.Expires)
+	if err != nil {
+		return models.Snippet{}, err
+	}
+	s.Created = s.Created.UTC()
+	s.Updated = s.Updated.UTC()
+	s.Expires = s.Expires.UTC()
+
+	return s, nil
+}
@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// StatsModel is a struct used to call DB operations.
+type StatsModel struct {
+	DB *sql.DB
+}
+
+// Get is a method used to get the counts of users and snippets, together with
+// the signups per day over the last days, most recent first. The days without
+// signups are omitted. The days are in UTC, as on SQLite.
+func (m *StatsModel) Get(days int) (models.Stats, error) {
+	var s models.Stats
+
+	query := `SELECT (SELECT count(*) FROM users),
+			  (SELECT count(*) FROM snippets WHERE expires > now()),
+			  (SELECT count(*) FROM snippets WHERE expires <= now())`
+
+	err := m.DB.QueryRow(query).Scan(&s.Users, &s.LiveSnippets, &s.ExpiredSnippets)
+	if err != nil {
+		return models.Stats{}, err
+	}
+
+	query = `SELECT to_char(created AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, count(*) FROM users
+			 WHERE created AT TIME ZONE 'UTC' > date_trunc('day', now() AT TIME ZONE 'UTC') - make_interval(days => $1)
+			 GROUP BY day ORDER BY day DESC`
+
+	results, err := m.DB.Query(query, days-1)
+	if err != nil {
+		return models.Stats{}, err
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var c models.DailyCount
+		err := results.Scan(&c.Day, &c.Count)
+		if err != nil {
+			return models.Stats{}, err
+		}
+		s.Signups = append(s.Signups, c)
+	}
+
+	if err = results.Err(); err != nil {
+		return models.Stats{}, err
+	}
+
+	return s, nil
+}
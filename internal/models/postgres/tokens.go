@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// TokenModel is a struct used to call DB operations.
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// Insert creates a new API token for a user and returns it. The token expires
+// in expiresIn days, or never if expiresIn is 0.
+func (m *TokenModel) Insert(userID int, name string, scopes []string, expiresIn int) (string, error) {
+	token, err := models.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	// As for the SQLite model, the interval is built with strconv.Itoa. A
+	// NULL expiry means that the token never expires.
+	expires := "NULL"
+	if expiresIn > 0 {
+		expires = "now() + make_interval(days => " + strconv.Itoa(expiresIn) + ")"
+	}
+
+	query := `INSERT INTO api_tokens (user_id, name, token_hash, scopes, created, expires)
+			  VALUES($1, $2, $3, $4, now(), ` + expires + ")"
+
+	_, err = m.DB.Exec(query, userID, name, models.HashToken(token), strings.Join(scopes, " "))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Authenticate is used to get the API token matching a token sent by a
+// client, updating its last used time if it's older than TokenUseInterval.
+// It returns ErrInvalidCredentials if the token doesn't exist, has been
+// revoked or has expired.
+func (m *TokenModel) Authenticate(token string) (models.Token, error) {
+	if !strings.HasPrefix(token, models.TokenPrefix) {
+		return models.Token{}, models.ErrInvalidCredentials
+	}
+
+	query := `SELECT id, user_id, name, scopes, created, expires, last_used FROM api_tokens
+			  WHERE token_hash = $1 AND (expires IS NULL OR expires > now())`
+
+	t, err := scanToken(m.DB.QueryRow(query, models.HashToken(token)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Token{}, models.ErrInvalidCredentials
+		}
+		return models.Token{}, err
+	}
+
+	if !t.Used(models.TokenUseInterval) {
+		query = "UPDATE api_tokens SET last_used = now() WHERE id = $1"
+
+		_, err = m.DB.Exec(query, t.ID)
+		if err != nil {
+			return models.Token{}, err
+		}
+	}
+
+	return t, nil
+}
+
+// GetByUser is a method used to get the API tokens of a user, including the
+// expired ones, the most recent first.
+func (m *TokenModel) GetByUser(userID int) ([]models.Token, error) {
+	query := `SELECT id, user_id, name, scopes, created, expires, last_used FROM api_tokens
+			  WHERE user_id = $1 ORDER BY id DESC`
+
+	results, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var tokens []models.Token
+
+	for results.Next() {
+		t, err := scanToken(results)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes an API token of a user. It returns ErrNoRecord if no such
+// token exists.
+func (m *TokenModel) Delete(id, userID int) error {
+	return update(context.Background(), m.DB, "DELETE FROM api_tokens WHERE id = $1 AND user_id = $2", id, userID)
+}
+
+// scanToken scans a row of the api_tokens table.
+func scanToken(row scanner) (models.Token, error) {
+	var t models.Token
+	var scopes string
+
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created, &t.Expires, &t.LastUsed)
+	if err != nil {
+		return models.Token{}, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	t.Created = t.Created.UTC()
+	t.Expires.Time = t.Expires.Time.UTC()
+	t.LastUsed.Time = t.LastUsed.Time.UTC()
+
+	return t, nil
+}
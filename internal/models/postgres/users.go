@@ -0,0 +1,205 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserModel is a struct used to call DB operations.
+type UserModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new record to the Users table. It returns ErrDuplicateEmail
+// if the email address is already in use.
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO users (name, email, hashed_password, created)
+			  VALUES($1, $2, $3, now())`
+
+	_, err = m.DB.ExecContext(ctx, query, name, email, string(hashedPassword))
+	return pgError(err)
+}
+
+// Authenticate is used to verify whether a user exists with the provided email address and password.
+// This will return the relevant user ID if they do.
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	var id int
+	var hashedPassword []byte
+
+	query := "SELECT id, hashed_password FROM users WHERE email = $1"
+
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(&id, &hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Exists is used to check if a user exists with a specific ID.
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	var exists bool
+
+	query := "SELECT EXISTS(SELECT true FROM users WHERE id = $1)"
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&exists)
+	return exists, err
+}
+
+// Get is a method used to get a user based on its ID.
+func (m *UserModel) Get(ctx context.Context, id int) (models.User, error) {
+	query := "SELECT id, name, email, role, suspended, created FROM users WHERE id = $1"
+
+	u, err := scanUser(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, models.ErrNoRecord
+		}
+		return models.User{}, err
+	}
+
+	return u, nil
+}
+
+// PasswordUpdate changes the password of a user, after checking that the
+// current password provided is correct. If it isn't, ErrInvalidCredentials is
+// returned.
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
+	var currentHashedPassword []byte
+
+	query := "SELECT hashed_password FROM users WHERE id = $1"
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&currentHashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		}
+		return err
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	query = "UPDATE users SET hashed_password = $1 WHERE id = $2"
+
+	_, err = m.DB.ExecContext(ctx, query, string(newHashedPassword), id)
+	return err
+}
+
+// List is a method used to get the latest 100 users whose name or email
+// address contains search, which can be empty to match every user.
+func (m *UserModel) List(ctx context.Context, search string) ([]models.User, error) {
+	query := `SELECT id, name, email, role, suspended, created FROM users
+			  WHERE name ILIKE $1 ESCAPE '\' OR email ILIKE $1 ESCAPE '\'
+			  ORDER BY id DESC LIMIT 100`
+
+	results, err := m.DB.QueryContext(ctx, query, likePattern(search))
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var users []models.User
+
+	for results.Next() {
+		u, err := scanUser(results)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// SetSuspended suspends or reinstates a user. It returns ErrNoRecord if no
+// such user exists.
+func (m *UserModel) SetSuspended(ctx context.Context, id int, suspended bool) error {
+	return update(ctx, m.DB, "UPDATE users SET suspended = $1 WHERE id = $2", suspended, id)
+}
+
+// SetRole changes the role of a user. It returns ErrNoRecord if no such user
+// exists.
+func (m *UserModel) SetRole(ctx context.Context, id int, role models.Role) error {
+	return update(ctx, m.DB, "UPDATE users SET role = $1 WHERE id = $2", string(role), id)
+}
+
+// scanUser scans the columns of a user selected by Get and List.
+func scanUser(row scanner) (models.User, error) {
+	var u models.User
+
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.Suspended, &u.Created)
+	if err != nil {
+		return models.User{}, err
+	}
+	u.Created = u.Created.UTC()
+
+	return u, nil
+}
+
+// execer is implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// update runs a query changing rows, in a database or a transaction,
+// returning ErrNoRecord if no row has been changed.
+func update(ctx context.Context, db execer, query string, args ...any) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// likePattern returns a LIKE pattern matching the strings which contain s,
+// escaping the wildcards in s with a backslash.
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
+}
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// WebhookModel is a struct used to call DB operations.
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+// Insert registers a webhook of a user, with the secret used to sign the
+// payloads sent to it.
+func (m *WebhookModel) Insert(userID int, url string, secret string) (int, error) {
+	query := "INSERT INTO webhooks (user_id, url, secret, created) VALUES($1, $2, $3, now()) RETURNING id"
+
+	var id int
+	err := m.DB.QueryRow(query, userID, url, secret).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetByUser returns the webhooks of a user, oldest first.
+func (m *WebhookModel) GetByUser(userID int) ([]models.Webhook, error) {
+	query := "SELECT id, user_id, url, created FROM webhooks WHERE user_id = $1 ORDER BY id"
+
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+
+	for rows.Next() {
+		var w models.Webhook
+		err = rows.Scan(&w.ID, &w.UserID, &w.URL, &w.Created)
+		if err != nil {
+			return nil, err
+		}
+		w.Created = w.Created.UTC()
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// Delete removes a webhook of a user, along with its deliveries. It returns
+// ErrNoRecord if the user has no such webhook.
+func (m *WebhookModel) Delete(id, userID int) error {
+	return update(context.Background(), m.DB, "DELETE FROM webhooks WHERE id = $1 AND user_id = $2", id, userID)
+}
+
+// Enqueue adds a pending delivery of an event to every webhook of a user.
+func (m *WebhookModel) Enqueue(userID int, event string, payload []byte) error {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt, created)
+			  SELECT id, $1, $2, $3, now(), now() FROM webhooks WHERE user_id = $4`
+
+	_, err := m.DB.Exec(query, event, payload, models.DeliveryStatusPending, userID)
+	return err
+}
+
+// Claim returns the oldest pending delivery which is due, counting a new
+// attempt for it, or ErrNoRecord if none is due. It is leased for the given
+// duration: if it is still pending by then, because the worker delivering it
+// has stopped, it can be claimed again. The delivery is locked by the claim
+// and skipped by the concurrent ones, so the workers never claim the same
+// delivery. A delivery is claimed right before it is sent, so the lease only
+// has to outlast a single attempt.
+func (m *WebhookModel) Claim(lease time.Duration) (models.Delivery, error) {
+	claim := `UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt = now() + make_interval(secs => $1)
+			  WHERE id = (
+				  SELECT id FROM webhook_deliveries WHERE status = $2 AND next_attempt <= now()
+				  ORDER BY next_attempt, id LIMIT 1
+				  FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING id, webhook_id, event, payload, status, attempts`
+
+	webhook := "SELECT url, secret FROM webhooks WHERE id = $1"
+
+	for {
+		var d models.Delivery
+		err := m.DB.QueryRow(claim, lease.Seconds(), models.DeliveryStatusPending).Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts)
+		if err != nil {
+			return models.Delivery{}, pgError(err)
+		}
+
+		// Add the URL and the secret of its webhook. The deliveries of a
+		// webhook deleted meanwhile have been deleted too, so the next one is
+		// claimed instead.
+		err = m.DB.QueryRow(webhook, d.WebhookID).Scan(&d.URL, &d.Secret)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return models.Delivery{}, err
+		}
+
+		return d, nil
+	}
+}
+
+// Delivered records the successful attempt of a delivery.
+func (m *WebhookModel) Delivered(id int, responseCode int) error {
+	query := `UPDATE webhook_deliveries SET status = $1, response_code = $2, error = '', delivered = now()
+			  WHERE id = $3`
+
+	return update(context.Background(), m.DB, query, models.DeliveryStatusDelivered, responseCode, id)
+}
+
+// Retry records the failed attempt of a delivery, which is attempted again
+// in retryIn. The response code is 0 if no response has been received.
+func (m *WebhookModel) Retry(id int, responseCode int, deliveryErr string, retryIn time.Duration) error {
+	query := `UPDATE webhook_deliveries SET status = $1, response_code = $2, error = $3, next_attempt = now() + make_interval(secs => $4)
+			  WHERE id = $5`
+
+	return update(context.Background(), m.DB, query, models.DeliveryStatusPending, responseCode, deliveryErr, retryIn.Seconds(), id)
+}
+
+// Fail records the last failed attempt of a delivery, which isn't attempted
+// anymore.
+func (m *WebhookModel) Fail(id int, responseCode int, deliveryErr string) error {
+	query := "UPDATE webhook_deliveries SET status = $1, response_code = $2, error = $3 WHERE id = $4"
+
+	return update(context.Background(), m.DB, query, models.DeliveryStatusFailed, responseCode, deliveryErr, id)
+}
+
+// Deliveries returns the latest deliveries to the webhooks of a user, newest
+// first, without their payload.
+func (m *WebhookModel) Deliveries(userID int, limit int) ([]models.Delivery, error) {
+	query := `SELECT d.id, d.webhook_id, w.url, d.event, d.status, d.attempts, d.response_code, d.error, d.created, d.delivered
+			  FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+			  WHERE w.user_id = $1 ORDER BY d.id DESC LIMIT $2`
+
+	rows, err := m.DB.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.Delivery
+
+	for rows.Next() {
+		var d models.Delivery
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Event, &d.Status, &d.Attempts, &d.ResponseCode, &d.Error, &d.Created, &d.Delivered)
+		if err != nil {
+			return nil, err
+		}
+		d.Created = d.Created.UTC()
+		d.Delivered.Time = d.Delivered.Time.UTC()
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
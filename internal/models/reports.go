@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Statuses of the reports. A report stays open until a moderator dismisses it
+// or deletes the snippet, which deletes its reports too.
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusDismissed = "dismissed"
+)
+
+// ReportedSnippet is a struct containing a snippet with open reports, as
+// listed in the moderation queue.
+type ReportedSnippet struct {
+	Snippet       Snippet
+	Reports       int
+	Reasons       []string
+	FirstReported time.Time
+}
+
+// ReportModelInterface interface.
+type ReportModelInterface interface {
+	Insert(snippetID, reporterID int, reason string) error
+	Open() ([]ReportedSnippet, error)
+	Dismiss(snippetID int) error
+}
+
+// ReportModel is a struct used to call DB operations.
+type ReportModel struct {
+	DB *sql.DB
+}
+
+// Insert records the report of a snippet by a user. It returns
+// ErrDuplicateReport if the user has already reported the snippet, even if
+// the report has been dismissed since.
+func (m *ReportModel) Insert(snippetID, reporterID int, reason string) error {
+	query := `INSERT INTO reports (snippet_id, reporter_id, reason, status, created)
+			  VALUES(?, ?, ?, ?, datetime())
+			  ON CONFLICT (snippet_id, reporter_id) DO NOTHING`
+
+	result, err := m.DB.Exec(query, snippetID, reporterID, reason, ReportStatusOpen)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrDuplicateReport
+	}
+
+	return nil
+}
+
+// Open is a method used to get the snippets with open reports, the ones
+// reported first coming first.
+func (m *ReportModel) Open() ([]ReportedSnippet, error) {
+	// The reasons are joined with the unit separator, which can't be typed in
+	// the report form. The aggregated date has no type, so it is read as a
+	// Unix time.
+	query := `SELECT s.id, COALESCE(s.user_id, 0), s.title, s.content, s.hidden, s.created, s.expires,
+			  COUNT(*), group_concat(r.reason, char(31)), unixepoch(MIN(r.created))
+			  FROM reports r JOIN snippets s ON s.id = r.snippet_id
+			  WHERE r.status = ?
+			  GROUP BY s.id ORDER BY MIN(r.created), s.id`
+
+	results, err := m.DB.Query(query, ReportStatusOpen)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var reported []ReportedSnippet
+
+	for results.Next() {
+		var rs ReportedSnippet
+		var reasons string
+		var firstReported int64
+		s := &rs.Snippet
+		err := results.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Hidden, &s.Created, &s.Expires,
+			&rs.Reports, &reasons, &firstReported)
+		if err != nil {
+			return nil, err
+		}
+		rs.Reasons = strings.Split(reasons, "\x1f")
+		rs.FirstReported = time.Unix(firstReported, 0).UTC()
+		reported = append(reported, rs)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return reported, nil
+}
+
+// Dismiss closes the open reports of a snippet. It returns ErrNoRecord if the
+// snippet has no open report.
+func (m *ReportModel) Dismiss(snippetID int) error {
+	query := "UPDATE reports SET status = ? WHERE snippet_id = ? AND status = ?"
+
+	result, err := m.DB.Exec(query, ReportStatusDismissed, snippetID, ReportStatusOpen)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Session is a struct containing the data of a logged in session of a user.
+type Session struct {
+	ID         string
+	UserID     int
+	UserAgent  string
+	IP         string
+	RememberMe bool
+	Created    time.Time
+	LastSeen   time.Time
+}
+
+// SessionModelInterface interface.
+type SessionModelInterface interface {
+	Insert(userID int, userAgent, ip string, rememberMe bool) (string, error)
+	Touch(id string, userID int, ip string) (bool, error)
+	GetByUser(userID int) ([]Session, error)
+	Count() (int, error)
+	Delete(id string, userID int) error
+	DeleteByUser(userID int, exceptID string) error
+}
+
+// SessionModel is a struct used to call DB operations.
+// The durations must match the settings of the application, so that sessions
+// which have expired are not listed: Lifetime and IdleTimeout apply to normal
+// sessions, RememberMeLifetime to sessions of users who asked to be remembered.
+// The last seen time of a session is only updated once it's older than
+// TouchInterval, which must be well below IdleTimeout.
+type SessionModel struct {
+	DB                 *sql.DB
+	Lifetime           time.Duration
+	IdleTimeout        time.Duration
+	RememberMeLifetime time.Duration
+	TouchInterval      time.Duration
+}
+
+// activeCondition is the SQL condition matching the sessions which have not
+// expired, given the modifiers returned by m.modifiers().
+const activeCondition = `((remember_me AND created > datetime('now', ?))
+	OR (NOT remember_me AND created > datetime('now', ?) AND last_seen > datetime('now', ?)))`
+
+// modifiers returns the datetime() modifiers used by activeCondition.
+func (m *SessionModel) modifiers() []any {
+	return []any{modifier(m.RememberMeLifetime), modifier(m.Lifetime), modifier(m.IdleTimeout)}
+}
+
+// Insert records a new session for a user and returns its ID. The expired
+// sessions of the user are removed at the same time.
+func (m *SessionModel) Insert(userID int, userAgent, ip string, rememberMe bool) (string, error) {
+	id, err := NewSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	query := "DELETE FROM user_sessions WHERE user_id = ? AND NOT " + activeCondition
+
+	_, err = m.DB.Exec(query, append([]any{userID}, m.modifiers()...)...)
+	if err != nil {
+		return "", err
+	}
+
+	query = `INSERT INTO user_sessions (id, user_id, user_agent, ip, remember_me, created, last_seen)
+			 VALUES(?, ?, ?, ?, ?, datetime(), datetime())`
+
+	_, err = m.DB.Exec(query, id, userID, userAgent, ip, rememberMe)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Touch updates the last seen time and IP address of a session, if the last
+// seen time is older than TouchInterval: sessions are checked on every request,
+// but they don't need to be written that often. It returns false if the
+// session doesn't exist anymore, i.e. it has been revoked.
+func (m *SessionModel) Touch(id string, userID int, ip string) (bool, error) {
+	query := "SELECT last_seen > datetime('now', ?) FROM user_sessions WHERE id = ? AND user_id = ?"
+
+	var recent bool
+	err := m.DB.QueryRow(query, modifier(m.TouchInterval), id, userID).Scan(&recent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if recent {
+		return true, nil
+	}
+
+	query = "UPDATE user_sessions SET last_seen = datetime(), ip = ? WHERE id = ? AND user_id = ?"
+
+	result, err := m.DB.Exec(query, ip, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// GetByUser is a method used to get the active sessions of a user, most
+// recently used first.
+func (m *SessionModel) GetByUser(userID int) ([]Session, error) {
+	query := `SELECT id, user_id, user_agent, ip, remember_me, created, last_seen FROM user_sessions
+			  WHERE user_id = ? AND ` + activeCondition + ` ORDER BY last_seen DESC`
+
+	results, err := m.DB.Query(query, append([]any{userID}, m.modifiers()...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var sessions []Session
+
+	for results.Next() {
+		var s Session
+		err := results.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.RememberMe, &s.Created, &s.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Count returns the number of active sessions, of all the users.
+func (m *SessionModel) Count() (int, error) {
+	var count int
+
+	query := "SELECT count(*) FROM user_sessions WHERE " + activeCondition
+
+	err := m.DB.QueryRow(query, m.modifiers()...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Delete revokes a session of a user. It returns ErrNoRecord if no such
+// session exists.
+func (m *SessionModel) Delete(id string, userID int) error {
+	query := "DELETE FROM user_sessions WHERE id = ? AND user_id = ?"
+
+	result, err := m.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// DeleteByUser revokes all the sessions of a user but exceptID, which can be
+// empty to revoke every session.
+func (m *SessionModel) DeleteByUser(userID int, exceptID string) error {
+	query := "DELETE FROM user_sessions WHERE user_id = ? AND id != ?"
+
+	_, err := m.DB.Exec(query, userID, exceptID)
+	return err
+}
+
+// NewSessionID returns a new random ID for a session, made of 32 hexadecimal
+// digits.
+func NewSessionID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// modifier returns a datetime() modifier which goes back in time by d.
+func modifier(d time.Duration) string {
+	return fmt.Sprintf("-%d seconds", int(d.Seconds()))
+}
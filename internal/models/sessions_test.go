@@ -0,0 +1,57 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func TestSessionModelTouch(t *testing.T) {
+	db := newTestDB(t)
+	m := models.SessionModel{DB: db, Lifetime: 12 * time.Hour, IdleTimeout: 20 * time.Minute, RememberMeLifetime: 30 * 24 * time.Hour, TouchInterval: time.Minute}
+
+	id, err := m.Insert(1, "Firefox", "192.0.2.1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ip returns the IP address recorded for the session.
+	ip := func() string {
+		var ip string
+		err := db.QueryRow("SELECT ip FROM user_sessions WHERE id = ?", id).Scan(&ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ip
+	}
+
+	// A session seen within the touch interval isn't written.
+	ok, err := m.Touch(id, 1, "192.0.2.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ok, true)
+	assert.Equal(t, ip(), "192.0.2.1")
+
+	// Once the interval has passed, the session is updated.
+	_, err = db.Exec("UPDATE user_sessions SET last_seen = datetime('now', '-2 minutes') WHERE id = ?", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err = m.Touch(id, 1, "192.0.2.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ok, true)
+	assert.Equal(t, ip(), "192.0.2.2")
+
+	sessions, err := m.GetByUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(sessions), 1)
+	assert.Equal(t, time.Since(sessions[0].LastSeen) < time.Minute, true)
+}
@@ -1,44 +1,114 @@
 package models
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// Snippet is a struct containing the snippet data.
+// Snippet is a struct containing the snippet data. UserID is the ID of the
+// author, or 0 for the snippets created before the authors were recorded.
+// Language is the programming language of the content, if known.
+// A hidden snippet is pending review by a moderator and can't be seen.
+// Updated is the time the snippet was last edited, or its creation time.
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID       int
+	UserID   int
+	Title    string
+	Content  string
+	Language string
+	Hidden   bool
+	Created  time.Time
+	Updated  time.Time
+	Expires  time.Time
+}
+
+// Statuses of the snippets, used to filter them.
+const (
+	SnippetStatusLive    = "live"
+	SnippetStatusExpired = "expired"
+)
+
+// SnippetFilter contains the criteria used to filter a list of snippets. The
+// zero value of each field matches every snippet. Language is matched without
+// regard to case.
+type SnippetFilter struct {
+	Search   string
+	UserID   int
+	Language string
+	Status   string
+}
+
+// where returns the conditions of the WHERE clause selecting the snippets
+// which match the filter, with their arguments.
+func (f SnippetFilter) where() ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	if f.Search != "" {
+		conditions = append(conditions, `(title LIKE ? ESCAPE '\' OR content LIKE ? ESCAPE '\')`)
+		args = append(args, likePattern(f.Search), likePattern(f.Search))
+	}
+
+	if f.UserID != 0 {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+
+	if f.Language != "" {
+		conditions = append(conditions, "language = ? COLLATE NOCASE")
+		args = append(args, f.Language)
+	}
+
+	switch f.Status {
+	case SnippetStatusLive:
+		conditions = append(conditions, "expires > datetime()")
+	case SnippetStatusExpired:
+		conditions = append(conditions, "expires <= datetime()")
+	}
+
+	return conditions, args
 }
 
 // SnippetModel interface.
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (Snippet, error)
-	Latest() ([]Snippet, error)
+	Insert(ctx context.Context, userID int, title string, content string, language string, expires int) (int, error)
+	Get(ctx context.Context, id int) (Snippet, error)
+	GetAny(ctx context.Context, id int) (Snippet, error)
+	Latest(ctx context.Context) ([]Snippet, error)
+	LatestBy(ctx context.Context, filter SnippetFilter) ([]Snippet, error)
+	List(ctx context.Context, filter SnippetFilter) ([]Snippet, error)
+	Page(ctx context.Context, page int, pageSize int) ([]Snippet, int, error)
+	Update(ctx context.Context, id int, title string, content string, language string, expires int) error
+	SetHidden(ctx context.Context, id int, hidden bool) error
+	Delete(ctx context.Context, id int) error
 }
 
+// snippetColumns are the columns selected for a Snippet, in the order they
+// are scanned.
+const snippetColumns = "id, COALESCE(user_id, 0), title, content, language, hidden, created, updated, expires"
+
 // SnippetModel is a struct used to call DB operations.
 type SnippetModel struct {
 	DB *sql.DB
 }
 
-// Insert is a function used to insert a snippet on the DB.
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// Insert is a function used to insert a snippet of a user on the DB. A
+// userID of 0 inserts a snippet without a user, as a deleted user leaves
+// behind. It returns ErrForeignKeyViolation if no such user exists.
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, language string, expires int) (int, error) {
 	// Sqlite's datetime('now', <modifier>) doesn't work well with placeholders due to the
 	// type of the modifier, which is a composed string, so strconv.Itoa was used as a quick workaround
-	query := `INSERT INTO snippets (title, content, created, expires)
-			  VALUES(?, ?, datetime(), datetime('now','+` + strconv.Itoa(expires) + " days'))"
+	query := `INSERT INTO snippets (user_id, title, content, language, created, updated, expires)
+			  VALUES(NULLIF(?, 0), ?, ?, ?, datetime(), datetime(), datetime('now','+` + strconv.Itoa(expires) + " days'))"
 
-	// Execute the query, populating the placeholders. If errors were found, return it
-	result, err := m.DB.Exec(query, title, content)
+	// Execute the query, populating the placeholders. If errors were found,
+	// return the one of the models they stand for.
+	result, err := m.DB.ExecContext(ctx, query, userID, title, content, language)
 	if err != nil {
-		return 0, err
+		return 0, sqliteError(err)
 	}
 
 	// Get the last insert id. If errors were found, return it
@@ -51,27 +121,35 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 	return int(id), nil
 }
 
-// Get is a method used to get a snippet based on its ID.
-func (m *SnippetModel) Get(id int) (Snippet, error) {
-	query := `SELECT id, title, content, created, expires FROM snippets
-			  WHERE expires > datetime() AND id = ?`
+// Get is a method used to get a snippet based on its ID, as long as it can be
+// seen: it hasn't expired and it isn't hidden.
+func (m *SnippetModel) Get(ctx context.Context, id int) (Snippet, error) {
+	query := "SELECT " + snippetColumns + " FROM snippets WHERE expires > datetime() AND NOT hidden AND id = ?"
+
+	return m.get(ctx, query, id)
+}
+
+// GetAny is a method used to get a snippet based on its ID, even if it has
+// expired or it is hidden.
+func (m *SnippetModel) GetAny(ctx context.Context, id int) (Snippet, error) {
+	query := "SELECT " + snippetColumns + " FROM snippets WHERE id = ?"
 
+	return m.get(ctx, query, id)
+}
+
+// get runs a query returning a single snippet.
+func (m *SnippetModel) get(ctx context.Context, query string, args ...any) (Snippet, error) {
 	// Execute the query and store the result (a single row at most) in a *sql.Row type
-	result := m.DB.QueryRow(query, id)
+	result := m.DB.QueryRowContext(ctx, query, args...)
 
 	var s Snippet
 
 	// Copy the result into a Snippet struct and check for errors
-	err := result.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := result.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Hidden, &s.Created, &s.Updated, &s.Expires)
 	if err != nil {
-		// Check if Scan didn't return any rows
-		// If so, returns an empty Snippet struct and the custom ErrNoRecord error
-		// Otherwise, returns an empty Snippet struct with the received error
-		if errors.Is(err, sql.ErrNoRows) {
-			return Snippet{}, ErrNoRecord
-		} else {
-			return Snippet{}, err
-		}
+		// Returns an empty Snippet struct with the custom ErrNoRecord error if
+		// Scan didn't return any rows, or with the received error otherwise.
+		return Snippet{}, sqliteError(err)
 	}
 
 	// If Scan ended with no errors, return the filled Snippet struct
@@ -79,11 +157,133 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 }
 
 // Latest is a method used to get the latest 10 valid snippets.
-func (m *SnippetModel) Latest() ([]Snippet, error) {
-	query := `SELECT id, title, content, created, expires FROM snippets
-			  WHERE expires > datetime() ORDER BY id DESC LIMIT 10`
+func (m *SnippetModel) Latest(ctx context.Context) ([]Snippet, error) {
+	return m.LatestBy(ctx, SnippetFilter{})
+}
+
+// LatestBy is a method used to get the latest 10 valid snippets matching a
+// filter. The status of the filter is ignored, as only the live snippets are
+// valid.
+func (m *SnippetModel) LatestBy(ctx context.Context, filter SnippetFilter) ([]Snippet, error) {
+	filter.Status = SnippetStatusLive
+	conditions, args := filter.where()
+	conditions = append(conditions, "NOT hidden")
+
+	query := "SELECT " + snippetColumns + " FROM snippets WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY id DESC LIMIT 10"
+
+	return m.query(ctx, query, args...)
+}
+
+// List is a method used to get the latest 100 snippets matching a filter,
+// including the hidden and the expired ones unless the filter says otherwise.
+func (m *SnippetModel) List(ctx context.Context, filter SnippetFilter) ([]Snippet, error) {
+	conditions, args := filter.where()
+
+	query := "SELECT " + snippetColumns + " FROM snippets"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT 100"
+
+	return m.query(ctx, query, args...)
+}
+
+// Page is a method used to get a page of the valid snippets, newest first,
+// along with the total number of valid snippets. Pages are numbered from 1.
+func (m *SnippetModel) Page(ctx context.Context, page int, pageSize int) ([]Snippet, int, error) {
+	var total int
+
+	query := "SELECT count(*) FROM snippets WHERE expires > datetime() AND NOT hidden"
 
-	results, err := m.DB.Query(query)
+	err := m.DB.QueryRowContext(ctx, query).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query = "SELECT " + snippetColumns + ` FROM snippets
+			 WHERE expires > datetime() AND NOT hidden ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	snippets, err := m.query(ctx, query, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return snippets, total, nil
+}
+
+// Update is a method used to change the title, the content and the language
+// of a snippet, which then expires the given number of days from now. It
+// returns ErrNoRecord if no such snippet exists.
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, language string, expires int) error {
+	// See Insert about the modifier of datetime().
+	query := `UPDATE snippets SET title = ?, content = ?, language = ?, updated = datetime(), expires = datetime('now','+` + strconv.Itoa(expires) + ` days')
+			  WHERE id = ?`
+
+	result, err := m.DB.ExecContext(ctx, query, title, content, language, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// SetHidden hides a snippet pending review, or makes it visible again. It
+// returns ErrNoRecord if no such snippet exists.
+func (m *SnippetModel) SetHidden(ctx context.Context, id int, hidden bool) error {
+	query := "UPDATE snippets SET hidden = ? WHERE id = ?"
+
+	result, err := m.DB.ExecContext(ctx, query, hidden, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Delete is a method used to delete a snippet, even if it hasn't expired yet.
+// It returns ErrNoRecord if no such snippet exists.
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	query := "DELETE FROM snippets WHERE id = ?"
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// query runs a query returning snippets and collects them.
+func (m *SnippetModel) query(ctx context.Context, query string, args ...any) ([]Snippet, error) {
+	results, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -95,7 +295,7 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 
 	for results.Next() {
 		var s Snippet
-		err := results.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err := results.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Language, &s.Hidden, &s.Created, &s.Updated, &s.Expires)
 		if err != nil {
 			return nil, err
 		}
@@ -110,3 +310,10 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 	// If everything went OK, return the snippets slice.
 	return snippets, nil
 }
+
+// likePattern returns a LIKE pattern matching the strings which contain s,
+// escaping the wildcards in s with a backslash.
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
+}
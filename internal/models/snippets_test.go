@@ -0,0 +1,252 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+// snippetIDs returns the IDs of the snippets, in order.
+func snippetIDs(snippets []models.Snippet) []int {
+	var ids []int
+	for _, s := range snippets {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// equalIDs asserts that the snippets have the expected IDs, in order.
+func equalIDs(t *testing.T, snippets []models.Snippet, want ...int) {
+	t.Helper()
+
+	got := snippetIDs(snippets)
+	assert.Equal(t, len(got), len(want))
+	for i := range min(len(got), len(want)) {
+		assert.Equal(t, got[i], want[i])
+	}
+}
+
+func TestSnippetModelGet(t *testing.T) {
+	m := models.SnippetModel{DB: newTestDB(t)}
+
+	tests := []struct {
+		name       string
+		id         int
+		wantTitle  string
+		wantUserID int
+		wantErr    error
+	}{
+		{name: "Live", id: 1, wantTitle: "First snippet", wantUserID: 1},
+		{name: "Without author", id: 4, wantTitle: "Anonymous snippet"},
+		{name: "Expired", id: 2, wantErr: models.ErrNoRecord},
+		{name: "Hidden", id: 3, wantErr: models.ErrNoRecord},
+		{name: "Non-existent", id: 99, wantErr: models.ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := m.Get(context.Background(), tt.id)
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+			assert.Equal(t, s.Title, tt.wantTitle)
+			assert.Equal(t, s.UserID, tt.wantUserID)
+		})
+	}
+
+	t.Run("Fields", func(t *testing.T) {
+		s, err := m.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s.ID, 1)
+		assert.Equal(t, s.Content, `fmt.Println("first")`)
+		assert.Equal(t, s.Language, "Go")
+		assert.Equal(t, s.Hidden, false)
+		assert.Equal(t, s.Created.Equal(time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)), true)
+		assert.Equal(t, s.Expires.After(time.Now()), true)
+	})
+}
+
+func TestSnippetModelGetAny(t *testing.T) {
+	m := models.SnippetModel{DB: newTestDB(t)}
+
+	// The expired and the hidden snippets are returned too.
+	for _, id := range []int{1, 2, 3} {
+		s, err := m.GetAny(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s.ID, id)
+	}
+
+	s, err := m.GetAny(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Hidden, true)
+
+	_, err = m.GetAny(context.Background(), 99)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	m := models.SnippetModel{DB: newTestDB(t)}
+
+	// The live snippets, newest first, without the expired and the hidden
+	// ones.
+	snippets, err := m.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalIDs(t, snippets, 5, 4, 1)
+
+	// At most 10 snippets are returned.
+	for range 12 {
+		_, err := m.Insert(context.Background(), 1, "Title", "Content", "", 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snippets, err = m.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalIDs(t, snippets, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8)
+}
+
+func TestSnippetModelLatestBy(t *testing.T) {
+	m := models.SnippetModel{DB: newTestDB(t)}
+
+	tests := []struct {
+		name   string
+		filter models.SnippetFilter
+		want   []int
+	}{
+		{name: "User", filter: models.SnippetFilter{UserID: 1}, want: []int{1}},
+		{name: "User with an expired snippet", filter: models.SnippetFilter{UserID: 2}, want: []int{5}},
+		{name: "Language", filter: models.SnippetFilter{Language: "python"}, want: []int{5}},
+		{name: "Expired status ignored", filter: models.SnippetFilter{Status: models.SnippetStatusExpired}, want: []int{5, 4, 1}},
+		{name: "No match", filter: models.SnippetFilter{UserID: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := m.LatestBy(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			equalIDs(t, snippets, tt.want...)
+		})
+	}
+}
+
+func TestSnippetModelList(t *testing.T) {
+	m := models.SnippetModel{DB: newTestDB(t)}
+
+	tests := []struct {
+		name   string
+		filter models.SnippetFilter
+		want   []int
+	}{
+		{name: "All", filter: models.SnippetFilter{}, want: []int{5, 4, 3, 2, 1}},
+		{name: "Live", filter: models.SnippetFilter{Status: models.SnippetStatusLive}, want: []int{5, 4, 3, 1}},
+		{name: "Expired", filter: models.SnippetFilter{Status: models.SnippetStatusExpired}, want: []int{2}},
+		{name: "Search", filter: models.SnippetFilter{Search: "PRINT"}, want: []int{5, 2, 1}},
+		{name: "User and status", filter: models.SnippetFilter{UserID: 2, Status: models.SnippetStatusExpired}, want: []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := m.List(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			equalIDs(t, snippets, tt.want...)
+		})
+	}
+}
+
+func TestSnippetModelPage(t *testing.T) {
+	m := models.SnippetModel{DB: newTestDB(t)}
+
+	tests := []struct {
+		name     string
+		page     int
+		pageSize int
+		want     []int
+	}{
+		{name: "First page", page: 1, pageSize: 2, want: []int{5, 4}},
+		{name: "Last page", page: 2, pageSize: 2, want: []int{1}},
+		{name: "Past the last page", page: 3, pageSize: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, total, err := m.Page(context.Background(), tt.page, tt.pageSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, total, 3)
+			equalIDs(t, snippets, tt.want...)
+		})
+	}
+}
+
+func TestSnippetModelInsert(t *testing.T) {
+	m := models.SnippetModel{DB: newTestDB(t)}
+
+	id, err := m.Insert(context.Background(), 2, "New snippet", "Content", "Rust", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, id, 6)
+
+	s, err := m.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.UserID, 2)
+	assert.Equal(t, s.Language, "Rust")
+	assert.Equal(t, s.Updated.Equal(s.Created), true)
+
+	// The snippet expires in 7 days, to the second.
+	expires := s.Expires.Sub(s.Created)
+	assert.Equal(t, expires, 7*24*time.Hour)
+
+	// A snippet of an unknown user violates the foreign key of its author.
+	_, err = m.Insert(context.Background(), 99, "Orphan snippet", "Content", "", 7)
+	assert.Equal(t, errors.Is(err, models.ErrForeignKeyViolation), true)
+
+	// A snippet expiring in 0 days has expired already.
+	id, err = m.Insert(context.Background(), 2, "Ephemeral snippet", "Content", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Get(context.Background(), id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func TestSnippetModelUpdate(t *testing.T) {
+	m := models.SnippetModel{DB: newTestDB(t)}
+
+	// Updating an expired snippet brings it back to life.
+	err := m.Update(context.Background(), 2, "Renewed snippet", "print(\"renewed\")", "Python", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Title, "Renewed snippet")
+	assert.Equal(t, s.Created.Equal(time.Date(2024, 2, 2, 9, 0, 0, 0, time.UTC)), true)
+	assert.Equal(t, s.Updated.After(s.Created), true)
+
+	err = m.Update(context.Background(), 99, "Title", "Content", "", 1)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DailyCount is a struct containing a count for a day, formatted as YYYY-MM-DD.
+type DailyCount struct {
+	Day   string
+	Count int
+}
+
+// Stats is a struct containing the basic counts about the use of the application.
+type Stats struct {
+	Users           int
+	LiveSnippets    int
+	ExpiredSnippets int
+	Signups         []DailyCount
+}
+
+// StatsModelInterface interface.
+type StatsModelInterface interface {
+	Get(days int) (Stats, error)
+}
+
+// StatsModel is a struct used to call DB operations.
+type StatsModel struct {
+	DB *sql.DB
+}
+
+// Get is a method used to get the counts of users and snippets, together with
+// the signups per day over the last days, most recent first. The days without
+// signups are omitted.
+func (m *StatsModel) Get(days int) (Stats, error) {
+	var s Stats
+
+	query := `SELECT (SELECT COUNT(*) FROM users),
+			  (SELECT COUNT(*) FROM snippets WHERE expires > datetime()),
+			  (SELECT COUNT(*) FROM snippets WHERE expires <= datetime())`
+
+	err := m.DB.QueryRow(query).Scan(&s.Users, &s.LiveSnippets, &s.ExpiredSnippets)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	query = `SELECT date(created) AS day, COUNT(*) FROM users
+			 WHERE created > datetime('now', 'start of day', ?)
+			 GROUP BY day ORDER BY day DESC`
+
+	results, err := m.DB.Query(query, modifier(time.Duration(days-1)*24*time.Hour))
+	if err != nil {
+		return Stats{}, err
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var c DailyCount
+		err := results.Scan(&c.Day, &c.Count)
+		if err != nil {
+			return Stats{}, err
+		}
+		s.Signups = append(s.Signups, c)
+	}
+
+	if err = results.Err(); err != nil {
+		return Stats{}, err
+	}
+
+	return s, nil
+}
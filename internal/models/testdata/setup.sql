@@ -0,0 +1,16 @@
+-- Fixtures of the integration tests of the models. The password of every user
+-- is "password", hashed with the minimum bcrypt cost to keep the tests fast.
+
+INSERT INTO users (id, name, email, hashed_password, role, suspended, created) VALUES
+	(1, 'Alice Jones', 'alice@example.com', '$2a$04$CEFVEvyqLnxHZr4k.7lK7.ONkWQ/hCKVjfZg.Gt5wHA7n7JwNc1D2', 'user', FALSE, '2024-01-01 10:00:00'),
+	(2, 'Bob Smith', 'bob@example.com', '$2a$04$CEFVEvyqLnxHZr4k.7lK7.ONkWQ/hCKVjfZg.Gt5wHA7n7JwNc1D2', 'admin', FALSE, '2024-01-02 10:00:00'),
+	(3, 'Carol White', 'carol@example.com', '$2a$04$CEFVEvyqLnxHZr4k.7lK7.ONkWQ/hCKVjfZg.Gt5wHA7n7JwNc1D2', 'user', TRUE, '2024-01-03 10:00:00');
+
+-- Snippets 1, 4 and 5 are live, 2 has expired and 3 is hidden. Snippet 4 was
+-- created before the authors were recorded.
+INSERT INTO snippets (id, user_id, title, content, language, hidden, created, updated, expires) VALUES
+	(1, 1, 'First snippet', 'fmt.Println("first")', 'Go', FALSE, '2024-02-01 09:00:00', '2024-02-01 09:00:00', datetime('now', '+1 year')),
+	(2, 2, 'Expired snippet', 'print("expired")', 'Python', FALSE, '2024-02-02 09:00:00', '2024-02-02 09:00:00', '2024-02-09 09:00:00'),
+	(3, 1, 'Hidden snippet', 'Reported content', '', TRUE, '2024-02-03 09:00:00', '2024-02-03 09:00:00', datetime('now', '+1 year')),
+	(4, NULL, 'Anonymous snippet', 'An old silent pond...', '', FALSE, '2024-02-04 09:00:00', '2024-02-04 09:00:00', datetime('now', '+1 year')),
+	(5, 2, 'Latest snippet', 'print("latest")', 'Python', FALSE, '2024-02-05 09:00:00', '2024-02-05 09:00:00', datetime('now', '+1 day'));
@@ -0,0 +1,62 @@
+package models_test
+
+import (
+	"database/sql"
+	_ "embed"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/migrate"
+	"github.com/AlessioPani/go-snippetbox/migrations"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// setupSQL contains the fixtures of the integration tests.
+//
+//go:embed testdata/setup.sql
+var setupSQL string
+
+// openTestDB opens a temporary SQLite database, with the migrations applied
+// and no data. It is closed at the end of the test.
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "snippetbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	files, err := migrations.Dialect("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := migrate.New(db, migrate.SQLite, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// newTestDB opens a temporary SQLite database seeded with the fixtures of
+// testdata/setup.sql. The integration tests are skipped in short mode.
+func newTestDB(t *testing.T) *sql.DB {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db := openTestDB(t)
+
+	_, err := db.Exec(setupSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
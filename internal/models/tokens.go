@@ -0,0 +1,213 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scopes of the API tokens, which limit what a token can be used for.
+const (
+	ScopeSnippetsRead  = "snippets:read"
+	ScopeSnippetsWrite = "snippets:write"
+)
+
+// Scopes lists all the scopes an API token can be granted.
+var Scopes = []string{ScopeSnippetsRead, ScopeSnippetsWrite}
+
+// TokenPrefix is the prefix of every API token, which makes them easy to
+// recognize, e.g. by secret scanners.
+const TokenPrefix = "sbx_"
+
+// TokenUseInterval is how often the last used time of an API token is
+// updated. Tokens are checked on every API request, but recording each use
+// would turn every request into a write.
+const TokenUseInterval = time.Minute
+
+// Token is a struct containing the data of an API token of a user. Only the
+// hash of the token is stored, so the token itself can't be retrieved.
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string
+	Scopes   []string
+	Created  time.Time
+	Expires  sql.NullTime
+	LastUsed sql.NullTime
+}
+
+// HasScope reports whether the token has been granted a scope.
+func (t Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Used reports whether the token has been used within the last interval d.
+func (t Token) Used(d time.Duration) bool {
+	return t.LastUsed.Valid && time.Since(t.LastUsed.Time) < d
+}
+
+// TokenModelInterface interface.
+type TokenModelInterface interface {
+	Insert(userID int, name string, scopes []string, expiresIn int) (string, error)
+	Authenticate(token string) (Token, error)
+	GetByUser(userID int) ([]Token, error)
+	Delete(id, userID int) error
+}
+
+// TokenModel is a struct used to call DB operations.
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// Insert creates a new API token for a user and returns it. The token expires
+// in expiresIn days, or never if expiresIn is 0.
+func (m *TokenModel) Insert(userID int, name string, scopes []string, expiresIn int) (string, error) {
+	token, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	// As for the snippets, the modifier is built with strconv.Itoa. A NULL
+	// expiry means that the token never expires.
+	expires := "NULL"
+	if expiresIn > 0 {
+		expires = "datetime('now', '+" + strconv.Itoa(expiresIn) + " days')"
+	}
+
+	query := `INSERT INTO api_tokens (user_id, name, token_hash, scopes, created, expires)
+			  VALUES(?, ?, ?, ?, datetime(), ` + expires + ")"
+
+	_, err = m.DB.Exec(query, userID, name, HashToken(token), strings.Join(scopes, " "))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Authenticate is used to get the API token matching a token sent by a
+// client, updating its last used time if it's older than TokenUseInterval.
+// It returns ErrInvalidCredentials if the token doesn't exist, has been
+// revoked or has expired.
+func (m *TokenModel) Authenticate(token string) (Token, error) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return Token{}, ErrInvalidCredentials
+	}
+
+	query := `SELECT id, user_id, name, scopes, created, expires, last_used FROM api_tokens
+			  WHERE token_hash = ? AND (expires IS NULL OR expires > datetime())`
+
+	t, err := scanToken(m.DB.QueryRow(query, HashToken(token)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Token{}, ErrInvalidCredentials
+		} else {
+			return Token{}, err
+		}
+	}
+
+	if !t.Used(TokenUseInterval) {
+		query = "UPDATE api_tokens SET last_used = datetime() WHERE id = ?"
+
+		_, err = m.DB.Exec(query, t.ID)
+		if err != nil {
+			return Token{}, err
+		}
+	}
+
+	return t, nil
+}
+
+// GetByUser is a method used to get the API tokens of a user, including the
+// expired ones, the most recent first.
+func (m *TokenModel) GetByUser(userID int) ([]Token, error) {
+	query := `SELECT id, user_id, name, scopes, created, expires, last_used FROM api_tokens
+			  WHERE user_id = ? ORDER BY id DESC`
+
+	results, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var tokens []Token
+
+	for results.Next() {
+		t, err := scanToken(results)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes an API token of a user. It returns ErrNoRecord if no such
+// token exists.
+func (m *TokenModel) Delete(id, userID int) error {
+	query := "DELETE FROM api_tokens WHERE id = ? AND user_id = ?"
+
+	result, err := m.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// scanToken copies a row of the api_tokens table into a Token.
+func scanToken(row interface{ Scan(...any) error }) (Token, error) {
+	var t Token
+	var scopes string
+
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created, &t.Expires, &t.LastUsed)
+	if err != nil {
+		return Token{}, err
+	}
+	t.Scopes = strings.Fields(scopes)
+
+	return t, nil
+}
+
+// NewToken returns a new random API token, with the TokenPrefix.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return TokenPrefix + hex.EncodeToString(b), nil
+}
+
+// HashToken returns the hash of a token stored in the DB. Tokens are long
+// random strings, so a fast hash is as safe as bcrypt for them.
+func HashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
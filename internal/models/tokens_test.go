@@ -0,0 +1,37 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func TestTokenModelAuthenticate(t *testing.T) {
+	db := newTestDB(t)
+	m := models.TokenModel{DB: db}
+
+	token, err := m.Insert(1, "CI", []string{models.ScopeSnippetsRead}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend that the token was last used before the use interval.
+	_, err = db.Exec("UPDATE api_tokens SET last_used = datetime('now', '-2 minutes')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tok, err := m.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tok.Used(models.TokenUseInterval), false)
+
+	// The use has been recorded this time.
+	tok, err = m.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tok.Used(models.TokenUseInterval), true)
+}
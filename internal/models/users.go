@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
@@ -8,21 +9,51 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Role is the role of a user, which grants access to parts of the application.
+type Role string
+
+// Roles of the users. Every user has the user role when signing up.
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Roles lists all the roles, from the least to the most privileged.
+var Roles = []Role{RoleUser, RoleModerator, RoleAdmin}
+
 // User is a struct containing the user data.
 type User struct {
 	ID             int
 	Name           string
 	Email          string
 	HashedPassword []byte
+	Role           Role
+	Suspended      bool
 	Created        time.Time
 }
 
+// HasRole reports whether the user has one of the roles.
+func (u User) HasRole(roles ...Role) bool {
+	for _, role := range roles {
+		if u.Role == role {
+			return true
+		}
+	}
+
+	return false
+}
+
 // UserModelInterface interface.
 type UserModelInterface interface {
-	Insert(name, email, password string) error
-	Authenticate(email, password string) (int, error)
-	Exists(id int) (bool, error)
-	EmailTaken(email string) (bool, error)
+	Insert(ctx context.Context, name, email, password string) error
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	Get(ctx context.Context, id int) (User, error)
+	PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error
+	List(ctx context.Context, search string) ([]User, error)
+	SetSuspended(ctx context.Context, id int, suspended bool) error
+	SetRole(ctx context.Context, id int, role Role) error
 }
 
 // UserModel is a struct used to call DB operations.
@@ -30,8 +61,10 @@ type UserModel struct {
 	DB *sql.DB
 }
 
-// Insert adds a new record to the Users table.
-func (m *UserModel) Insert(name, email, password string) error {
+// Insert adds a new record to the Users table. It returns ErrDuplicateEmail
+// if the email address is already in use, which the unique constraint on the
+// email column checks atomically, even for concurrent signups.
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
 	// Create a bcrypt hash of the plain-text password.
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
@@ -41,10 +74,11 @@ func (m *UserModel) Insert(name, email, password string) error {
 	query := `INSERT INTO users (name, email, hashed_password, created)
  			 VALUES(?, ?, ?, datetime())`
 
-	// Execute the query, populating the placeholders. If errors were found, return it
-	_, err = m.DB.Exec(query, name, email, string(hashedPassword))
+	// Execute the query, populating the placeholders. If errors were found,
+	// return the one of the models they stand for.
+	_, err = m.DB.ExecContext(ctx, query, name, email, string(hashedPassword))
 	if err != nil {
-		return err
+		return sqliteError(err)
 	}
 
 	return nil
@@ -52,13 +86,13 @@ func (m *UserModel) Insert(name, email, password string) error {
 
 // Authenticate is used to verify whether a user exists with the provided email address and password.
 // This will return the relevant user ID if they do.
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
 	var id int
 	var hashedPassword []byte
 
 	query := "SELECT id, hashed_password FROM users WHERE email = ?"
 
-	err := m.DB.QueryRow(query, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(&id, &hashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -82,14 +116,14 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	return id, nil
 }
 
-// EmailTaken is used to check if a mail exists already.
-func (m *UserModel) EmailTaken(email string) (bool, error) {
+// Exists is used to check if a user exists with a specific ID.
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
 	var exists bool
 
-	query := "SELECT EXISTS(SELECT true FROM users WHERE email = ?)"
+	query := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
 
 	// This query is expected to get 1 row at most.
-	result := m.DB.QueryRow(query, email)
+	result := m.DB.QueryRowContext(ctx, query, id)
 
 	// Copy the result into the boolean variable.
 	// We need to check only if a row was returned, and the query will return
@@ -99,19 +133,120 @@ func (m *UserModel) EmailTaken(email string) (bool, error) {
 	return exists, err
 }
 
-// Exists is used to check if a user exists with a specific ID.
-func (m *UserModel) Exists(id int) (bool, error) {
-	var exists bool
+// Get is a method used to get a user based on its ID.
+func (m *UserModel) Get(ctx context.Context, id int) (User, error) {
+	var u User
 
-	query := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+	query := "SELECT id, name, email, role, suspended, created FROM users WHERE id = ?"
 
-	// This query is expected to get 1 row at most.
-	result := m.DB.QueryRow(query, id)
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.Suspended, &u.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNoRecord
+		} else {
+			return User{}, err
+		}
+	}
 
-	// Copy the result into the boolean variable.
-	// We need to check only if a row was returned, and the query will return
-	// a 1 (true) if at least one row was returned.
-	err := result.Scan(&exists)
+	return u, nil
+}
 
-	return exists, err
+// PasswordUpdate changes the password of a user, after checking that the
+// current password provided is correct. If it isn't, ErrInvalidCredentials is
+// returned.
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
+	var currentHashedPassword []byte
+
+	query := "SELECT hashed_password FROM users WHERE id = ?"
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&currentHashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	query = "UPDATE users SET hashed_password = ? WHERE id = ?"
+
+	_, err = m.DB.ExecContext(ctx, query, string(newHashedPassword), id)
+	return err
+}
+
+// List is a method used to get the latest 100 users whose name or email
+// address contains search, which can be empty to match every user.
+func (m *UserModel) List(ctx context.Context, search string) ([]User, error) {
+	query := `SELECT id, name, email, role, suspended, created FROM users
+			  WHERE name LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\'
+			  ORDER BY id DESC LIMIT 100`
+
+	results, err := m.DB.QueryContext(ctx, query, likePattern(search), likePattern(search))
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var users []User
+
+	for results.Next() {
+		var u User
+		err := results.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.Suspended, &u.Created)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// SetSuspended suspends or reinstates a user. It returns ErrNoRecord if no
+// such user exists.
+func (m *UserModel) SetSuspended(ctx context.Context, id int, suspended bool) error {
+	return m.update(ctx, "UPDATE users SET suspended = ? WHERE id = ?", suspended, id)
+}
+
+// SetRole changes the role of a user. It returns ErrNoRecord if no such user
+// exists.
+func (m *UserModel) SetRole(ctx context.Context, id int, role Role) error {
+	return m.update(ctx, "UPDATE users SET role = ? WHERE id = ?", string(role), id)
+}
+
+// update runs a query updating a single user, returning ErrNoRecord if no
+// user has been updated.
+func (m *UserModel) update(ctx context.Context, query string, args ...any) error {
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
 }
@@ -0,0 +1,167 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/models"
+)
+
+func TestUserModelInsert(t *testing.T) {
+	m := models.UserModel{DB: newTestDB(t)}
+
+	tests := []struct {
+		name    string
+		email   string
+		wantErr error
+	}{
+		{name: "New email", email: "dave@example.com"},
+		{name: "Duplicate email", email: "alice@example.com", wantErr: models.ErrDuplicateEmail},
+		{name: "Email of a suspended user", email: "carol@example.com", wantErr: models.ErrDuplicateEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Insert(context.Background(), "Test User", tt.email, "password")
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+		})
+	}
+
+	// The duplicate hasn't replaced the existing user.
+	u, err := m.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, u.Name, "Alice Jones")
+
+	// The new user has the user role.
+	users, err := m.List(context.Background(), "dave@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(users), 1)
+	assert.Equal(t, users[0].ID, 4)
+	assert.Equal(t, users[0].Role, models.RoleUser)
+}
+
+func TestUserModelAuthenticate(t *testing.T) {
+	m := models.UserModel{DB: newTestDB(t)}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantID   int
+		wantErr  error
+	}{
+		{name: "Valid credentials", email: "alice@example.com", password: "password", wantID: 1},
+		{name: "Wrong password", email: "alice@example.com", password: "wrong", wantErr: models.ErrInvalidCredentials},
+		{name: "Empty password", email: "alice@example.com", password: "", wantErr: models.ErrInvalidCredentials},
+		{name: "Password of another user", email: "bob@example.com", password: "wrong", wantErr: models.ErrInvalidCredentials},
+		{name: "Unknown email", email: "nobody@example.com", password: "password", wantErr: models.ErrInvalidCredentials},
+		{name: "Empty email", email: "", password: "password", wantErr: models.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := m.Authenticate(context.Background(), tt.email, tt.password)
+			assert.Equal(t, id, tt.wantID)
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+		})
+	}
+}
+
+func TestUserModelGet(t *testing.T) {
+	m := models.UserModel{DB: newTestDB(t)}
+
+	tests := []struct {
+		name          string
+		id            int
+		wantEmail     string
+		wantRole      models.Role
+		wantSuspended bool
+		wantErr       error
+	}{
+		{name: "User", id: 1, wantEmail: "alice@example.com", wantRole: models.RoleUser},
+		{name: "Admin", id: 2, wantEmail: "bob@example.com", wantRole: models.RoleAdmin},
+		{name: "Suspended", id: 3, wantEmail: "carol@example.com", wantRole: models.RoleUser, wantSuspended: true},
+		{name: "Non-existent", id: 99, wantErr: models.ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := m.Get(context.Background(), tt.id)
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+			assert.Equal(t, u.Email, tt.wantEmail)
+			assert.Equal(t, u.Role, tt.wantRole)
+			assert.Equal(t, u.Suspended, tt.wantSuspended)
+		})
+	}
+
+	t.Run("Created", func(t *testing.T) {
+		u, err := m.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, u.Created.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)), true)
+	})
+}
+
+func TestUserModelExists(t *testing.T) {
+	m := models.UserModel{DB: newTestDB(t)}
+
+	tests := []struct {
+		name string
+		id   int
+		want bool
+	}{
+		{name: "Valid ID", id: 1, want: true},
+		{name: "Suspended user", id: 3, want: true},
+		{name: "Zero ID", id: 0, want: false},
+		{name: "Non-existent ID", id: 99, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exists, err := m.Exists(context.Background(), tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, exists, tt.want)
+		})
+	}
+}
+
+func TestUserModelPasswordUpdate(t *testing.T) {
+	m := models.UserModel{DB: newTestDB(t)}
+
+	err := m.PasswordUpdate(context.Background(), 1, "wrong", "new password")
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+	err = m.PasswordUpdate(context.Background(), 99, "password", "new password")
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	err = m.PasswordUpdate(context.Background(), 1, "password", "new password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Authenticate(context.Background(), "alice@example.com", "password")
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+	id, err := m.Authenticate(context.Background(), "alice@example.com", "new password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, id, 1)
+
+	// The other users keep their password.
+	id, err = m.Authenticate(context.Background(), "bob@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, id, 2)
+}
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Events of the snippets which are sent to the webhooks of their author.
+const (
+	EventSnippetCreated = "snippet.created"
+	EventSnippetUpdated = "snippet.updated"
+	EventSnippetDeleted = "snippet.deleted"
+)
+
+// Statuses of the webhook deliveries.
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+)
+
+// Webhook is a struct containing the data of a webhook of a user. Its secret,
+// used to sign the payloads, can't be retrieved once registered.
+type Webhook struct {
+	ID      int
+	UserID  int
+	URL     string
+	Created time.Time
+}
+
+// Delivery is a struct containing the data of the delivery of an event to a
+// webhook. The deliveries make up a persistent outbox: they are pending until
+// they are delivered, or they fail for good after too many attempts.
+// ResponseCode and Error are the result of the last attempt, if any. Secret
+// is only set on the claimed deliveries.
+type Delivery struct {
+	ID           int
+	WebhookID    int
+	URL          string
+	Secret       string
+	Event        string
+	Payload      []byte
+	Status       string
+	Attempts     int
+	ResponseCode int
+	Error        string
+	Created      time.Time
+	Delivered    sql.NullTime
+}
+
+// WebhookModelInterface interface.
+type WebhookModelInterface interface {
+	Insert(userID int, url string, secret string) (int, error)
+	GetByUser(userID int) ([]Webhook, error)
+	Delete(id, userID int) error
+	Enqueue(userID int, event string, payload []byte) error
+	Claim(lease time.Duration) (Delivery, error)
+	Delivered(id int, responseCode int) error
+	Retry(id int, responseCode int, deliveryErr string, retryIn time.Duration) error
+	Fail(id int, responseCode int, deliveryErr string) error
+	Deliveries(userID int, limit int) ([]Delivery, error)
+}
+
+// WebhookModel is a struct used to call DB operations.
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+// Insert registers a webhook of a user, with the secret used to sign the
+// payloads sent to it.
+func (m *WebhookModel) Insert(userID int, url string, secret string) (int, error) {
+	query := "INSERT INTO webhooks (user_id, url, secret, created) VALUES(?, ?, ?, datetime())"
+
+	result, err := m.DB.Exec(query, userID, url, secret)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetByUser returns the webhooks of a user, oldest first.
+func (m *WebhookModel) GetByUser(userID int) ([]Webhook, error) {
+	query := "SELECT id, user_id, url, created FROM webhooks WHERE user_id = ? ORDER BY id"
+
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+
+	for rows.Next() {
+		var w Webhook
+		err = rows.Scan(&w.ID, &w.UserID, &w.URL, &w.Created)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// Delete removes a webhook of a user, along with its deliveries. It returns
+// ErrNoRecord if the user has no such webhook.
+func (m *WebhookModel) Delete(id, userID int) error {
+	query := "DELETE FROM webhooks WHERE id = ? AND user_id = ?"
+
+	result, err := m.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Enqueue adds a pending delivery of an event to every webhook of a user.
+func (m *WebhookModel) Enqueue(userID int, event string, payload []byte) error {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt, created)
+			  SELECT id, ?, ?, ?, datetime(), datetime() FROM webhooks WHERE user_id = ?`
+
+	_, err := m.DB.Exec(query, event, payload, DeliveryStatusPending, userID)
+	return err
+}
+
+// Claim returns the oldest pending delivery which is due, counting a new
+// attempt for it, or ErrNoRecord if none is due. It is leased for the given
+// duration: if it is still pending by then, because the worker delivering it
+// has stopped, it can be claimed again. The claim is a single update, so
+// concurrent workers never claim the same delivery. A delivery is claimed
+// right before it is sent, so the lease only has to outlast a single attempt.
+func (m *WebhookModel) Claim(lease time.Duration) (Delivery, error) {
+	claim := `UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt = datetime('now', ?)
+			  WHERE id = (
+				  SELECT id FROM webhook_deliveries WHERE status = ? AND next_attempt <= datetime()
+				  ORDER BY next_attempt, id LIMIT 1
+			  )
+			  RETURNING id, webhook_id, event, payload, status, attempts`
+
+	webhook := "SELECT url, secret FROM webhooks WHERE id = ?"
+
+	for {
+		var d Delivery
+		err := m.DB.QueryRow(claim, later(lease), DeliveryStatusPending).Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts)
+		if err != nil {
+			return Delivery{}, sqliteError(err)
+		}
+
+		// Add the URL and the secret of its webhook. The deliveries of a
+		// webhook deleted meanwhile have been deleted too, so the next one is
+		// claimed instead.
+		err = m.DB.QueryRow(webhook, d.WebhookID).Scan(&d.URL, &d.Secret)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return Delivery{}, err
+		}
+
+		return d, nil
+	}
+}
+
+// Delivered records the successful attempt of a delivery.
+func (m *WebhookModel) Delivered(id int, responseCode int) error {
+	query := `UPDATE webhook_deliveries SET status = ?, response_code = ?, error = '', delivered = datetime()
+			  WHERE id = ?`
+
+	return m.update(query, DeliveryStatusDelivered, responseCode, id)
+}
+
+// Retry records the failed attempt of a delivery, which is attempted again
+// in retryIn. The response code is 0 if no response has been received.
+func (m *WebhookModel) Retry(id int, responseCode int, deliveryErr string, retryIn time.Duration) error {
+	query := `UPDATE webhook_deliveries SET status = ?, response_code = ?, error = ?, next_attempt = datetime('now', ?)
+			  WHERE id = ?`
+
+	return m.update(query, DeliveryStatusPending, responseCode, deliveryErr, later(retryIn), id)
+}
+
+// Fail records the last failed attempt of a delivery, which isn't attempted
+// anymore.
+func (m *WebhookModel) Fail(id int, responseCode int, deliveryErr string) error {
+	query := "UPDATE webhook_deliveries SET status = ?, response_code = ?, error = ? WHERE id = ?"
+
+	return m.update(query, DeliveryStatusFailed, responseCode, deliveryErr, id)
+}
+
+// update runs a query updating a delivery. It returns ErrNoRecord if no such
+// delivery exists.
+func (m *WebhookModel) update(query string, args ...any) error {
+	result, err := m.DB.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Deliveries returns the latest deliveries to the webhooks of a user, newest
+// first, without their payload.
+func (m *WebhookModel) Deliveries(userID int, limit int) ([]Delivery, error) {
+	query := `SELECT d.id, d.webhook_id, w.url, d.event, d.status, d.attempts, d.response_code, d.error, d.created, d.delivered
+			  FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+			  WHERE w.user_id = ? ORDER BY d.id DESC LIMIT ?`
+
+	rows, err := m.DB.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+
+	for rows.Next() {
+		var d Delivery
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Event, &d.Status, &d.Attempts, &d.ResponseCode, &d.Error, &d.Created, &d.Delivered)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// later returns a datetime() modifier which goes forward in time by d.
+func later(d time.Duration) string {
+	return fmt.Sprintf("+%d seconds", int(d.Seconds()))
+}
@@ -0,0 +1,173 @@
+// Package postgresstore provides a session store for the scs session manager
+// backed by a sessions table in a PostgreSQL database opened with the pgx
+// driver. The table is expected to be created by the application:
+//
+//	CREATE TABLE sessions (
+//		token TEXT PRIMARY KEY,
+//		data BYTEA NOT NULL,
+//		expiry BIGINT NOT NULL
+//	);
+//	CREATE INDEX idx_sessions_expiry ON sessions (expiry);
+//
+// The expiry is stored as a Unix time in milliseconds, as by the sqlitestore
+// package, so that both databases have the same schema.
+//
+// The store is derived from the postgresstore package of scs
+// (github.com/alexedwards/scs/postgresstore), copyright (c) 2016 Alex Edwards
+// and distributed under the MIT license found in the LICENSE file of this
+// directory. It differs from it by the storage of the expiry and by logging
+// the errors of the cleanup with slog.
+package postgresstore
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// PostgresStore represents the session store.
+type PostgresStore struct {
+	db          *sql.DB
+	logger      *slog.Logger
+	stopCleanup chan bool
+}
+
+// New returns a new PostgresStore instance, with a background cleanup
+// goroutine that runs every 5 minutes to remove expired session data. The
+// errors of the cleanup are logged to logger.
+func New(db *sql.DB, logger *slog.Logger) *PostgresStore {
+	return NewWithCleanupInterval(db, logger, 5*time.Minute)
+}
+
+// NewWithCleanupInterval returns a new PostgresStore instance. The
+// cleanupInterval parameter controls how frequently expired session data is
+// removed by the background cleanup goroutine. Setting it to 0 prevents the
+// cleanup goroutine from running (i.e. expired sessions will not be removed).
+func NewWithCleanupInterval(db *sql.DB, logger *slog.Logger, cleanupInterval time.Duration) *PostgresStore {
+	p := &PostgresStore{db: db, logger: logger}
+
+	if cleanupInterval > 0 {
+		p.stopCleanup = make(chan bool)
+		go p.startCleanup(cleanupInterval)
+	}
+
+	return p
+}
+
+// Find returns the data for a given session token from the PostgresStore
+// instance. If the session token is not found or is expired, the returned
+// exists flag will be set to false.
+func (p *PostgresStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+
+	query := "SELECT data FROM sessions WHERE token = $1 AND expiry > $2"
+
+	err := p.db.QueryRow(query, token, time.Now().UnixMilli()).Scan(&b)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Commit adds a session token and data to the PostgresStore instance with the
+// given expiry time. If the session token already exists, then the data and
+// expiry time are updated.
+func (p *PostgresStore) Commit(token string, b []byte, expiry time.Time) error {
+	query := `INSERT INTO sessions (token, data, expiry) VALUES ($1, $2, $3)
+			  ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data, expiry = EXCLUDED.expiry`
+
+	_, err := p.db.Exec(query, token, b, expiry.UnixMilli())
+	return err
+}
+
+// Delete removes a session token and corresponding data from the
+// PostgresStore instance.
+func (p *PostgresStore) Delete(token string) error {
+	query := "DELETE FROM sessions WHERE token = $1"
+
+	_, err := p.db.Exec(query, token)
+	return err
+}
+
+// All returns a map containing the token and data for all active (i.e.
+// not expired) sessions in the PostgresStore instance.
+func (p *PostgresStore) All() (map[string][]byte, error) {
+	query := "SELECT token, data FROM sessions WHERE expiry > $1"
+
+	rows, err := p.db.Query(query, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make(map[string][]byte)
+
+	for rows.Next() {
+		var (
+			token string
+			data  []byte
+		)
+
+		err = rows.Scan(&token, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions[token] = data
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// StopCleanup terminates the background cleanup goroutine for the
+// PostgresStore instance. It's rare to terminate this; generally
+// PostgresStore instances and their cleanup goroutines are intended to be
+// long-lived and run for the lifetime of your application.
+//
+// There may be occasions though when your use of the PostgresStore is
+// transient. An example is creating a new PostgresStore instance in a test
+// function. In this scenario, the cleanup goroutine (which will run forever)
+// will prevent the PostgresStore object from being garbage collected even
+// after the test function has finished. You can prevent this by manually
+// calling StopCleanup.
+func (p *PostgresStore) StopCleanup() {
+	if p.stopCleanup != nil {
+		p.stopCleanup <- true
+	}
+}
+
+// startCleanup deletes the expired sessions every interval, until StopCleanup
+// is called.
+func (p *PostgresStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := p.deleteExpired()
+			if err != nil {
+				p.logger.Error("cannot delete the expired sessions", slog.String("error", err.Error()))
+			}
+		case <-p.stopCleanup:
+			return
+		}
+	}
+}
+
+// deleteExpired removes the expired sessions from the table.
+func (p *PostgresStore) deleteExpired() error {
+	query := "DELETE FROM sessions WHERE expiry < $1"
+
+	_, err := p.db.Exec(query, time.Now().UnixMilli())
+	return err
+}
@@ -0,0 +1,148 @@
+package postgresstore
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// dsnEnv is the environment variable holding the URL of the PostgreSQL
+// database to run the tests on. The tests are skipped if it isn't set.
+const dsnEnv = "SNIPPETBOX_TEST_POSTGRES_DSN"
+
+// newTestDB opens the test database on a schema of its own containing the
+// sessions table, which is dropped at the end of the test.
+func newTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s isn't set", dsnEnv)
+	}
+
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := "snippetbox_test_" + hex.EncodeToString(b)
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	db, err := sql.Open("pgx", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`
+		CREATE TABLE sessions (
+			token TEXT PRIMARY KEY,
+			data BYTEA NOT NULL,
+			expiry BIGINT NOT NULL
+		);
+		CREATE INDEX idx_sessions_expiry ON sessions (expiry);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestFindCommitDelete(t *testing.T) {
+	p := NewWithCleanupInterval(newTestDB(t), slog.New(slog.NewTextHandler(io.Discard, nil)), 0)
+
+	// A missing token is not an error.
+	_, found, err := p.Find("session_token")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, found, false)
+
+	err = p.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	assert.Equal(t, err, nil)
+
+	b, found, err := p.Find("session_token")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, found, true)
+	assert.Equal(t, string(b), "encoded_data")
+
+	// Committing again overwrites the data.
+	err = p.Commit("session_token", []byte("new_encoded_data"), time.Now().Add(time.Minute))
+	assert.Equal(t, err, nil)
+
+	b, _, _ = p.Find("session_token")
+	assert.Equal(t, string(b), "new_encoded_data")
+
+	all, err := p.All()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(all), 1)
+
+	err = p.Delete("session_token")
+	assert.Equal(t, err, nil)
+
+	_, found, _ = p.Find("session_token")
+	assert.Equal(t, found, false)
+}
+
+func TestExpiry(t *testing.T) {
+	p := NewWithCleanupInterval(newTestDB(t), slog.New(slog.NewTextHandler(io.Discard, nil)), 0)
+
+	err := p.Commit("session_token", []byte("encoded_data"), time.Now().Add(-time.Second))
+	assert.Equal(t, err, nil)
+
+	_, found, err := p.Find("session_token")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, found, false)
+
+	all, err := p.All()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(all), 0)
+}
+
+func TestCleanup(t *testing.T) {
+	db := newTestDB(t)
+	p := NewWithCleanupInterval(db, slog.New(slog.NewTextHandler(io.Discard, nil)), 100*time.Millisecond)
+	defer p.StopCleanup()
+
+	err := p.Commit("expired_token", []byte("encoded_data"), time.Now().Add(50*time.Millisecond))
+	assert.Equal(t, err, nil)
+	err = p.Commit("live_token", []byte("encoded_data"), time.Now().Add(time.Hour))
+	assert.Equal(t, err, nil)
+
+	time.Sleep(300 * time.Millisecond)
+
+	// The expired row has been deleted from the table, not only hidden.
+	var count int
+	err = db.QueryRow("SELECT count(*) FROM sessions").Scan(&count)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, count, 1)
+}
@@ -0,0 +1,170 @@
+// Package sqlitestore provides a session store for the scs session manager
+// backed by a sessions table in a SQLite database opened with the ncruces
+// driver. The table is expected to be created by the application:
+//
+//	CREATE TABLE sessions (
+//		token TEXT PRIMARY KEY,
+//		data BLOB NOT NULL,
+//		expiry INTEGER NOT NULL
+//	);
+//	CREATE INDEX idx_sessions_expiry ON sessions (expiry);
+//
+// The expiry is stored as a Unix time in milliseconds.
+//
+// The store is derived from the sqlite3store package of scs
+// (github.com/alexedwards/scs/sqlite3store), copyright (c) 2016 Alex Edwards
+// and distributed under the MIT license found in the LICENSE file of this
+// directory. It differs from it by the storage of the expiry and by logging
+// the errors of the cleanup with slog.
+package sqlitestore
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// SQLiteStore represents the session store.
+type SQLiteStore struct {
+	db          *sql.DB
+	logger      *slog.Logger
+	stopCleanup chan bool
+}
+
+// New returns a new SQLiteStore instance, with a background cleanup goroutine
+// that runs every 5 minutes to remove expired session data. The errors of the
+// cleanup are logged to logger.
+func New(db *sql.DB, logger *slog.Logger) *SQLiteStore {
+	return NewWithCleanupInterval(db, logger, 5*time.Minute)
+}
+
+// NewWithCleanupInterval returns a new SQLiteStore instance. The cleanupInterval
+// parameter controls how frequently expired session data is removed by the
+// background cleanup goroutine. Setting it to 0 prevents the cleanup goroutine
+// from running (i.e. expired sessions will not be removed).
+func NewWithCleanupInterval(db *sql.DB, logger *slog.Logger, cleanupInterval time.Duration) *SQLiteStore {
+	s := &SQLiteStore{db: db, logger: logger}
+
+	if cleanupInterval > 0 {
+		s.stopCleanup = make(chan bool)
+		go s.startCleanup(cleanupInterval)
+	}
+
+	return s
+}
+
+// Find returns the data for a given session token from the SQLiteStore
+// instance. If the session token is not found or is expired, the returned
+// exists flag will be set to false.
+func (s *SQLiteStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+
+	query := "SELECT data FROM sessions WHERE token = ? AND expiry > ?"
+
+	err := s.db.QueryRow(query, token, time.Now().UnixMilli()).Scan(&b)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Commit adds a session token and data to the SQLiteStore instance with the
+// given expiry time. If the session token already exists, then the data and
+// expiry time are updated.
+func (s *SQLiteStore) Commit(token string, b []byte, expiry time.Time) error {
+	query := "REPLACE INTO sessions (token, data, expiry) VALUES (?, ?, ?)"
+
+	_, err := s.db.Exec(query, token, b, expiry.UnixMilli())
+	return err
+}
+
+// Delete removes a session token and corresponding data from the SQLiteStore
+// instance.
+func (s *SQLiteStore) Delete(token string) error {
+	query := "DELETE FROM sessions WHERE token = ?"
+
+	_, err := s.db.Exec(query, token)
+	return err
+}
+
+// All returns a map containing the token and data for all active (i.e.
+// not expired) sessions in the SQLiteStore instance.
+func (s *SQLiteStore) All() (map[string][]byte, error) {
+	query := "SELECT token, data FROM sessions WHERE expiry > ?"
+
+	rows, err := s.db.Query(query, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make(map[string][]byte)
+
+	for rows.Next() {
+		var (
+			token string
+			data  []byte
+		)
+
+		err = rows.Scan(&token, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions[token] = data
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// StopCleanup terminates the background cleanup goroutine for the SQLiteStore
+// instance. It's rare to terminate this; generally SQLiteStore instances and
+// their cleanup goroutines are intended to be long-lived and run for the
+// lifetime of your application.
+//
+// There may be occasions though when your use of the SQLiteStore is transient.
+// An example is creating a new SQLiteStore instance in a test function. In this
+// scenario, the cleanup goroutine (which will run forever) will prevent the
+// SQLiteStore object from being garbage collected even after the test function
+// has finished. You can prevent this by manually calling StopCleanup.
+func (s *SQLiteStore) StopCleanup() {
+	if s.stopCleanup != nil {
+		s.stopCleanup <- true
+	}
+}
+
+// startCleanup deletes the expired sessions every interval, until StopCleanup
+// is called.
+func (s *SQLiteStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := s.deleteExpired()
+			if err != nil {
+				s.logger.Error("cannot delete the expired sessions", slog.String("error", err.Error()))
+			}
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
+
+// deleteExpired removes the expired sessions from the table.
+func (s *SQLiteStore) deleteExpired() error {
+	query := "DELETE FROM sessions WHERE expiry < ?"
+
+	_, err := s.db.Exec(query, time.Now().UnixMilli())
+	return err
+}
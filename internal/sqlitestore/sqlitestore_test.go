@@ -0,0 +1,104 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// newTestDB opens a temporary database containing the sessions table.
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`
+		CREATE TABLE sessions (
+			token TEXT PRIMARY KEY,
+			data BLOB NOT NULL,
+			expiry INTEGER NOT NULL
+		);
+		CREATE INDEX idx_sessions_expiry ON sessions (expiry);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestFindCommitDelete(t *testing.T) {
+	s := NewWithCleanupInterval(newTestDB(t), slog.New(slog.NewTextHandler(io.Discard, nil)), 0)
+
+	// A missing token is not an error.
+	_, found, err := s.Find("session_token")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, found, false)
+
+	err = s.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	assert.Equal(t, err, nil)
+
+	b, found, err := s.Find("session_token")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, found, true)
+	assert.Equal(t, string(b), "encoded_data")
+
+	// Committing again overwrites the data.
+	err = s.Commit("session_token", []byte("new_encoded_data"), time.Now().Add(time.Minute))
+	assert.Equal(t, err, nil)
+
+	b, _, _ = s.Find("session_token")
+	assert.Equal(t, string(b), "new_encoded_data")
+
+	all, err := s.All()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(all), 1)
+
+	err = s.Delete("session_token")
+	assert.Equal(t, err, nil)
+
+	_, found, _ = s.Find("session_token")
+	assert.Equal(t, found, false)
+}
+
+func TestExpiry(t *testing.T) {
+	s := NewWithCleanupInterval(newTestDB(t), slog.New(slog.NewTextHandler(io.Discard, nil)), 0)
+
+	err := s.Commit("session_token", []byte("encoded_data"), time.Now().Add(-time.Second))
+	assert.Equal(t, err, nil)
+
+	_, found, err := s.Find("session_token")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, found, false)
+
+	all, err := s.All()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(all), 0)
+}
+
+func TestCleanup(t *testing.T) {
+	db := newTestDB(t)
+	s := NewWithCleanupInterval(db, slog.New(slog.NewTextHandler(io.Discard, nil)), 100*time.Millisecond)
+	defer s.StopCleanup()
+
+	err := s.Commit("expired_token", []byte("encoded_data"), time.Now().Add(50*time.Millisecond))
+	assert.Equal(t, err, nil)
+	err = s.Commit("live_token", []byte("encoded_data"), time.Now().Add(time.Hour))
+	assert.Equal(t, err, nil)
+
+	time.Sleep(300 * time.Millisecond)
+
+	// The expired row has been deleted from the table, not only hidden.
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&count)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, count, 1)
+}
@@ -0,0 +1,141 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// errCBOR is returned when a CBOR item is malformed or uses a feature that
+// authenticators never produce (e.g. tags or indefinite lengths).
+var errCBOR = errors.New("webauthn: malformed cbor data")
+
+// maxCBORDepth limits the nesting of arrays and maps, so a hostile payload
+// can't exhaust the stack.
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the first CBOR item found in b and returns it together with
+// the number of bytes it occupied. Only the subset of RFC 8949 used by WebAuthn
+// is supported: integers are returned as int64, byte strings as []byte, text
+// strings as string, arrays as []any and maps as map[any]any.
+func decodeCBOR(b []byte) (any, int, error) {
+	return decodeCBORItem(b, 0)
+}
+
+// decodeCBORItem is the recursive worker behind decodeCBOR.
+func decodeCBORItem(b []byte, depth int) (any, int, error) {
+	if depth > maxCBORDepth || len(b) == 0 {
+		return nil, 0, errCBOR
+	}
+
+	major := b[0] >> 5
+	info := b[0] & 0x1f
+
+	// Simple values and floats share the major type 7.
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, 1, nil
+		case 21:
+			return true, 1, nil
+		case 22, 23:
+			return nil, 1, nil
+		}
+		return nil, 0, errCBOR
+	}
+
+	arg, n, err := readCBORArgument(b, info)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, 0, errCBOR
+		}
+		return int64(arg), n, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, 0, errCBOR
+		}
+		return -1 - int64(arg), n, nil
+	case 2, 3:
+		if arg > uint64(len(b)-n) {
+			return nil, 0, errCBOR
+		}
+		end := n + int(arg)
+		if major == 3 {
+			return string(b[n:end]), end, nil
+		}
+		return append([]byte(nil), b[n:end]...), end, nil
+	case 4:
+		if arg > uint64(len(b)) {
+			return nil, 0, errCBOR
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, m, err := decodeCBORItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, item)
+			n += m
+		}
+		return items, n, nil
+	case 5:
+		if arg > uint64(len(b)) {
+			return nil, 0, errCBOR
+		}
+		items := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, m, err := decodeCBORItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += m
+
+			// Only integer and text keys are comparable and used by WebAuthn.
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, 0, errCBOR
+			}
+
+			value, m, err := decodeCBORItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += m
+
+			if _, exists := items[key]; exists {
+				return nil, 0, fmt.Errorf("%w: duplicate map key", errCBOR)
+			}
+			items[key] = value
+		}
+		return items, n, nil
+	}
+
+	// Major type 6 (tags) is never used by authenticators.
+	return nil, 0, errCBOR
+}
+
+// readCBORArgument reads the argument of a CBOR item head and returns it with
+// the length of the head.
+func readCBORArgument(b []byte, info byte) (uint64, int, error) {
+	switch {
+	case info < 24:
+		return uint64(info), 1, nil
+	case info == 24 && len(b) >= 2:
+		return uint64(b[1]), 2, nil
+	case info == 25 && len(b) >= 3:
+		return uint64(binary.BigEndian.Uint16(b[1:3])), 3, nil
+	case info == 26 && len(b) >= 5:
+		return uint64(binary.BigEndian.Uint32(b[1:5])), 5, nil
+	case info == 27 && len(b) >= 9:
+		return binary.BigEndian.Uint64(b[1:9]), 9, nil
+	}
+
+	return 0, 0, errCBOR
+}
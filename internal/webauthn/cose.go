@@ -0,0 +1,118 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+)
+
+// COSE algorithm identifiers supported by the relying party, as registered in
+// the IANA "COSE Algorithms" registry.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// COSE key types and curves used by the supported algorithms.
+const (
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// SupportedAlgorithms lists the COSE algorithms accepted for new credentials,
+// in order of preference.
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// publicKey is a parsed COSE_Key able to verify assertion signatures.
+type publicKey struct {
+	alg   int
+	ecdsa *ecdsa.PublicKey
+	ed    ed25519.PublicKey
+	rsa   *rsa.PublicKey
+}
+
+// parsePublicKey parses a CBOR-encoded COSE_Key (RFC 9053) and returns it
+// together with the number of bytes it occupied.
+func parsePublicKey(b []byte) (*publicKey, int, error) {
+	item, n, err := decodeCBOR(b)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	m, ok := item.(map[any]any)
+	if !ok {
+		return nil, 0, ErrInvalidPublicKey
+	}
+
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == AlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, ErrInvalidPublicKey
+		}
+
+		// Rebuild the uncompressed point so the standard library validates
+		// that it actually lies on the curve.
+		point := append([]byte{0x04}, append(x, y...)...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, 0, ErrInvalidPublicKey
+		}
+		pk := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		return &publicKey{alg: AlgES256, ecdsa: pk}, n, nil
+
+	case kty == coseKeyTypeOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, ErrInvalidPublicKey
+		}
+		return &publicKey{alg: AlgEdDSA, ed: ed25519.PublicKey(x)}, n, nil
+
+	case kty == coseKeyTypeRSA && alg == AlgRS256:
+		modulus, _ := m[int64(-1)].([]byte)
+		exponent, _ := m[int64(-2)].([]byte)
+		if len(modulus) < 256 || len(exponent) == 0 || len(exponent) > 4 {
+			return nil, 0, ErrInvalidPublicKey
+		}
+		e := new(big.Int).SetBytes(exponent)
+		pk := &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(e.Int64())}
+		return &publicKey{alg: AlgRS256, rsa: pk}, n, nil
+	}
+
+	return nil, 0, ErrUnsupportedAlgorithm
+}
+
+// verify checks signature over message using the algorithm bound to the key.
+func (k *publicKey) verify(message, signature []byte) bool {
+	switch k.alg {
+	case AlgES256:
+		digest := sha256.Sum256(message)
+		return ecdsa.VerifyASN1(k.ecdsa, digest[:], signature)
+	case AlgEdDSA:
+		return ed25519.Verify(k.ed, message, signature)
+	case AlgRS256:
+		digest := sha256.Sum256(message)
+		return rsa.VerifyPKCS1v15(k.rsa, crypto.SHA256, digest[:], signature) == nil
+	}
+
+	return false
+}
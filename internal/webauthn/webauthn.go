@@ -0,0 +1,446 @@
+// Package webauthn implements the server side of the WebAuthn registration and
+// authentication ceremonies, limited to what the application needs: "none"
+// attestation and ES256, EdDSA or RS256 credential keys.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Errors returned by the verification methods. Callers usually only need to
+// tell a failed ceremony apart from an internal error, so every one of them
+// wraps ErrVerification.
+var (
+	ErrVerification           = errors.New("webauthn: verification failed")
+	ErrInvalidChallenge       = wrap("challenge mismatch")
+	ErrInvalidOrigin          = wrap("origin mismatch")
+	ErrInvalidType            = wrap("unexpected client data type")
+	ErrInvalidRelyingParty    = wrap("relying party id hash mismatch")
+	ErrUserNotPresent         = wrap("user presence flag not set")
+	ErrUserNotVerified        = wrap("user verification flag not set")
+	ErrInvalidSignature       = wrap("invalid signature")
+	ErrInvalidPublicKey       = wrap("invalid credential public key")
+	ErrUnsupportedAlgorithm   = wrap("unsupported credential algorithm")
+	ErrUnsupportedAttestation = wrap("unsupported attestation format")
+	ErrSignCountRegression    = wrap("signature counter did not increase")
+	ErrMalformed              = wrap("malformed authenticator response")
+)
+
+// wrap creates a sentinel error which wraps ErrVerification.
+func wrap(msg string) error {
+	return &verificationError{msg: "webauthn: " + msg}
+}
+
+// verificationError is the concrete type of every ceremony failure.
+type verificationError struct {
+	msg string
+}
+
+func (e *verificationError) Error() string { return e.msg }
+func (e *verificationError) Unwrap() error { return ErrVerification }
+
+// Authenticator data flags, see §6.1 of the WebAuthn Level 2 specification.
+const (
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+)
+
+// challengeLength is the number of random bytes in a ceremony challenge.
+const challengeLength = 32
+
+// Timeout is the number of milliseconds the browser is given to complete a
+// ceremony.
+const Timeout = 120000
+
+// Bytes is a byte slice that is encoded in JSON as unpadded base64url, which is
+// the encoding browsers expect for the binary fields of the WebAuthn options.
+type Bytes []byte
+
+// MarshalJSON implements json.Marshaler.
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Padded input is accepted too.
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+
+	*b = decoded
+	return nil
+}
+
+// RelyingParty holds the identity of the web application as seen by the
+// authenticators.
+type RelyingParty struct {
+	// ID is the effective domain of the application (e.g. "example.com").
+	ID string
+	// Name is a human-readable name shown by the browser.
+	Name string
+	// Origin is the exact origin the ceremonies are performed from
+	// (e.g. "https://example.com").
+	Origin string
+}
+
+// Credential is a public key credential created during registration.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+	AAGUID    []byte
+}
+
+// CredentialDescriptor identifies a credential in the ceremony options.
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   Bytes  `json:"id"`
+}
+
+// User describes the account a credential is created for.
+type User struct {
+	ID          Bytes  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CreationOptions are the PublicKeyCredentialCreationOptions sent to
+// navigator.credentials.create().
+type CreationOptions struct {
+	Challenge              Bytes                  `json:"challenge"`
+	RelyingParty           relyingPartyEntity     `json:"rp"`
+	User                   User                   `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	Attestation            string                 `json:"attestation"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+}
+
+// RequestOptions are the PublicKeyCredentialRequestOptions sent to
+// navigator.credentials.get().
+type RequestOptions struct {
+	Challenge        Bytes                  `json:"challenge"`
+	RelyingPartyID   string                 `json:"rpId"`
+	Timeout          int                    `json:"timeout"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type relyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// AttestationResponse is the JSON encoded PublicKeyCredential returned by
+// navigator.credentials.create().
+type AttestationResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes `json:"clientDataJSON"`
+		AttestationObject Bytes `json:"attestationObject"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the JSON encoded PublicKeyCredential returned by
+// navigator.credentials.get().
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes `json:"clientDataJSON"`
+		AuthenticatorData Bytes `json:"authenticatorData"`
+		Signature         Bytes `json:"signature"`
+		UserHandle        Bytes `json:"userHandle"`
+	} `json:"response"`
+}
+
+// clientData is the subset of CollectedClientData checked by the server.
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// authenticatorData is the parsed form of the authenticator data structure.
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// NewChallenge returns a fresh random challenge for a ceremony.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeLength)
+	_, err := rand.Read(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// CreationOptions builds the options for a registration ceremony. Existing
+// credentials of the user are excluded so the same authenticator can't be
+// registered twice.
+func (rp *RelyingParty) CreationOptions(challenge []byte, user User, exclude [][]byte) CreationOptions {
+	opts := CreationOptions{
+		Challenge:          challenge,
+		RelyingParty:       relyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:               user,
+		Timeout:            Timeout,
+		Attestation:        "none",
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+	}
+
+	for _, alg := range SupportedAlgorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, credentialParameter{Type: "public-key", Alg: alg})
+	}
+
+	return opts
+}
+
+// RequestOptions builds the options for an authentication ceremony. An empty
+// allow list lets the authenticator pick a discoverable credential, which is
+// how passwordless login works.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow [][]byte, userVerification string) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		RelyingPartyID:   rp.ID,
+		Timeout:          Timeout,
+		AllowCredentials: descriptors(allow),
+		UserVerification: userVerification,
+	}
+}
+
+// VerifyRegistration validates the response of a registration ceremony started
+// with challenge and returns the new credential.
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, resp AttestationResponse, requireUV bool) (Credential, error) {
+	err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	item, _, err := decodeCBOR(resp.Response.AttestationObject)
+	if err != nil {
+		return Credential{}, ErrMalformed
+	}
+
+	attestation, ok := item.(map[any]any)
+	if !ok {
+		return Credential{}, ErrMalformed
+	}
+
+	// Only the "none" attestation format is accepted: the application doesn't
+	// care about the make and model of the authenticator.
+	format, _ := attestation["fmt"].(string)
+	statement, _ := attestation["attStmt"].(map[any]any)
+	if format != "none" || len(statement) != 0 {
+		return Credential{}, ErrUnsupportedAttestation
+	}
+
+	raw, _ := attestation["authData"].([]byte)
+	data, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	err = rp.verifyFlags(data, requireUV)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	if data.flags&flagAttestedCredentialData == 0 {
+		return Credential{}, ErrMalformed
+	}
+
+	// The credential ID reported by the browser must match the one inside the
+	// signed authenticator data.
+	if !bytes.Equal(resp.RawID, data.credentialID) {
+		return Credential{}, ErrMalformed
+	}
+
+	return Credential{
+		ID:        data.credentialID,
+		PublicKey: data.publicKey,
+		SignCount: data.signCount,
+		AAGUID:    data.aaguid,
+	}, nil
+}
+
+// VerifyAssertion validates the response of an authentication ceremony started
+// with challenge against a stored credential public key and signature counter.
+// It returns the new value of the signature counter to be stored.
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, resp AssertionResponse, credentialPublicKey []byte, storedSignCount uint32, requireUV bool) (uint32, error) {
+	err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := parseAuthenticatorData(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	err = rp.verifyFlags(data, requireUV)
+	if err != nil {
+		return 0, err
+	}
+
+	key, _, err := parsePublicKey(credentialPublicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	// The signature covers the authenticator data followed by the hash of the
+	// client data.
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	message := append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...)
+	if !key.verify(message, resp.Response.Signature) {
+		return 0, ErrInvalidSignature
+	}
+
+	// Authenticators that implement a signature counter must always increase
+	// it. A counter that didn't move forward is a sign that the credential
+	// has been cloned. A zero on both sides means no counter is implemented.
+	if (data.signCount != 0 || storedSignCount != 0) && data.signCount <= storedSignCount {
+		return 0, ErrSignCountRegression
+	}
+
+	return data.signCount, nil
+}
+
+// verifyClientData checks the type, challenge and origin of the client data.
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var cd clientData
+	err := json.Unmarshal(raw, &cd)
+	if err != nil {
+		return ErrMalformed
+	}
+
+	if cd.Type != ceremony {
+		return ErrInvalidType
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil || len(challenge) == 0 || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return ErrInvalidChallenge
+	}
+
+	if cd.Origin != rp.Origin {
+		return ErrInvalidOrigin
+	}
+
+	return nil
+}
+
+// verifyFlags checks the relying party ID hash and the user presence and
+// verification flags of the authenticator data.
+func (rp *RelyingParty) verifyFlags(data authenticatorData, requireUV bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data.rpIDHash, rpIDHash[:]) {
+		return ErrInvalidRelyingParty
+	}
+
+	if data.flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+
+	if requireUV && data.flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+
+	return nil
+}
+
+// parseAuthenticatorData parses the binary authenticator data structure.
+func parseAuthenticatorData(b []byte) (authenticatorData, error) {
+	// rpIdHash (32) + flags (1) + signCount (4).
+	if len(b) < 37 {
+		return authenticatorData{}, ErrMalformed
+	}
+
+	data := authenticatorData{
+		rpIDHash:  b[:32],
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+
+	if data.flags&flagAttestedCredentialData == 0 {
+		return data, nil
+	}
+
+	// aaguid (16) + credentialIdLength (2) + credentialId + credentialPublicKey.
+	rest := b[37:]
+	if len(rest) < 18 {
+		return authenticatorData{}, ErrMalformed
+	}
+	data.aaguid = rest[:16]
+
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+		return authenticatorData{}, ErrMalformed
+	}
+	data.credentialID = rest[:idLength]
+	rest = rest[idLength:]
+
+	// Parsing the key also validates it, so an unusable credential is
+	// rejected at registration time rather than at the first login.
+	_, n, err := parsePublicKey(rest)
+	if err != nil {
+		if errors.Is(err, ErrVerification) {
+			return authenticatorData{}, err
+		}
+		return authenticatorData{}, ErrMalformed
+	}
+	data.publicKey = rest[:n]
+
+	return data, nil
+}
+
+// descriptors converts raw credential IDs into credential descriptors.
+func descriptors(ids [][]byte) []CredentialDescriptor {
+	list := []CredentialDescriptor{}
+	for _, id := range ids {
+		list = append(list, CredentialDescriptor{Type: "public-key", ID: id})
+	}
+
+	return list
+}
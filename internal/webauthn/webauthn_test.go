@@ -0,0 +1,180 @@
+package webauthn_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AlessioPani/go-snippetbox/internal/assert"
+	"github.com/AlessioPani/go-snippetbox/internal/webauthn"
+	"github.com/AlessioPani/go-snippetbox/internal/webauthn/webauthntest"
+)
+
+// newRelyingParty returns the relying party used by the tests.
+func newRelyingParty() *webauthn.RelyingParty {
+	return &webauthn.RelyingParty{
+		ID:     "localhost",
+		Name:   "Snippetbox",
+		Origin: "https://localhost:8080",
+	}
+}
+
+// register runs a registration ceremony with the given authenticator and
+// returns the created credential.
+func register(t *testing.T, rp *webauthn.RelyingParty, a *webauthntest.Authenticator) webauthn.Credential {
+	t.Helper()
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := webauthn.User{ID: []byte("1"), Name: "test@test.com", DisplayName: "John Doe"}
+	resp, err := a.Create(rp.CreationOptions(challenge, user, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err := rp.VerifyRegistration(challenge, resp, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return credential
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	rp := newRelyingParty()
+
+	tests := []struct {
+		name      string
+		origin    string
+		rpID      string
+		uv        bool
+		requireUV bool
+		challenge []byte
+		wantErr   error
+	}{
+		{"Valid", rp.Origin, rp.ID, true, true, nil, nil},
+		{"Wrong origin", "https://evil.example", rp.ID, true, false, nil, webauthn.ErrInvalidOrigin},
+		{"Wrong relying party", rp.Origin, "evil.example", true, false, nil, webauthn.ErrInvalidRelyingParty},
+		{"Wrong challenge", rp.Origin, rp.ID, true, false, []byte("another challenge"), webauthn.ErrInvalidChallenge},
+		{"User not verified", rp.Origin, rp.ID, false, true, nil, webauthn.ErrUserNotVerified},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := webauthntest.NewAuthenticator(test.origin, test.rpID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.UserVerified = test.uv
+
+			challenge, err := webauthn.NewChallenge()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The authenticator answers to the challenge of the test case
+			// when one is set, otherwise to the expected one.
+			opts := rp.CreationOptions(challenge, webauthn.User{ID: []byte("1")}, nil)
+			if test.challenge != nil {
+				opts.Challenge = test.challenge
+			}
+
+			resp, err := a.Create(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			credential, err := rp.VerifyRegistration(challenge, resp, test.requireUV)
+			assert.Equal(t, errors.Is(err, test.wantErr), true)
+			if test.wantErr == nil {
+				assert.Equal(t, string(credential.ID), string(a.CredentialID))
+				assert.Equal(t, string(credential.PublicKey), string(a.PublicKey()))
+			} else {
+				assert.Equal(t, errors.Is(err, webauthn.ErrVerification), true)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	rp := newRelyingParty()
+
+	a, err := webauthntest.NewAuthenticator(rp.Origin, rp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential := register(t, rp, a)
+
+	// assert runs an authentication ceremony and returns the verification
+	// result for a stored counter.
+	assertion := func(t *testing.T, storedCount uint32) (uint32, error) {
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := a.Get(rp.RequestOptions(challenge, nil, "required"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return rp.VerifyAssertion(challenge, resp, credential.PublicKey, storedCount, true)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		count, err := assertion(t, credential.SignCount)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, count, credential.SignCount+1)
+	})
+
+	t.Run("Cloned authenticator", func(t *testing.T) {
+		// Rewind the counter, as a clone of the authenticator would do.
+		a.SignCount = 0
+		_, err := assertion(t, 5)
+		assert.Equal(t, errors.Is(err, webauthn.ErrSignCountRegression), true)
+	})
+
+	t.Run("Wrong key", func(t *testing.T) {
+		other, err := webauthntest.NewAuthenticator(rp.Origin, rp.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := other.Get(rp.RequestOptions(challenge, nil, "required"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = rp.VerifyAssertion(challenge, resp, credential.PublicKey, 0, true)
+		assert.Equal(t, errors.Is(err, webauthn.ErrInvalidSignature), true)
+	})
+
+	t.Run("Replayed response", func(t *testing.T) {
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := a.Get(rp.RequestOptions(challenge, nil, "required"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A response signed for an old challenge must not be accepted for
+		// a new ceremony.
+		next, err := webauthn.NewChallenge()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = rp.VerifyAssertion(next, resp, credential.PublicKey, 0, true)
+		assert.Equal(t, errors.Is(err, webauthn.ErrInvalidChallenge), true)
+	})
+}
@@ -0,0 +1,159 @@
+// Package webauthntest provides a software authenticator which can take part in
+// WebAuthn ceremonies without a browser or a hardware key, for use in tests.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/AlessioPani/go-snippetbox/internal/webauthn"
+)
+
+// Authenticator is an in-memory ES256 authenticator holding a single
+// discoverable credential.
+type Authenticator struct {
+	// Origin is reported in the client data, as a browser would do.
+	Origin string
+	// RelyingPartyID is hashed into the authenticator data.
+	RelyingPartyID string
+	// UserVerified controls whether the UV flag is set in the responses.
+	UserVerified bool
+	// SignCount is the current value of the signature counter. It's
+	// incremented before each assertion; tests can rewind it to simulate a
+	// cloned authenticator.
+	SignCount uint32
+
+	CredentialID []byte
+	UserHandle   []byte
+
+	key *ecdsa.PrivateKey
+}
+
+// NewAuthenticator creates an authenticator with a fresh key pair and
+// credential ID, with user verification enabled.
+func NewAuthenticator(origin, rpID string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	_, err = rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{
+		Origin:         origin,
+		RelyingPartyID: rpID,
+		UserVerified:   true,
+		CredentialID:   id,
+		key:            key,
+	}, nil
+}
+
+// Create answers a registration ceremony, like navigator.credentials.create().
+func (a *Authenticator) Create(opts webauthn.CreationOptions) (webauthn.AttestationResponse, error) {
+	a.UserHandle = opts.User.ID
+
+	clientDataJSON, err := a.clientData("webauthn.create", opts.Challenge)
+	if err != nil {
+		return webauthn.AttestationResponse{}, err
+	}
+
+	// Attested credential data: aaguid, credential ID length, credential ID
+	// and COSE public key.
+	authData := a.authenticatorData(0x40)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, a.PublicKey()...)
+
+	attestationObject := encode(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", authData},
+	})
+
+	var resp webauthn.AttestationResponse
+	resp.ID = base64.RawURLEncoding.EncodeToString(a.CredentialID)
+	resp.RawID = a.CredentialID
+	resp.Type = "public-key"
+	resp.Response.ClientDataJSON = clientDataJSON
+	resp.Response.AttestationObject = attestationObject
+
+	return resp, nil
+}
+
+// Get answers an authentication ceremony, like navigator.credentials.get().
+func (a *Authenticator) Get(opts webauthn.RequestOptions) (webauthn.AssertionResponse, error) {
+	clientDataJSON, err := a.clientData("webauthn.get", opts.Challenge)
+	if err != nil {
+		return webauthn.AssertionResponse{}, err
+	}
+
+	a.SignCount++
+	authData := a.authenticatorData(0)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return webauthn.AssertionResponse{}, err
+	}
+
+	var resp webauthn.AssertionResponse
+	resp.ID = base64.RawURLEncoding.EncodeToString(a.CredentialID)
+	resp.RawID = a.CredentialID
+	resp.Type = "public-key"
+	resp.Response.ClientDataJSON = clientDataJSON
+	resp.Response.AuthenticatorData = authData
+	resp.Response.Signature = signature
+	resp.Response.UserHandle = a.UserHandle
+
+	return resp, nil
+}
+
+// PublicKey returns the COSE encoding of the credential public key.
+func (a *Authenticator) PublicKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+
+	return encode(cborMap{
+		{int64(1), int64(2)},
+		{int64(3), int64(webauthn.AlgES256)},
+		{int64(-1), int64(1)},
+		{int64(-2), x},
+		{int64(-3), y},
+	})
+}
+
+// clientData builds the client data JSON a browser would produce.
+func (a *Authenticator) clientData(ceremony string, challenge []byte) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+// authenticatorData builds the fixed part of the authenticator data with the
+// user presence flag, and user verification if enabled, plus extra flags.
+func (a *Authenticator) authenticatorData(flags byte) []byte {
+	flags |= 0x01
+	if a.UserVerified {
+		flags |= 0x04
+	}
+
+	rpIDHash := sha256.Sum256([]byte(a.RelyingPartyID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
@@ -0,0 +1,54 @@
+package webauthntest
+
+import "encoding/binary"
+
+// cborMap is an ordered CBOR map, so the encoding is deterministic.
+type cborMap []cborPair
+
+// cborPair is a key/value entry of a cborMap.
+type cborPair struct {
+	key   any
+	value any
+}
+
+// encode encodes v in CBOR. Only the types used by authenticators are
+// supported: int64, []byte, string and cborMap.
+func encode(v any) []byte {
+	switch v := v.(type) {
+	case int64:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case cborMap:
+		b := head(5, uint64(len(v)))
+		for _, pair := range v {
+			b = append(b, encode(pair.key)...)
+			b = append(b, encode(pair.value)...)
+		}
+		return b
+	}
+
+	panic("webauthntest: unsupported cbor type")
+}
+
+// head encodes the initial bytes of a CBOR item.
+func head(major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return []byte{major | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major | 26}, uint32(arg))
+	}
+
+	return binary.BigEndian.AppendUint64([]byte{major | 27}, arg)
+}
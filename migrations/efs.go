@@ -0,0 +1,26 @@
+// Package migrations embeds the SQL migrations of the application database.
+//
+// The migrations of each SQL dialect are in a directory named after it,
+// sqlite or postgres, and have the same versions in both. Each migration is a
+// pair of files named after its version and a short description, e.g.
+// 0002_add_tags.up.sql and 0002_add_tags.down.sql. The up file applies the
+// change to the schema and the down file reverts it. The versions are applied
+// in ascending order and must never be renumbered once released.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed "sqlite/*.sql" "postgres/*.sql"
+var files embed.FS
+
+// Dialect returns the migrations of a SQL dialect: sqlite or postgres.
+func Dialect(name string) (fs.FS, error) {
+	if name != "sqlite" && name != "postgres" {
+		return nil, fs.ErrNotExist
+	}
+
+	return fs.Sub(files, name)
+}
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS reports;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS user_sessions;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS credentials;
+DROP TABLE IF EXISTS snippets;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,128 @@
+-- Schema of the SQLite database at version 1, in the dialect of PostgreSQL.
+-- The columns whose length isn't checked by the application are TEXT, as
+-- PostgreSQL enforces the length of VARCHAR columns while SQLite doesn't.
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL,
+	hashed_password CHAR(60) NOT NULL,
+	role VARCHAR(20) NOT NULL DEFAULT 'user',
+	suspended BOOLEAN NOT NULL DEFAULT FALSE,
+	created TIMESTAMPTZ NOT NULL,
+	CONSTRAINT uc_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS snippets (
+	id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+	user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	title VARCHAR(255) NOT NULL,
+	content TEXT NOT NULL,
+	language VARCHAR(30) NOT NULL DEFAULT '',
+	hidden BOOLEAN NOT NULL DEFAULT FALSE,
+	created TIMESTAMPTZ NOT NULL,
+	expires TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS credentials (
+	id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+	user_id INTEGER NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	credential_id BYTEA NOT NULL,
+	public_key BYTEA NOT NULL,
+	sign_count BIGINT NOT NULL DEFAULT 0,
+	created TIMESTAMPTZ NOT NULL,
+	last_used TIMESTAMPTZ,
+	CONSTRAINT uc_credential_id UNIQUE (credential_id),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+	id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+	email TEXT NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	created TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts (email, created);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts (ip, created);
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+	id CHAR(32) PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	user_agent TEXT NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	remember_me BOOLEAN NOT NULL DEFAULT FALSE,
+	created TIMESTAMPTZ NOT NULL,
+	last_seen TIMESTAMPTZ NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	token TEXT PRIMARY KEY,
+	data BYTEA NOT NULL,
+	expiry BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_expiry ON sessions (expiry);
+
+CREATE TABLE IF NOT EXISTS reports (
+	id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+	snippet_id INTEGER NOT NULL,
+	reporter_id INTEGER NOT NULL,
+	reason VARCHAR(500) NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	created TIMESTAMPTZ NOT NULL,
+	CONSTRAINT uc_snippet_reporter UNIQUE (snippet_id, reporter_id),
+	FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+	FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports (status);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+	id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+	actor_id INTEGER NOT NULL,
+	action VARCHAR(50) NOT NULL,
+	snippet_id INTEGER NOT NULL DEFAULT 0,
+	user_id INTEGER NOT NULL DEFAULT 0,
+	details TEXT NOT NULL DEFAULT '',
+	created TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+	user_id INTEGER NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	token_hash BYTEA NOT NULL,
+	scopes VARCHAR(255) NOT NULL,
+	created TIMESTAMPTZ NOT NULL,
+	expires TIMESTAMPTZ,
+	last_used TIMESTAMPTZ,
+	CONSTRAINT uc_token_hash UNIQUE (token_hash),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+	id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+	user_id INTEGER NOT NULL,
+	url VARCHAR(2048) NOT NULL,
+	secret VARCHAR(255) NOT NULL,
+	created TIMESTAMPTZ NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks (user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+	webhook_id INTEGER NOT NULL,
+	event VARCHAR(50) NOT NULL,
+	payload BYTEA NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt TIMESTAMPTZ NOT NULL,
+	response_code INTEGER NOT NULL DEFAULT 0,
+	error VARCHAR(255) NOT NULL DEFAULT '',
+	created TIMESTAMPTZ NOT NULL,
+	delivered TIMESTAMPTZ,
+	FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries (status, next_attempt);
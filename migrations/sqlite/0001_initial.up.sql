@@ -0,0 +1,128 @@
+-- Schema of the database as it was created by checkTables. The statements
+-- don't fail on existing objects, so that the databases created before the
+-- migrations can be brought up to date with it when they are baselined.
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	hashed_password CHAR(60) NOT NULL,
+	role VARCHAR(20) NOT NULL DEFAULT 'user',
+	suspended BOOLEAN NOT NULL DEFAULT FALSE,
+	created DATETIME NOT NULL,
+	CONSTRAINT uc_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS snippets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	title VARCHAR(255) NOT NULL,
+	content VARCHAR(255) NOT NULL,
+	language VARCHAR(30) NOT NULL DEFAULT '',
+	hidden BOOLEAN NOT NULL DEFAULT FALSE,
+	created DATETIME NOT NULL,
+	expires DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS credentials (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	credential_id BLOB NOT NULL,
+	public_key BLOB NOT NULL,
+	sign_count INTEGER NOT NULL DEFAULT 0,
+	created DATETIME NOT NULL,
+	last_used DATETIME,
+	CONSTRAINT uc_credential_id UNIQUE (credential_id),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email VARCHAR(255) NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	created DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts (email, created);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts (ip, created);
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+	id CHAR(32) PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	user_agent VARCHAR(255) NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	remember_me BOOLEAN NOT NULL DEFAULT FALSE,
+	created DATETIME NOT NULL,
+	last_seen DATETIME NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	token TEXT PRIMARY KEY,
+	data BLOB NOT NULL,
+	expiry INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_expiry ON sessions (expiry);
+
+CREATE TABLE IF NOT EXISTS reports (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	snippet_id INTEGER NOT NULL,
+	reporter_id INTEGER NOT NULL,
+	reason VARCHAR(500) NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	created DATETIME NOT NULL,
+	CONSTRAINT uc_snippet_reporter UNIQUE (snippet_id, reporter_id),
+	FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+	FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports (status);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	actor_id INTEGER NOT NULL,
+	action VARCHAR(50) NOT NULL,
+	snippet_id INTEGER NOT NULL DEFAULT 0,
+	user_id INTEGER NOT NULL DEFAULT 0,
+	details TEXT NOT NULL DEFAULT '',
+	created DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	token_hash BLOB NOT NULL,
+	scopes VARCHAR(255) NOT NULL,
+	created DATETIME NOT NULL,
+	expires DATETIME,
+	last_used DATETIME,
+	CONSTRAINT uc_token_hash UNIQUE (token_hash),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	url VARCHAR(2048) NOT NULL,
+	secret VARCHAR(255) NOT NULL,
+	created DATETIME NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks (user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	webhook_id INTEGER NOT NULL,
+	event VARCHAR(50) NOT NULL,
+	payload BLOB NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt DATETIME NOT NULL,
+	response_code INTEGER NOT NULL DEFAULT 0,
+	error VARCHAR(255) NOT NULL DEFAULT '',
+	created DATETIME NOT NULL,
+	delivered DATETIME,
+	FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries (status, next_attempt);
@@ -0,0 +1,4 @@
+-- The time the snippets were last edited, set to their creation time when they
+-- are inserted and by this backfill.
+ALTER TABLE snippets ADD COLUMN updated DATETIME;
+UPDATE snippets SET updated = created;
@@ -0,0 +1,99 @@
+// Renders the OpenAPI description of the JSON API on the documentation page.
+// Everything is built with DOM nodes and served by the application itself, as
+// the Content-Security-Policy allows neither inline scripts nor other origins.
+var specURL = "/api/v1/openapi.json";
+
+function element(tag, text, className) {
+	var el = document.createElement(tag);
+	if (text) {
+		el.textContent = text;
+	}
+	if (className) {
+		el.className = className;
+	}
+	return el;
+}
+
+// resolve follows a "$ref" to the components of the specification.
+function resolve(spec, schema) {
+	if (schema && schema["$ref"]) {
+		var name = schema["$ref"].split("/").pop();
+		return {name: name, schema: spec.components.schemas[name]};
+	}
+	return {name: "", schema: schema};
+}
+
+function renderSchema(spec, title, schema) {
+	var resolved = resolve(spec, schema);
+	var section = element("div");
+	section.appendChild(element("h4", title + (resolved.name ? " (" + resolved.name + ")" : "")));
+	section.appendChild(element("pre", JSON.stringify(resolved.schema, null, 2)));
+	return section;
+}
+
+function renderOperation(spec, server, path, method, op) {
+	var section = element("div", "", "operation");
+	section.appendChild(element("h3", method.toUpperCase() + " " + server + path));
+	section.appendChild(element("p", op.summary));
+
+	var scopes = [];
+	(op.security || []).forEach(function (requirement) {
+		Object.keys(requirement).forEach(function (scheme) {
+			scopes = scopes.concat(requirement[scheme]);
+		});
+	});
+	section.appendChild(element("p", scopes.length ? "API token scope: " + scopes.join(", ") : "No API token needed."));
+
+	if (op.parameters) {
+		var list = element("ul");
+		op.parameters.forEach(function (param) {
+			list.appendChild(element("li", param.name + " (" + param["in"] + ", " + param.schema.type + (param.required ? ", required" : "") + ")"));
+		});
+		section.appendChild(element("h4", "Parameters"));
+		section.appendChild(list);
+	}
+
+	if (op.requestBody) {
+		section.appendChild(renderSchema(spec, "Request body", op.requestBody.content["application/json"].schema));
+	}
+
+	var responses = element("ul");
+	Object.keys(op.responses).sort().forEach(function (status) {
+		var response = op.responses[status];
+		var text = status + ": " + response.description;
+		if (response.content) {
+			text += " (" + resolve(spec, response.content["application/json"].schema).name + ")";
+		}
+		responses.appendChild(element("li", text));
+	});
+	section.appendChild(element("h4", "Responses"));
+	section.appendChild(responses);
+
+	return section;
+}
+
+function renderSpec(spec) {
+	var server = spec.servers[0].url;
+	document.getElementById("api-title").textContent = spec.info.title + " " + spec.info.version;
+	document.getElementById("api-description").textContent = spec.info.description;
+
+	var operations = document.getElementById("api-operations");
+	Object.keys(spec.paths).sort().forEach(function (path) {
+		Object.keys(spec.paths[path]).forEach(function (method) {
+			operations.appendChild(renderOperation(spec, server, path, method, spec.paths[path][method]));
+		});
+	});
+
+	Object.keys(spec.components.schemas).sort().forEach(function (name) {
+		operations.appendChild(renderSchema(spec, "Schema", {"$ref": "#/components/schemas/" + name}));
+	});
+}
+
+fetch(specURL).then(function (response) {
+	if (!response.ok) {
+		throw new Error(response.statusText);
+	}
+	return response.json();
+}).then(renderSpec).catch(function (err) {
+	document.getElementById("api-description").textContent = "The API description can't be loaded: " + err.message;
+});
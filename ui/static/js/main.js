@@ -5,4 +5,115 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// WebAuthn helpers: the server sends and expects binary fields encoded as
+// unpadded base64url strings.
+function bufferToBase64url(buffer) {
+	var bytes = new Uint8Array(buffer);
+	var binary = "";
+	for (var i = 0; i < bytes.length; i++) {
+		binary += String.fromCharCode(bytes[i]);
+	}
+	return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function base64urlToBuffer(value) {
+	var base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+	var binary = atob(base64);
+	var bytes = new Uint8Array(binary.length);
+	for (var i = 0; i < binary.length; i++) {
+		bytes[i] = binary.charCodeAt(i);
+	}
+	return bytes.buffer;
+}
+
+function postJSON(url, csrfToken, body) {
+	return fetch(url, {
+		method: "POST",
+		credentials: "same-origin",
+		headers: {"Content-Type": "application/json", "X-CSRF-Token": csrfToken},
+		body: JSON.stringify(body || {})
+	}).then(function (response) {
+		if (!response.ok) {
+			throw new Error(response.statusText);
+		}
+		return response.json();
+	});
+}
+
+function showPasskeyError(form, message) {
+	var error = form.querySelector(".error");
+	error.textContent = message;
+	error.hidden = false;
+}
+
+var registerForm = document.getElementById("passkey-register");
+if (registerForm && window.PublicKeyCredential) {
+	registerForm.addEventListener("submit", function (event) {
+		event.preventDefault();
+		var csrfToken = registerForm.elements["csrf_token"].value;
+		var name = registerForm.elements["name"].value;
+
+		postJSON("/user/passkeys/register/begin", csrfToken).then(function (options) {
+			var publicKey = options.publicKey;
+			publicKey.challenge = base64urlToBuffer(publicKey.challenge);
+			publicKey.user.id = base64urlToBuffer(publicKey.user.id);
+			publicKey.excludeCredentials.forEach(function (c) {
+				c.id = base64urlToBuffer(c.id);
+			});
+			return navigator.credentials.create({publicKey: publicKey});
+		}).then(function (credential) {
+			return postJSON("/user/passkeys/register/finish", csrfToken, {
+				name: name,
+				credential: {
+					id: credential.id,
+					rawId: bufferToBase64url(credential.rawId),
+					type: credential.type,
+					response: {
+						clientDataJSON: bufferToBase64url(credential.response.clientDataJSON),
+						attestationObject: bufferToBase64url(credential.response.attestationObject)
+					}
+				}
+			});
+		}).then(function (result) {
+			window.location.assign(result.redirect);
+		}).catch(function () {
+			showPasskeyError(registerForm, "The passkey could not be registered. Please try again.");
+		});
+	});
+}
+
+var loginForm = document.getElementById("passkey-login");
+if (loginForm && window.PublicKeyCredential) {
+	loginForm.addEventListener("submit", function (event) {
+		event.preventDefault();
+		var csrfToken = loginForm.elements["csrf_token"].value;
+
+		postJSON("/user/login/passkey/begin", csrfToken).then(function (options) {
+			var publicKey = options.publicKey;
+			publicKey.challenge = base64urlToBuffer(publicKey.challenge);
+			publicKey.allowCredentials.forEach(function (c) {
+				c.id = base64urlToBuffer(c.id);
+			});
+			return navigator.credentials.get({publicKey: publicKey});
+		}).then(function (credential) {
+			var response = credential.response;
+			return postJSON("/user/login/passkey/finish", csrfToken, {
+				id: credential.id,
+				rawId: bufferToBase64url(credential.rawId),
+				type: credential.type,
+				response: {
+					clientDataJSON: bufferToBase64url(response.clientDataJSON),
+					authenticatorData: bufferToBase64url(response.authenticatorData),
+					signature: bufferToBase64url(response.signature),
+					userHandle: response.userHandle ? bufferToBase64url(response.userHandle) : ""
+				}
+			});
+		}).then(function (result) {
+			window.location.assign(result.redirect);
+		}).catch(function () {
+			showPasskeyError(loginForm, "The passkey could not be verified. Please try again.");
+		});
+	});
+}